@@ -0,0 +1,161 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxArchiveEntrySize limits the entries ConvertArchive extracts unless
+// MaxArchiveEntrySize is set, so a small archive can't fill the disk
+const DefaultMaxArchiveEntrySize = 100 << 20
+
+// ErrArchiveEntryTooLarge is returned for archive entries larger than MaxArchiveEntrySize
+var ErrArchiveEntryTooLarge = errors.New("archive entry is too large")
+
+// ConvertArchive converts every spreadsheet, CSV and TSV file in the ZIP archive zipPath
+// to outDir and returns the stats of each in archive order. Output files are named after
+// the entry path, with folders joined by underscores, and numbered if two entries give
+// the same name; in all sheets mode every sheet gets its own file as in ConvertFile.
+// Other entries are skipped, entries larger than MaxArchiveEntrySize fail. A failed entry doesn't stop
+// the others, the errors of all failed entries are returned together.
+func (ec *ExcelConverter) ConvertArchive(zipPath, outDir string) ([]ConvertStats, error) {
+	return ec.ConvertArchiveContext(context.Background(), zipPath, outDir)
+}
+
+// ConvertArchiveContext is like ConvertArchive, but stops converting and kills
+// LibreOffice when ctx is done
+func (ec *ExcelConverter) ConvertArchiveContext(ctx context.Context, zipPath, outDir string) ([]ConvertStats, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	tempDir, err := ec.createTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	// Entries are read by their own extension
+	converter := *ec
+	converter.ForceFormat = ""
+
+	var results []ConvertStats
+	var errs []error
+	names := make(uniqueFileNames)
+	for _, file := range reader.File {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		if !isArchivedSpreadsheet(file) || converter.checkInputFormat(file.Name) != nil {
+			continue
+		}
+
+		// Flatten the entry path, so equal names in different folders don't collide,
+		// and number names that still do, such as report.xlsx and report.xls
+		ext := path.Ext(file.Name)
+		outputExt := converter.archiveOutputExt(ext)
+		outputName := names.claim(SafeFileName(strings.TrimSuffix(path.Clean(file.Name), ext)) + outputExt)
+		baseName := strings.TrimSuffix(outputName, outputExt)
+		outputPath := filepath.Join(outDir, outputName)
+
+		fmt.Printf("Converting %s from archive to %s\n", file.Name, outputPath)
+
+		stats, err := converter.convertArchiveEntry(ctx, file, filepath.Join(tempDir, baseName+ext), outputPath)
+		stats.Source = file.Name
+		if err != nil {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			fmt.Printf("Warning: failed to convert %s: %v\n", file.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", file.Name, err))
+		}
+		results = append(results, stats)
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// isArchivedSpreadsheet skips folders and the metadata files that macOS and Excel
+// leave next to spreadsheets: __MACOSX/ resource forks, ._ files and ~$ lock files
+func isArchivedSpreadsheet(file *zip.File) bool {
+	if file.FileInfo().IsDir() || strings.HasPrefix(file.Name, "__MACOSX/") {
+		return false
+	}
+	name := path.Base(file.Name)
+	return !strings.HasPrefix(name, "._") && !strings.HasPrefix(name, "~$")
+}
+
+// archiveOutputExt returns the extension of the file written for an archive entry,
+// or a file of ConvertDirectory.
+// In all sheets mode only the directory of the output path is used, but the entry's
+// extension keeps a name like "book.zip.xlsx" from selecting ZIP output.
+func (ec *ExcelConverter) archiveOutputExt(inputExt string) string {
+	switch {
+	case ec.AllSheetsMode || ec.SheetPattern != "":
+		return inputExt
+	case ec.OutputFormat == FormatParquet:
+		return ".parquet"
+	default:
+		return ".csv"
+	}
+}
+
+// convertArchiveEntry extracts an archive entry to tempPath, so LibreOffice can read it,
+// and converts it to outputPath
+func (ec *ExcelConverter) convertArchiveEntry(ctx context.Context, file *zip.File, tempPath, outputPath string) (ConvertStats, error) {
+	maxSize := ec.MaxArchiveEntrySize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxArchiveEntrySize
+	}
+	if err := extractFile(file, tempPath, maxSize); err != nil {
+		return ConvertStats{DetectedHeaderRow: -1}, err
+	}
+	defer func() { _ = os.Remove(tempPath) }()
+
+	return ec.ConvertFileStatsContext(ctx, tempPath, outputPath)
+}
+
+// extractFile writes the content of an archive entry to dstPath, failing with
+// ErrArchiveEntryTooLarge beyond maxSize bytes whatever size the entry claims
+func extractFile(file *zip.File, dstPath string, maxSize int64) error {
+	if file.UncompressedSize64 > uint64(maxSize) {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrArchiveEntryTooLarge, file.UncompressedSize64, maxSize)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read archive entry: %w", err)
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	n, err := io.Copy(dst, io.LimitReader(src, maxSize+1))
+	if err == nil && n > maxSize {
+		err = fmt.Errorf("%w: the limit is %d bytes", ErrArchiveEntryTooLarge, maxSize)
+	} else if err != nil {
+		err = fmt.Errorf("failed to read archive entry: %w", err)
+	}
+	if err != nil {
+		_ = dst.Close()
+		_ = os.Remove(dstPath)
+		return err
+	}
+	return dst.Close()
+}
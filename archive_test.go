@@ -0,0 +1,158 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestConvertArchive(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "upload.zip")
+	table := "Name,Qty\na,1\n"
+	writeZip(t, zipPath,
+		zipPart{"report.csv", table},
+		zipPart{"2024/report.csv", table},
+		// Names are numbered in archive order, case-insensitively
+		zipPart{"report.tsv", "Name\tQty\na\t1\n"},
+		zipPart{"REPORT.csv", table},
+		zipPart{"big.csv", table + strings.Repeat("b,2\n", 20)},
+		zipPart{"folder/", ""},
+		zipPart{"__MACOSX/report.csv", table},
+		zipPart{"__MACOSX/2024/._report.csv", table},
+		zipPart{"._report.csv", table},
+		zipPart{"~$report.csv", table},
+		zipPart{"notes.txt", table})
+
+	outDir := filepath.Join(dir, "out")
+	converter := NewExcelConverter()
+	converter.DetectionStrategy = StrategyNone
+	converter.MaxArchiveEntrySize = 50
+	results, err := converter.ConvertArchive(zipPath, outDir)
+	if !errors.Is(err, ErrArchiveEntryTooLarge) {
+		t.Errorf("ConvertArchive() error = %v, want ErrArchiveEntryTooLarge", err)
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "big.csv: ") {
+		t.Errorf("ConvertArchive() error = %v, want it to name big.csv", err)
+	}
+
+	if got := convertedSources(results); !reflect.DeepEqual(got,
+		[]string{"report.csv", "2024/report.csv", "report.tsv", "REPORT.csv", "big.csv"}) {
+		t.Errorf("ConvertArchive() converted %q", got)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []string
+	for _, entry := range entries {
+		written = append(written, entry.Name())
+	}
+	want := []string{"2024_report.csv", "REPORT_3.csv", "report.csv", "report_2.csv"}
+	sort.Strings(written)
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("ConvertArchive() wrote %q, want %q", written, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outDir, "report_2.csv")); string(data) != table {
+		t.Errorf("report_2.csv = %q, want the tsv entry as CSV", data)
+	}
+}
+
+func TestExtractFile(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"small.csv": "a,b\n", "exact.csv": "0123456789", "large.csv": "01234567890"} {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{"small.csv", "a,b\n", nil},
+		{"exact.csv", "0123456789", nil},
+		{"large.csv", "", ErrArchiveEntryTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file *zip.File
+			for _, f := range reader.File {
+				if f.Name == tt.name {
+					file = f
+				}
+			}
+
+			dstPath := filepath.Join(t.TempDir(), tt.name)
+			err := extractFile(file, dstPath, 10)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("extractFile() error = %v, want %v", err, tt.wantErr)
+			}
+			data, readErr := os.ReadFile(dstPath)
+			if tt.wantErr != nil {
+				if !errors.Is(readErr, os.ErrNotExist) {
+					t.Errorf("extractFile() left %q behind", data)
+				}
+				return
+			}
+			if string(data) != tt.want {
+				t.Errorf("extractFile() wrote %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+// An entry whose header understates its size still can't write more than the limit
+func TestExtractFileUnderstatedSize(t *testing.T) {
+	content := []byte(strings.Repeat("x", 100))
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.CreateRaw(&zip.FileHeader{
+		Name:               "lying.csv",
+		Method:             zip.Store,
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dstPath := filepath.Join(t.TempDir(), "lying.csv")
+	if err := extractFile(reader.File[0], dstPath, 10); err == nil {
+		t.Fatal("extractFile() of an entry larger than its header says succeeded")
+	}
+	if _, err := os.Stat(dstPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("extractFile() left the partial file behind")
+	}
+}
@@ -0,0 +1,130 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/oxyii/excel2csv/internal/officecrypto"
+)
+
+// ErrNotSpreadsheet is returned by CanConvert for files whose content is certainly
+// not a spreadsheet, whatever their extension says
+var ErrNotSpreadsheet = errors.New("file content is not a spreadsheet")
+
+// otherFileSignatures start common file types that are never spreadsheets
+var otherFileSignatures = [][]byte{
+	[]byte("%PDF-"),
+	[]byte("\x89PNG\r\n\x1a\n"),
+	{0xFF, 0xD8, 0xFF}, // JPEG
+	[]byte("GIF87a"),
+	[]byte("GIF89a"),
+	{0x1F, 0x8B}, // gzip
+	[]byte("7z\xBC\xAF\x27\x1C"),
+	[]byte("Rar!\x1A\x07"),
+}
+
+// CanConvert checks with the default settings that inputPath can be converted,
+// without converting it. See ExcelConverter.CanConvert.
+func CanConvert(inputPath string) (bool, error) {
+	return NewExcelConverter().CanConvert(inputPath)
+}
+
+// CanConvert checks that inputPath can be converted without running LibreOffice:
+// the format is supported, the content isn't another kind of file, the workbook
+// isn't password-protected or Password opens it, and LibreOffice is available. It reads only the file
+// header and, for ZIP based formats, the archive directory. Content it doesn't
+// recognize, such as HTML or SpreadsheetML saved as .xls, is left to LibreOffice.
+// When the file can't be converted it returns false and the reason,
+// ErrNotSpreadsheet, ErrPasswordRequired, ErrWrongPassword or ErrLibreOfficeNotFound
+// for the known cases.
+func (ec *ExcelConverter) CanConvert(inputPath string) (bool, error) {
+	if err := ec.checkInputFormat(inputPath); err != nil {
+		return false, err
+	}
+
+	stat, err := os.Stat(inputPath)
+	if err != nil {
+		return false, fmt.Errorf("input file not accessible: %w", err)
+	}
+	if stat.IsDir() {
+		return false, fmt.Errorf("input path is a directory: %s", inputPath)
+	}
+
+	// CSV and TSV are read directly, any readable file will do
+	if ec.isDelimitedText(inputPath) {
+		file, err := os.Open(inputPath)
+		if err != nil {
+			return false, fmt.Errorf("input file not accessible: %w", err)
+		}
+		_ = file.Close()
+		return true, nil
+	}
+
+	format, err := SniffFormat(inputPath)
+	if err != nil {
+		return false, fmt.Errorf("input file not accessible: %w", err)
+	}
+	if format == "" {
+		if other, err := isOtherFileType(inputPath); err != nil {
+			return false, fmt.Errorf("input file not accessible: %w", err)
+		} else if other {
+			return false, fmt.Errorf("%s: %w", filepath.Base(inputPath), ErrNotSpreadsheet)
+		}
+	}
+
+	// The sniffed format is what LibreOffice will find, encrypted xlsx included.
+	// Unrecognized content can't be an encrypted workbook.
+	if protected, err := ec.checkEncryption(inputPath, "."+format); err != nil {
+		return false, err
+	} else if protected {
+		if err := officecrypto.CheckPassword(inputPath, ec.Password); err != nil {
+			return false, fmt.Errorf("%s: %w", filepath.Base(inputPath), err)
+		}
+	}
+
+	if _, err := FindLibreOffice(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isOtherFileType reports whether content SniffFormat didn't recognize is certainly
+// not a spreadsheet: an empty file, a readable ZIP archive without workbook parts or
+// a file starting with the signature of another type
+func isOtherFileType(inputPath string) (bool, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return false, err
+	}
+	header := make([]byte, 8)
+	n, err := io.ReadFull(file, header)
+	_ = file.Close()
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, err
+	}
+	header = header[:n]
+
+	if n == 0 {
+		return true, nil
+	}
+	if bytes.HasPrefix(header, zipSignature) {
+		// A damaged archive may still be repaired by LibreOffice
+		reader, err := zip.OpenReader(inputPath)
+		if err != nil {
+			return false, nil
+		}
+		_ = reader.Close()
+		return true, nil
+	}
+	for _, signature := range otherFileSignatures {
+		if bytes.HasPrefix(header, signature) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,104 @@
+package excel2csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cellRange is a rectangle of cells, with 0-based inclusive bounds
+type cellRange struct {
+	firstRow, firstCol int
+	lastRow, lastCol   int
+}
+
+// parseCellRange parses A1 notation such as "B3:F120", or a single cell such as "B3".
+// Absolute references ($B$3) are accepted, the corners may be given in any order.
+func parseCellRange(value string) (cellRange, error) {
+	from, to, found := strings.Cut(strings.ToUpper(strings.ReplaceAll(value, "$", "")), ":")
+	if !found {
+		to = from
+	}
+
+	firstRow, firstCol, err := parseCellRef(strings.TrimSpace(from))
+	if err != nil {
+		return cellRange{}, fmt.Errorf("invalid cell range %q: %w", value, err)
+	}
+	lastRow, lastCol, err := parseCellRef(strings.TrimSpace(to))
+	if err != nil {
+		return cellRange{}, fmt.Errorf("invalid cell range %q: %w", value, err)
+	}
+
+	return cellRange{
+		firstRow: min(firstRow, lastRow), firstCol: min(firstCol, lastCol),
+		lastRow: max(firstRow, lastRow), lastCol: max(firstCol, lastCol),
+	}, nil
+}
+
+// parseCellRef parses an uppercase cell reference such as "AB12" into 0-based row and column
+func parseCellRef(ref string) (int, int, error) {
+	// Up to three column letters, as in Excel's last column XFD
+	letters := strings.IndexFunc(ref, func(r rune) bool { return r < 'A' || r > 'Z' })
+	row, err := strconv.Atoi(ref[max(letters, 0):])
+	if letters <= 0 || letters > 3 || err != nil || row < 1 {
+		return 0, 0, fmt.Errorf("cell %q is not a column and row such as B3", ref)
+	}
+
+	col := 0
+	for _, r := range ref[:letters] {
+		col = col*26 + int(r-'A') + 1
+	}
+
+	return row - 1, col - 1, nil
+}
+
+// formatCellRange returns the A1 notation of a range
+func formatCellRange(bounds cellRange) string {
+	return cellName(bounds.firstRow, bounds.firstCol) + ":" + cellName(bounds.lastRow, bounds.lastCol)
+}
+
+// cellName returns the A1 name of a 0-based cell
+func cellName(row, col int) string {
+	return columnLetters(col) + strconv.Itoa(row+1)
+}
+
+// columnLetters returns the letters of a 0-based column: A, B, ..., Z, AA
+func columnLetters(col int) string {
+	letters := ""
+	for col >= 0 {
+		letters = string(rune('A'+col%26)) + letters
+		col = col/26 - 1
+	}
+	return letters
+}
+
+// applyCellRange checks that CellRange lies within the exported rows of a sheet and
+// drops the columns outside of it. The rows are kept, tableBoundaries picks them.
+func (ec *ExcelConverter) applyCellRange(records [][]string) ([][]string, error) {
+	if ec.CellRange == "" {
+		return records, nil
+	}
+
+	bounds, err := parseCellRange(ec.CellRange)
+	if err != nil {
+		return nil, err
+	}
+
+	width := 0
+	for _, record := range records {
+		width = max(width, len(record))
+	}
+	if bounds.lastRow >= len(records) || bounds.lastCol >= width {
+		return nil, fmt.Errorf("cell range %s is outside the sheet, which has %d rows and %d columns", ec.CellRange, len(records), width)
+	}
+
+	cropped := make([][]string, len(records))
+	for i, record := range records {
+		// LibreOffice pads rows, but in-memory rows may be ragged
+		for len(record) <= bounds.lastCol {
+			record = append(record, "")
+		}
+		cropped[i] = record[bounds.firstCol : bounds.lastCol+1]
+	}
+	return cropped, nil
+}
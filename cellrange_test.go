@@ -0,0 +1,96 @@
+package excel2csv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCellRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    cellRange
+		wantErr bool
+	}{
+		{"B3:F120", cellRange{firstRow: 2, firstCol: 1, lastRow: 119, lastCol: 5}, false},
+		{"$B$3", cellRange{firstRow: 2, firstCol: 1, lastRow: 2, lastCol: 1}, false},
+		{"$B$3:$D$4", cellRange{firstRow: 2, firstCol: 1, lastRow: 3, lastCol: 3}, false},
+		{"b3 : d4", cellRange{firstRow: 2, firstCol: 1, lastRow: 3, lastCol: 3}, false},
+		// Corners in any order make the same rectangle
+		{"F120:B3", cellRange{firstRow: 2, firstCol: 1, lastRow: 119, lastCol: 5}, false},
+		{"B120:F3", cellRange{firstRow: 2, firstCol: 1, lastRow: 119, lastCol: 5}, false},
+		{"Z1:AA1", cellRange{firstRow: 0, firstCol: 25, lastRow: 0, lastCol: 26}, false},
+		{"A1:XFD1048576", cellRange{firstRow: 0, firstCol: 0, lastRow: 1048575, lastCol: 16383}, false},
+		{"A0", cellRange{}, true},
+		{"A1:B0", cellRange{}, true},
+		{"ABCD1", cellRange{}, true},
+		{"A1:ABCD2", cellRange{}, true},
+		{"3B", cellRange{}, true},
+		{"B", cellRange{}, true},
+		{"", cellRange{}, true},
+		{"A1:B2:C3", cellRange{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseCellRange(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCellRange(%q) error = %v, want error %t", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCellRange(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCellRange(t *testing.T) {
+	bounds := cellRange{firstRow: 2, firstCol: 25, lastRow: 1048575, lastCol: 16383}
+	if got := formatCellRange(bounds); got != "Z3:XFD1048576" {
+		t.Errorf("formatCellRange() = %q, want %q", got, "Z3:XFD1048576")
+	}
+}
+
+func TestApplyCellRange(t *testing.T) {
+	records := [][]string{
+		{"Report", "", ""},
+		{"Name", "Qty", "Note"},
+		{"a", "1"}, // in-memory rows may be ragged
+	}
+
+	tests := []struct {
+		name      string
+		cellRange string
+		want      [][]string
+		wantErr   string
+	}{
+		{"no range", "", records, ""},
+		{"columns", "$B$2:C3", [][]string{{"", ""}, {"Qty", "Note"}, {"1", ""}}, ""},
+		{"reversed corners", "C3:B2", [][]string{{"", ""}, {"Qty", "Note"}, {"1", ""}}, ""},
+		{"single cell", "A2", [][]string{{"Report"}, {"Name"}, {"a"}}, ""},
+		{"rows outside the sheet", "A2:B4", nil, "cell range A2:B4 is outside the sheet, which has 3 rows and 3 columns"},
+		{"columns outside the sheet", "XFD1", nil, "cell range XFD1 is outside the sheet, which has 3 rows and 3 columns"},
+		{"invalid", "A0", nil, `invalid cell range "A0"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			converter.CellRange = tt.cellRange
+
+			got, err := converter.applyCellRange(records)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("applyCellRange() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyCellRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
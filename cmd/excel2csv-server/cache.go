@@ -0,0 +1,115 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// resultCache keeps the CSV of recent single sheet conversions in memory, keyed by
+// the content of the input file and the options, so re-uploads are answered without
+// running LibreOffice. It evicts the least recently used results beyond its limits.
+type resultCache struct {
+	mu         sync.Mutex
+	maxBytes   int64
+	maxEntries int
+	bytes      int64
+	order      *list.List // of *cachedResult, most recently used first
+	entries    map[string]*list.Element
+}
+
+// cachedResult is a converted CSV and what is reported with it
+type cachedResult struct {
+	key  string
+	csv  []byte
+	rows int
+}
+
+// conversionCache is nil unless CACHE_MAX_MB is set
+var conversionCache *resultCache
+
+func newResultCache(maxBytes int64, maxEntries int) *resultCache {
+	return &resultCache{
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the result stored under key and marks it as recently used
+func (c *resultCache) get(key string) (*cachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cachedResult), true
+}
+
+// add stores a result, evicting the least recently used ones until it fits.
+// Results larger than the whole cache are not stored.
+func (c *resultCache) add(result *cachedResult) {
+	size := int64(len(result.csv))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[result.key]; ok {
+		c.remove(element)
+	}
+	for c.order.Len() > 0 && (c.bytes+size > c.maxBytes || c.order.Len() >= c.maxEntries) {
+		c.remove(c.order.Back())
+	}
+
+	c.entries[result.key] = c.order.PushFront(result)
+	c.bytes += size
+}
+
+// remove drops an element, the caller holds mu
+func (c *resultCache) remove(element *list.Element) {
+	result := c.order.Remove(element).(*cachedResult)
+	delete(c.entries, result.key)
+	c.bytes -= int64(len(result.csv))
+}
+
+// size returns the number of cached results and their total bytes
+func (c *resultCache) size() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len(), c.bytes
+}
+
+// cacheKey hashes the input file together with the options that shape the output
+func cacheKey(inputPath string, options ...any) (string, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	content := sha256.New()
+	if _, err := io.Copy(content, file); err != nil {
+		return "", err
+	}
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+
+	// The fixed size content hash keeps file and options bytes from running into each other
+	key := sha256.New()
+	key.Write(content.Sum(nil))
+	key.Write(encodedOptions)
+	return hex.EncodeToString(key.Sum(nil)), nil
+}
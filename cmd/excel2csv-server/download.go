@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/oxyii/excel2csv"
+)
+
+// sourceTimeout limits a whole source_url download, on top of the conversion timeout
+const sourceTimeout = time.Minute
+
+var (
+	errSourceNotAllowed = errors.New("source_url not allowed")
+	errSourceTooLarge   = errors.New("source file too large")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, private but not covered by IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// sourceURLHosts reads the comma-separated SOURCE_URL_HOSTS environment variable,
+// the hosts source_url may download from
+func sourceURLHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("SOURCE_URL_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// sourceFetcher downloads the spreadsheets of the source_url field. With allowed hosts it
+// only downloads from them; without, it only connects to public addresses, so a URL can't
+// reach the server's own network. The address is checked on connect, after DNS resolution.
+type sourceFetcher struct {
+	hosts  map[string]bool
+	client *http.Client
+}
+
+func newSourceFetcher(hosts []string) *sourceFetcher {
+	fetcher := &sourceFetcher{hosts: make(map[string]bool, len(hosts))}
+	for _, host := range hosts {
+		fetcher.hosts[host] = true
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if len(hosts) == 0 {
+		dialer.Control = publicAddressOnly
+	}
+
+	fetcher.client = &http.Client{
+		Timeout: sourceTimeout,
+		Transport: &http.Transport{
+			// No proxy, the address check must see the address of the source itself
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			return fetcher.checkURL(req.URL)
+		},
+	}
+	return fetcher
+}
+
+// checkURL rejects URLs that aren't http or https, or whose host isn't allowed
+func (f *sourceFetcher) checkURL(source *url.URL) error {
+	if source.Scheme != "http" && source.Scheme != "https" {
+		return errors.New("invalid source_url: only http and https URLs are supported")
+	}
+	if source.Hostname() == "" {
+		return errors.New("invalid source_url: URL has no host")
+	}
+	if len(f.hosts) > 0 && !f.hosts[strings.ToLower(source.Hostname())] {
+		return fmt.Errorf("%w: host %s is not allowed", errSourceNotAllowed, source.Hostname())
+	}
+	return nil
+}
+
+// publicAddressOnly refuses connections to loopback, private, link-local and other
+// non-public addresses
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s is not a public address", errSourceNotAllowed, ip)
+	}
+	return nil
+}
+
+// download saves the file at source to dstPath, refusing files over maxUploadSize,
+// and returns its size and Content-Type
+func (f *sourceFetcher) download(ctx context.Context, source *url.URL, dstPath string) (int64, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("source_url returned %s", resp.Status)
+	}
+	if resp.ContentLength > maxUploadSize {
+		return 0, "", errSourceTooLarge
+	}
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return 0, "", err
+	}
+	// Read one byte over the limit to tell a file of exactly maxUploadSize from a larger one
+	size, err := io.Copy(dst, io.LimitReader(resp.Body, maxUploadSize+1))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if size > maxUploadSize {
+		return 0, "", errSourceTooLarge
+	}
+
+	return size, resp.Header.Get("Content-Type"), nil
+}
+
+// sourceFileName returns the file name of the last path segment of a URL, made safe
+// to join to a directory on any system, or "download" for URLs without one
+func sourceFileName(source *url.URL) string {
+	name := path.Base(source.Path)
+	if name == "." || name == ".." || name == "/" {
+		return "download"
+	}
+	// A decoded segment may hold backslashes, path separators on Windows
+	return excel2csv.SafeFileName(name)
+}
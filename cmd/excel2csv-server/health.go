@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthFixture is a one-sheet workbook converted by the deep health check
+//
+//go:embed health.xlsx
+var healthFixture []byte
+
+// healthExpectedCSV is the conversion of healthFixture
+const healthExpectedCSV = "Check,Value\nhealth,42\n"
+
+const (
+	// deepCheckTTL is how long a deep check result is reused, so frequent probes
+	// don't start LibreOffice every time
+	deepCheckTTL = 30 * time.Second
+	// deepCheckTimeout limits a deep check conversion
+	deepCheckTimeout = time.Minute
+)
+
+// deepChecks caches the result of the last deep health check
+var deepChecks deepCheck
+
+// deepCheck runs the end-to-end conversion check of /health?deep=true. Probes arriving
+// during a check wait for it and share its result.
+type deepCheck struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// result returns the cached result of the last check, running a new check when it expired
+func (c *deepCheck) result() (time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checked.IsZero() || time.Since(c.checked) > deepCheckTTL {
+		// Not bound to the request, a probe giving up doesn't fail the cached result
+		ctx, cancel := context.WithTimeout(context.Background(), deepCheckTimeout)
+		defer cancel()
+		c.err = convertHealthFixture(ctx)
+		c.checked = time.Now()
+		if c.err != nil {
+			logger.Warn("Deep health check failed", "error", c.err)
+		}
+	}
+	return c.checked, c.err
+}
+
+// convertHealthFixture converts healthFixture through the same converter settings and
+// temp directory as uploads, and compares the CSV with healthExpectedCSV
+func convertHealthFixture(ctx context.Context) error {
+	parentDir := serverTempDir
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	tempDir, err := os.MkdirTemp(parentDir, "health_")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputPath := filepath.Join(tempDir, "health.xlsx")
+	if err := os.WriteFile(inputPath, healthFixture, 0644); err != nil {
+		return fmt.Errorf("failed to write health check file: %w", err)
+	}
+
+	converter := converterTemplate.Clone()
+	var output bytes.Buffer
+	if err := converter.ConvertToContext(ctx, inputPath, &output); err != nil {
+		return fmt.Errorf("conversion failed: %w", err)
+	}
+
+	csv := strings.ReplaceAll(output.String(), "\r\n", "\n")
+	if csv != healthExpectedCSV {
+		return fmt.Errorf("unexpected conversion output %q, want %q", csv, healthExpectedCSV)
+	}
+	return nil
+}
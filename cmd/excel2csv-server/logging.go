@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// logger writes the server's structured logs to stderr
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// newLogger creates the logger for LOG_FORMAT: "text" (default) or "json"
+func newLogger(format string) (*slog.Logger, error) {
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil)), nil
+	default:
+		return nil, fmt.Errorf("invalid LOG_FORMAT %q, use text or json", format)
+	}
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// conversionLog collects the fields of the event logged once per conversion request
+type conversionLog struct {
+	filename  string
+	size      int64
+	allSheets bool
+	start     time.Time
+	cache     string // "hit" or "miss" when the result cache is enabled
+}
+
+// complete logs and records a successful conversion, with extra output attributes
+func (c *conversionLog) complete(outputBytes int64, extra ...any) {
+	duration := time.Since(c.start)
+	metrics.record(duration, c.size, outputBytes, false, "", c.cache)
+	logger.Info("conversion_complete", c.attrs(duration, "success",
+		append(extra, "output_bytes", outputBytes)...)...)
+}
+
+// failed logs and records a failed conversion with the error code sent to the client, if any
+func (c *conversionLog) failed(err error) {
+	duration := time.Since(c.start)
+	_, code := conversionErrorStatus(err)
+	metrics.record(duration, c.size, 0, true, code, c.cache)
+	logger.Warn("conversion_failed", c.attrs(duration, "failure",
+		"error", err.Error(),
+		"code", code,
+	)...)
+}
+
+func (c *conversionLog) attrs(duration time.Duration, outcome string, extra ...any) []any {
+	attrs := []any{
+		"filename", c.filename,
+		"size", c.size,
+		"all_sheets", c.allSheets,
+		"duration_ms", duration.Milliseconds(),
+		"outcome", outcome,
+	}
+	if c.cache != "" {
+		attrs = append(attrs, "cache", c.cache)
+	}
+	return append(attrs, extra...)
+}
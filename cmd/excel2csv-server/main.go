@@ -1,30 +1,78 @@
 package main
 
 import (
-	"archive/zip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/oxyii/excel2csv"
 )
 
-// ConvertRequest represents the conversion request
+// maxUploadSize is the largest accepted upload
+const maxUploadSize = 50 << 20
+
+// maxRows caps the rows of a converted sheet, a small upload can still expand to millions of rows
+var maxRows = 1000000
+
+// converterTemplate holds the server-wide converter settings, configured at startup
+// and cloned for every upload
+var converterTemplate = excel2csv.NewExcelConverter()
+
+// sourceFiles downloads the files of source_url, configured at startup
+var sourceFiles *sourceFetcher
+
+// serverTempDir holds the per-request directories of this process, created at
+// startup under tempParentDir and removed on shutdown
+var serverTempDir string
+
+// uploadFormats maps the accepted file extensions to formats
+var uploadFormats = map[string]string{
+	".xlsx": "xlsx",
+	".xlsm": "xlsm",
+	".xlsb": "xlsb",
+	".xls":  "xls",
+	".ods":  "ods",
+}
+
+// mimeFormats maps spreadsheet media types, sent as the Content-Type of the
+// uploaded part, to formats for uploads without a known extension
+var mimeFormats = map[string]string{
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": "xlsx",
+	"application/vnd.ms-excel.sheet.macroenabled.12":                    "xlsm",
+	"application/vnd.ms-excel.sheet.binary.macroenabled.12":             "xlsb",
+	"application/vnd.ms-excel":                                          "xls",
+	"application/vnd.oasis.opendocument.spreadsheet":                    "ods",
+}
+
+// ConvertRequest represents the conversion request, the doc tags describe the fields in /openapi.json
 type ConvertRequest struct {
-	Separator   string `json:"separator,omitempty"`
-	StartRow    *int   `json:"start_row,omitempty"`
-	SheetName   string `json:"sheet_name,omitempty"`
-	SheetIndex  *int   `json:"sheet_index,omitempty"`
-	AllSheets   bool   `json:"all_sheets,omitempty"`
-	CleanBreaks *bool  `json:"clean_breaks,omitempty"`
+	Separator     string `json:"separator,omitempty" doc:"CSV separator: comma, semicolon, tab, pipe, space or a single character"`
+	StartRow      *int   `json:"start_row,omitempty" doc:"Force the table to start at this row (0-based) instead of detecting it"`
+	SheetName     string `json:"sheet_name,omitempty" doc:"Convert the sheet with this name"`
+	SheetIndex    *int   `json:"sheet_index,omitempty" doc:"Convert the sheet at this index (0-based)"`
+	AllSheets     bool   `json:"all_sheets,omitempty" doc:"Convert all sheets and return a ZIP archive with one CSV per sheet"`
+	CleanBreaks   *bool  `json:"clean_breaks,omitempty" doc:"Replace line breaks in cells with spaces (default true)"`
+	IncludeHeader *bool  `json:"include_header,omitempty" doc:"Write the detected header row (default true)"`
+	Sanitize      bool   `json:"sanitize_formulas,omitempty" doc:"Prefix cells that spreadsheets would run as formulas with a quote"`
+	Collapse      bool   `json:"collapse_spaces,omitempty" doc:"Collapse runs of spaces in cells and trim cells"`
+	Password      string `json:"password,omitempty" doc:"Password of an encrypted xlsx, xlsm or xlsb workbook, never logged"`
 }
 
 // ConvertResponse represents the conversion response
@@ -33,24 +81,107 @@ type ConvertResponse struct {
 	Message       string   `json:"message"`
 	Files         []string `json:"files,omitempty"`
 	Error         string   `json:"error,omitempty"`
+	Code          string   `json:"code,omitempty" doc:"Machine-readable error code, e.g. row_limit_exceeded or password_required"`
 	ProcessedRows int      `json:"processed_rows,omitempty"`
 }
 
 // HealthResponse represents health check response
 type HealthResponse struct {
-	Status      string `json:"status"`
-	LibreOffice bool   `json:"libreoffice_available"`
-	Version     string `json:"version"`
-	Timestamp   string `json:"timestamp"`
+	Status             string `json:"status"`
+	LibreOffice        bool   `json:"libreoffice_available"`
+	LibreOfficePath    string `json:"libreoffice_path,omitempty"`
+	LibreOfficeVersion string `json:"libreoffice_version,omitempty"`
+	Version            string `json:"version"`
+	Timestamp          string `json:"timestamp"`
+	Conversion         string `json:"conversion,omitempty" doc:"Deep check only: ok, or why converting a test workbook failed"`
+	ConversionChecked  string `json:"conversion_checked,omitempty" doc:"Deep check only: when the conversion was checked, results are reused for 30 seconds"`
 }
 
 func main() {
+	var err error
+	logger, err = newLogger(os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
 	r := mux.NewRouter()
 
+	// Optional API key, /health stays public for probes
+	apiKey := os.Getenv("API_KEY")
+
+	// Conversion limits
+	maxConversions := runtime.NumCPU()
+	if value := os.Getenv("MAX_CONCURRENT_CONVERSIONS"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			fatal("Invalid MAX_CONCURRENT_CONVERSIONS", "value", value)
+		}
+		maxConversions = limit
+	}
+	conversionTimeout := 5 * time.Minute
+	if value := os.Getenv("CONVERSION_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			fatal("Invalid CONVERSION_TIMEOUT", "value", value)
+		}
+		conversionTimeout = timeout
+	}
+	if value := os.Getenv("MAX_ROWS"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			fatal("Invalid MAX_ROWS", "value", value)
+		}
+		maxRows = limit
+	}
+	converterTemplate.MaxRows = maxRows
+	shutdownTimeout := 30 * time.Second
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			fatal("Invalid SHUTDOWN_TIMEOUT", "value", value)
+		}
+		shutdownTimeout = timeout
+	}
+	sourceFiles = newSourceFetcher(sourceURLHosts())
+	if value := os.Getenv("CACHE_MAX_MB"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			fatal("Invalid CACHE_MAX_MB", "value", value)
+		}
+		maxEntries := 1000
+		if value := os.Getenv("CACHE_MAX_ENTRIES"); value != "" {
+			maxEntries, err = strconv.Atoi(value)
+			if err != nil || maxEntries < 1 {
+				fatal("Invalid CACHE_MAX_ENTRIES", "value", value)
+			}
+		}
+		if limit > 0 {
+			conversionCache = newResultCache(int64(limit)<<20, maxEntries)
+		}
+	}
+	parentDir := tempParentDir()
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		fatal("Failed to create temp directory", "error", err)
+	}
+	// Other instances may share the home directory, each process cleans up only its own files
+	serverTempDir, err = os.MkdirTemp(parentDir, "server_")
+	if err != nil {
+		fatal("Failed to create temp directory", "error", err)
+	}
+
+	convert := limitConcurrency(maxConversions, withTimeout(conversionTimeout, convertHandler))
+
 	// API routes
 	r.HandleFunc("/health", healthCheckHandler).Methods("GET")
-	r.HandleFunc("/convert", convertHandler).Methods("POST")
+	r.HandleFunc("/convert", requireAPIKey(apiKey, convert)).Methods("POST")
 	r.HandleFunc("/info", infoHandler).Methods("GET")
+	r.HandleFunc("/openapi.json", openAPIHandler).Methods("GET")
+
+	// Optional Prometheus metrics
+	metricsEnabled := os.Getenv("METRICS_ENABLED") == "true"
+	if metricsEnabled {
+		r.HandleFunc("/metrics", metricsHandler).Methods("GET")
+	}
 
 	// Static files for simple web interface
 	r.HandleFunc("/", indexHandler).Methods("GET")
@@ -61,59 +192,173 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("🚀 Excel2CSV Server starting on port %s", port)
-	log.Printf("📋 Endpoints:")
-	log.Printf("   GET  /health  - Health check")
-	log.Printf("   POST /convert - Convert Excel to CSV")
-	log.Printf("   GET  /info    - API information")
-	log.Printf("   GET  /        - Web interface")
+	origins := allowedOrigins()
+	logger.Info("Excel2CSV Server starting",
+		"port", port,
+		"endpoints", "GET /health, POST /convert, GET /info, GET /openapi.json, GET /",
+		"max_conversions", maxConversions,
+		"timeout", conversionTimeout.String(),
+		"max_rows", maxRows,
+		"api_key_required", apiKey != "",
+		"metrics", metricsEnabled,
+		"cors_origins", strings.Join(origins, ","),
+		"source_url_hosts", os.Getenv("SOURCE_URL_HOSTS"),
+		"cache_max_mb", os.Getenv("CACHE_MAX_MB"),
+	)
+
+	// Cancelling baseCtx cancels every request context, which kills running conversions
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	var handlers sync.WaitGroup
+	server := &http.Server{
+		Addr:        ":" + port,
+		Handler:     trackHandlers(&handlers, corsMiddleware(origins, r)),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		_ = os.RemoveAll(serverTempDir)
+		fatal("Server stopped", "error", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Stop accepting connections and let running conversions finish
+	logger.Info("Shutting down, draining active conversions", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("Shutdown timed out, cancelling active conversions", "error", err)
+		cancelRequests()
+		_ = server.Close()
+	}
+
+	// Close doesn't wait for handlers, wait until the cancelled ones have stopped
+	// LibreOffice and removed their directories
+	handlers.Wait()
+	if err := os.RemoveAll(serverTempDir); err != nil {
+		logger.Warn("Failed to remove temp directory", "error", err)
+	}
+	// Removing the shared parent fails while other instances still use it
+	_ = os.Remove(tempParentDir())
+	logger.Info("Server stopped")
 }
 
+// tempParentDir holds the temp directories of all server processes, it is under the home directory for LibreOffice compatibility
+func tempParentDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "excel2csv_http_temp")
+}
+
+// healthCheckHandler reports whether LibreOffice is installed, with deep=true
+// also whether it actually converts a workbook
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	deep := false
+	if value := r.URL.Query().Get("deep"); value != "" {
+		var err error
+		if deep, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid deep parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Check LibreOffice availability
-	libreOfficeAvailable := true
+	libreOfficePath, err := excel2csv.FindLibreOffice()
+	libreOfficeAvailable := err == nil
+
+	healthy := libreOfficeAvailable
+	conversion, conversionChecked := "", ""
+	if deep && libreOfficeAvailable {
+		checked, err := deepChecks.result()
+		conversion = "ok"
+		if err != nil {
+			conversion = err.Error()
+			healthy = false
+		}
+		conversionChecked = checked.UTC().Format(time.RFC3339)
+	}
 
-	// Try to check LibreOffice version
-	tempDir := "/tmp/health_check"
-	os.MkdirAll(tempDir, 0755)
-	defer os.RemoveAll(tempDir)
+	status := "healthy"
+	if !healthy {
+		status = "unhealthy"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	// A missing version is not fatal, the binary was found
+	libreOfficeVersion := ""
+	if libreOfficeAvailable {
+		if version, err := excel2csv.LibreOfficeVersion(); err == nil {
+			libreOfficeVersion = version
+		} else {
+			logger.Warn("Failed to get LibreOffice version", "error", err)
+		}
+	}
 
 	response := HealthResponse{
-		Status:      "healthy",
-		LibreOffice: libreOfficeAvailable,
-		Version:     "1.1.0",
-		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Status:             status,
+		LibreOffice:        libreOfficeAvailable,
+		LibreOfficePath:    libreOfficePath,
+		LibreOfficeVersion: libreOfficeVersion,
+		Version:            excel2csv.Version(),
+		Timestamp:          time.Now().UTC().Format(time.RFC3339),
+		Conversion:         conversion,
+		ConversionChecked:  conversionChecked,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
 func convertHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse multipart form
-	err := r.ParseMultipartForm(50 << 20) // 50MB max
+	// Parse multipart form, the form overhead is small next to the limit
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+1<<20)
+	err := r.ParseMultipartForm(maxUploadSize)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "File too large, the limit is 50MB", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
 		return
 	}
 
-	// Get file from form
-	file, fileHeader, err := r.FormFile("file")
+	// Get the file from the form, or download it from source_url
+	var file multipart.File
+	var filename, contentType string
+	var size int64
+	source, err := sourceURL(r.FormValue("source_url"))
 	if err != nil {
-		http.Error(w, "No file provided", http.StatusBadRequest)
+		status := http.StatusBadRequest
+		if errors.Is(err, errSourceNotAllowed) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
-	defer file.Close()
-
-	// Validate file extension
-	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-	if ext != ".xlsx" && ext != ".xls" && ext != ".ods" {
-		http.Error(w, "Unsupported file format. Use .xlsx, .xls, or .ods", http.StatusBadRequest)
-		return
+	if source != nil {
+		filename = sourceFileName(source)
+	} else {
+		var fileHeader *multipart.FileHeader
+		file, fileHeader, err = r.FormFile("file")
+		if err != nil {
+			http.Error(w, "No file or source_url provided", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		filename, contentType, size = fileHeader.Filename, fileHeader.Header.Get("Content-Type"), fileHeader.Size
 	}
+	ext := strings.ToLower(filepath.Ext(filename))
 
 	// Parse conversion options
 	var req ConvertRequest
@@ -141,13 +386,33 @@ func convertHandler(w http.ResponseWriter, r *http.Request) {
 	if r.FormValue("all_sheets") == "true" {
 		req.AllSheets = true
 	}
+	if includeHeader := r.FormValue("include_header"); includeHeader != "" {
+		val := includeHeader == "true"
+		req.IncludeHeader = &val
+	}
+	if r.FormValue("sanitize_formulas") == "true" {
+		req.Sanitize = true
+	}
+	if r.FormValue("collapse_spaces") == "true" {
+		req.Collapse = true
+	}
+	if password := r.FormValue("password"); password != "" {
+		req.Password = password
+	}
 
-	// Create temporary files with better error handling - use home directory for LibreOffice compatibility
-	homeDir, _ := os.UserHomeDir()
-	tempDir := filepath.Join(homeDir, "excel2csv_http_temp")
-	err = os.MkdirAll(tempDir, 0755)
+	// Create temporary files with better error handling
+	parentDir := serverTempDir
+	err = os.MkdirAll(parentDir, 0755)
 	if err != nil {
-		log.Printf("Failed to create temp directory: %v", err)
+		logger.Error("Failed to create temp directory", "error", err)
+		http.Error(w, "Failed to create temp directory", http.StatusInternalServerError)
+		return
+	}
+
+	// Each request gets its own directory so concurrent uploads don't clobber each other
+	tempDir, err := os.MkdirTemp(parentDir, "request_")
+	if err != nil {
+		logger.Error("Failed to create temp directory", "error", err)
 		http.Error(w, "Failed to create temp directory", http.StatusInternalServerError)
 		return
 	}
@@ -155,40 +420,75 @@ func convertHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Ensure temp directory is writable
 	if err := os.Chmod(tempDir, 0755); err != nil {
-		log.Printf("Failed to set temp directory permissions: %v", err)
+		logger.Warn("Failed to set temp directory permissions", "error", err)
 	}
 
-	// Save uploaded file
-	inputPath := filepath.Join(tempDir, fileHeader.Filename)
-	outputFile, err := os.Create(inputPath)
-	if err != nil {
-		log.Printf("Failed to create input file: %v", err)
-		http.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
-		return
+	// Save uploaded or downloaded file
+	inputPath := filepath.Join(tempDir, filename)
+	if source != nil {
+		size, contentType, err = sourceFiles.download(r.Context(), source, inputPath)
+		if err != nil {
+			logger.Warn("Failed to download source_url", "url", source.Redacted(), "error", err)
+			http.Error(w, fmt.Sprintf("Failed to download source_url: %v", err), sourceErrorStatus(err))
+			return
+		}
+	} else {
+		outputFile, err := os.Create(inputPath)
+		if err != nil {
+			logger.Error("Failed to create input file", "error", err)
+			http.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = io.Copy(outputFile, file)
+		outputFile.Close()
+		if err != nil {
+			logger.Error("Failed to save uploaded file", "error", err)
+			http.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
+			return
+		}
 	}
 
-	_, err = io.Copy(outputFile, file)
-	outputFile.Close()
-	if err != nil {
-		log.Printf("Failed to save uploaded file: %v", err)
-		http.Error(w, "Failed to save uploaded file", http.StatusInternalServerError)
+	// Pick the format from the file extension, else from the Content-Type,
+	// else from the content
+	format := uploadFormats[ext]
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		format = mimeFormats[mediaType]
+	}
+	if format == "" {
+		format, err = excel2csv.SniffFormat(inputPath)
+		if err != nil {
+			logger.Error("Failed to read uploaded file", "error", err)
+			http.Error(w, "Failed to read uploaded file", http.StatusInternalServerError)
+			return
+		}
+	}
+	if format == "" {
+		http.Error(w, "Unsupported file format. Use .xlsx, .xlsm, .xlsb, .xls, or .ods", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Processing file: %s (size: %d bytes)", fileHeader.Filename, fileHeader.Size)
+	// Configure a per-request copy of the server-wide settings
+	converter := converterTemplate.Clone()
+	if uploadFormats[ext] != format {
+		converter.ForceFormat = format
+	}
+	converter.Password = req.Password
 
-	// Configure converter
-	converter := excel2csv.NewExcelConverter()
+	// Fail fast with the precise reason before the expensive conversion
+	if ok, err := converter.CanConvert(inputPath); !ok {
+		writeConversionError(w, err)
+		return
+	}
 
 	// Set separator
-	switch req.Separator {
-	case "semicolon", ";":
-		converter.CSVSeparator = ';'
-	case "tab", "\t":
-		converter.CSVSeparator = '\t'
-	default:
-		converter.CSVSeparator = ','
+	separator, err := excel2csv.ParseSeparator(req.Separator)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	converter.CSVSeparator = separator
 
 	// Set options
 	if req.StartRow != nil {
@@ -203,118 +503,189 @@ func convertHandler(w http.ResponseWriter, r *http.Request) {
 	if req.CleanBreaks != nil {
 		converter.CleanLineBreaks = *req.CleanBreaks
 	}
+	if req.IncludeHeader != nil {
+		converter.OmitHeader = !*req.IncludeHeader
+	}
+	converter.SanitizeFormulas = req.Sanitize
+	converter.CollapseSpaces = req.Collapse
 	converter.AllSheetsMode = req.AllSheets
 
-	// Convert file
-	var outputPaths []string
-	baseName := strings.TrimSuffix(fileHeader.Filename, ext)
+	baseName := strings.TrimSuffix(filename, ext)
+	event := &conversionLog{
+		filename:  filename,
+		size:      size,
+		allSheets: req.AllSheets,
+		start:     time.Now(),
+	}
 
 	if req.AllSheets {
-		// Convert all sheets to separate files
-		outputDir := filepath.Join(tempDir, "output")
-		err = os.MkdirAll(outputDir, 0755)
-		if err != nil {
-			log.Printf("Failed to create output directory: %v", err)
-			http.Error(w, "Failed to create output directory", http.StatusInternalServerError)
-			return
-		}
+		// Stream each sheet into the ZIP as soon as it is converted
+		zipResponse := &responseStarted{ResponseWriter: w}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_sheets.zip\"", baseName))
 
-		err = converter.ConvertFile(inputPath, filepath.Join(outputDir, "dummy.csv"))
+		err = converter.ConvertAllSheetsToZipContext(r.Context(), inputPath, zipResponse)
 		if err != nil {
-			log.Printf("Conversion failed: %v", err)
-			response := ConvertResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Conversion failed: %v", err),
+			event.failed(err)
+			if zipResponse.started {
+				// Part of the ZIP is already sent, the client sees a truncated archive
+				return
 			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(response)
+			w.Header().Del("Content-Disposition")
+			writeConversionError(w, err)
 			return
 		}
 
-		// Find all generated CSV files
-		files, _ := os.ReadDir(outputDir)
-		for _, f := range files {
-			if strings.HasSuffix(f.Name(), ".csv") {
-				outputPaths = append(outputPaths, filepath.Join(outputDir, f.Name()))
-			}
-		}
-	} else {
-		// Convert single sheet
-		outputPath := filepath.Join(tempDir, baseName+".csv")
-		log.Printf("Converting to: %s", outputPath)
+		event.complete(zipResponse.written)
+		return
+	}
 
-		err = converter.ConvertFile(inputPath, outputPath)
+	// Answer a re-upload of the same file with the same options from the cache
+	var key string
+	if conversionCache != nil {
+		event.cache = "miss"
+		key, err = cacheKey(inputPath, req, converter.ForceFormat)
 		if err != nil {
-			log.Printf("Conversion failed: %v", err)
-			response := ConvertResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Conversion failed: %v", err),
-			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(response)
+			logger.Warn("Failed to hash input file for the cache", "error", err)
+		} else if cached, ok := conversionCache.get(key); ok {
+			event.cache = "hit"
+			event.complete(int64(len(cached.csv)), "rows", cached.rows)
+			setCSVHeaders(w, baseName, cached.rows, int64(len(cached.csv)))
+			w.Write(cached.csv)
 			return
 		}
+	}
 
-		// Check if output file exists and has content
-		if stat, err := os.Stat(outputPath); err != nil {
-			log.Printf("Output file not found: %v", err)
-			response := ConvertResponse{
-				Success: false,
-				Error:   "Conversion failed: output file not generated",
-			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(response)
-			return
-		} else {
-			log.Printf("Output file created: %s (size: %d bytes)", outputPath, stat.Size())
-		}
+	// Convert single sheet
+	outputPath := filepath.Join(tempDir, baseName+".csv")
 
-		outputPaths = append(outputPaths, outputPath)
+	stats, err := converter.ConvertFileStatsContext(r.Context(), inputPath, outputPath)
+	if err != nil {
+		event.failed(err)
+		writeConversionError(w, err)
+		return
 	}
 
-	// Return response based on number of files
-	if len(outputPaths) == 1 {
-		// Single file - return directly
-		w.Header().Set("Content-Type", "text/csv")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", baseName))
+	// Check if output file exists and has content
+	if _, err := os.Stat(outputPath); err != nil {
+		event.failed(err)
+		response := ConvertResponse{
+			Success: false,
+			Error:   "Conversion failed: output file not generated",
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	event.complete(stats.BytesWritten, "rows", stats.RowsWritten)
 
-		csvFile, err := os.Open(outputPaths[0])
-		if err != nil {
-			log.Printf("Failed to read converted file: %v", err)
-			http.Error(w, "Failed to read converted file", http.StatusInternalServerError)
-			return
+	if key != "" && stats.BytesWritten <= conversionCache.maxBytes {
+		if csv, err := os.ReadFile(outputPath); err == nil {
+			conversionCache.add(&cachedResult{key: key, csv: csv, rows: stats.RowsWritten})
 		}
-		defer csvFile.Close()
+	}
 
-		log.Printf("Sending CSV file: %s", outputPaths[0])
-		io.Copy(w, csvFile)
-	} else {
-		// Multiple files - return as ZIP
-		w.Header().Set("Content-Type", "application/zip")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_sheets.zip\"", baseName))
+	setCSVHeaders(w, baseName, stats.RowsWritten, stats.BytesWritten)
 
-		zipWriter := zip.NewWriter(w)
-		defer zipWriter.Close()
+	csvFile, err := os.Open(outputPath)
+	if err != nil {
+		logger.Error("Failed to read converted file", "error", err)
+		http.Error(w, "Failed to read converted file", http.StatusInternalServerError)
+		return
+	}
+	defer csvFile.Close()
 
-		for _, outputPath := range outputPaths {
-			csvFile, err := os.Open(outputPath)
-			if err != nil {
-				continue
-			}
+	io.Copy(w, csvFile)
+}
 
-			fileName := filepath.Base(outputPath)
-			zipFile, err := zipWriter.Create(fileName)
-			if err != nil {
-				csvFile.Close()
-				continue
-			}
+// setCSVHeaders sets the headers of a single sheet CSV response
+func setCSVHeaders(w http.ResponseWriter, baseName string, rows int, size int64) {
+	w.Header().Set("X-Processed-Rows", strconv.Itoa(rows))
+	w.Header().Set("X-Output-Bytes", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", baseName))
+}
 
-			io.Copy(zipFile, csvFile)
-			csvFile.Close()
-		}
+// sourceURL parses the source_url field, nil if it is empty
+func sourceURL(value string) (*url.URL, error) {
+	if value == "" {
+		return nil, nil
+	}
+	source, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source_url: %w", err)
+	}
+	return source, sourceFiles.checkURL(source)
+}
+
+// sourceErrorStatus returns the response status of a failed source_url download
+func sourceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errSourceNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, errSourceTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// conversionErrors maps known conversion errors to a response status and code
+var conversionErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{excel2csv.ErrRowLimitExceeded, http.StatusRequestEntityTooLarge, "row_limit_exceeded"},
+	{excel2csv.ErrNotSpreadsheet, http.StatusUnsupportedMediaType, "not_a_spreadsheet"},
+	{excel2csv.ErrPasswordRequired, http.StatusUnprocessableEntity, "password_required"},
+	{excel2csv.ErrWrongPassword, http.StatusUnprocessableEntity, "wrong_password"},
+	{excel2csv.ErrSheetNotFound, http.StatusUnprocessableEntity, "sheet_not_found"},
+	{excel2csv.ErrLibreOfficeLoadFailed, http.StatusUnprocessableEntity, "load_failed"},
+	{excel2csv.ErrNoOutputProduced, http.StatusInternalServerError, "no_output"},
+	{excel2csv.ErrLibreOfficeNotFound, http.StatusServiceUnavailable, "libreoffice_not_found"},
+}
 
-		log.Printf("Sending ZIP with %d files", len(outputPaths))
+// writeConversionError reports a failed conversion as JSON
+func writeConversionError(w http.ResponseWriter, err error) {
+	response := ConvertResponse{
+		Success: false,
+		Error:   fmt.Sprintf("Conversion failed: %v", err),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status, code := conversionErrorStatus(err); code != "" {
+		response.Code = code
+		w.WriteHeader(status)
 	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// conversionErrorStatus returns the status and code of a known conversion error,
+// or an empty code
+func conversionErrorStatus(err error) (int, string) {
+	for _, known := range conversionErrors {
+		if errors.Is(err, known.err) {
+			return known.status, known.code
+		}
+	}
+	return http.StatusOK, ""
+}
+
+// responseStarted records whether anything was written to the response,
+// after which an error can no longer be reported as JSON
+type responseStarted struct {
+	http.ResponseWriter
+	started bool
+	written int64
+}
+
+func (r *responseStarted) Write(p []byte) (int, error) {
+	r.started = true
+	n, err := r.ResponseWriter.Write(p)
+	r.written += int64(n)
+	return n, err
 }
 
 func infoHandler(w http.ResponseWriter, r *http.Request) {
@@ -322,13 +693,14 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 
 	info := map[string]interface{}{
 		"name":    "Excel2CSV API Server",
-		"version": "1.1.0",
+		"version": excel2csv.Version(),
 		"endpoints": map[string]string{
-			"GET /health":   "Health check",
-			"POST /convert": "Convert Excel to CSV",
-			"GET /info":     "API information",
+			"GET /health":       "Health check",
+			"POST /convert":     "Convert Excel to CSV",
+			"GET /info":         "API information",
+			"GET /openapi.json": "OpenAPI 3 description of the API",
 		},
-		"supported_formats": []string{".xlsx", ".xls", ".ods"},
+		"supported_formats": []string{".xlsx", ".xlsm", ".xlsb", ".xls", ".ods"},
 		"max_file_size":     "50MB",
 		"features": []string{
 			"Smart table boundary detection",
@@ -362,7 +734,7 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
     <h1>📊 Excel2CSV Converter</h1>
     
     <div class="info">
-        <strong>Supported formats:</strong> .xlsx, .xls, .ods<br>
+        <strong>Supported formats:</strong> .xlsx, .xlsm, .xlsb, .xls, .ods<br>
         <strong>Max file size:</strong> 50MB<br>
         <strong>Features:</strong> Smart table detection, multi-sheet support, configurable separators
     </div>
@@ -371,7 +743,7 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
         <form id="uploadForm" enctype="multipart/form-data">
             <div class="form-group">
                 <label for="file">Select Excel file:</label>
-                <input type="file" id="file" name="file" accept=".xlsx,.xls,.ods" required>
+                <input type="file" id="file" name="file" accept=".xlsx,.xlsm,.xlsb,.xls,.ods" required>
             </div>
 
             <div class="form-group">
@@ -380,6 +752,7 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
                     <option value="comma">Comma (,)</option>
                     <option value="semicolon">Semicolon (;)</option>
                     <option value="tab">Tab</option>
+                    <option value="pipe">Pipe (|)</option>
                 </select>
             </div>
 
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oxyii/excel2csv"
+)
+
+// durationBuckets are the upper bounds in seconds of the conversion duration histogram
+var durationBuckets = []float64{0.5, 1, 2, 5, 10, 30, 60, 120, 300}
+
+// serverMetrics holds the conversion metrics exposed on /metrics
+// in the Prometheus text format
+type serverMetrics struct {
+	mu             sync.Mutex
+	conversions    int64
+	failures       map[string]int64 // by error code
+	durationCounts []int64          // per bucket, not cumulative
+	durationSum    float64
+	durationCount  int64
+	inputBytes     int64
+	outputBytes    int64
+	cacheHits      int64
+	cacheMisses    int64
+}
+
+var metrics = &serverMetrics{
+	failures:       make(map[string]int64),
+	durationCounts: make([]int64, len(durationBuckets)),
+}
+
+// record adds a finished conversion. code is empty for a successful one
+// and "unknown" for a failure without a specific code. cache is "hit" for
+// results answered from the result cache, which are left out of the durations.
+func (m *serverMetrics) record(duration time.Duration, inputBytes, outputBytes int64, failed bool, code, cache string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.conversions++
+	m.inputBytes += inputBytes
+	m.outputBytes += outputBytes
+	switch cache {
+	case "hit":
+		m.cacheHits++
+		return
+	case "miss":
+		m.cacheMisses++
+	}
+	if failed {
+		if code == "" {
+			code = "unknown"
+		}
+		m.failures[code]++
+	}
+
+	seconds := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			m.durationCounts[i]++
+			break
+		}
+	}
+	m.durationSum += seconds
+	m.durationCount++
+}
+
+// write writes all metrics in the Prometheus text exposition format
+func (m *serverMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP excel2csv_conversions_total Conversion requests handled.")
+	fmt.Fprintln(w, "# TYPE excel2csv_conversions_total counter")
+	fmt.Fprintf(w, "excel2csv_conversions_total %d\n", m.conversions)
+
+	fmt.Fprintln(w, "# HELP excel2csv_conversion_failures_total Failed conversions by error code.")
+	fmt.Fprintln(w, "# TYPE excel2csv_conversion_failures_total counter")
+	codes := make([]string, 0, len(m.failures))
+	for code := range m.failures {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "excel2csv_conversion_failures_total{code=%q} %d\n", code, m.failures[code])
+	}
+
+	fmt.Fprintln(w, "# HELP excel2csv_conversion_duration_seconds Time spent converting.")
+	fmt.Fprintln(w, "# TYPE excel2csv_conversion_duration_seconds histogram")
+	var cumulative int64
+	for i, bound := range durationBuckets {
+		cumulative += m.durationCounts[i]
+		fmt.Fprintf(w, "excel2csv_conversion_duration_seconds_bucket{le=\"%g\"} %d\n", bound, cumulative)
+	}
+	fmt.Fprintf(w, "excel2csv_conversion_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durationCount)
+	fmt.Fprintf(w, "excel2csv_conversion_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(w, "excel2csv_conversion_duration_seconds_count %d\n", m.durationCount)
+
+	fmt.Fprintln(w, "# HELP excel2csv_input_bytes_total Bytes of uploaded files.")
+	fmt.Fprintln(w, "# TYPE excel2csv_input_bytes_total counter")
+	fmt.Fprintf(w, "excel2csv_input_bytes_total %d\n", m.inputBytes)
+
+	fmt.Fprintln(w, "# HELP excel2csv_output_bytes_total Bytes of converted output sent.")
+	fmt.Fprintln(w, "# TYPE excel2csv_output_bytes_total counter")
+	fmt.Fprintf(w, "excel2csv_output_bytes_total %d\n", m.outputBytes)
+
+	fmt.Fprintln(w, "# HELP excel2csv_libreoffice_runs_total LibreOffice processes started, including retries.")
+	fmt.Fprintln(w, "# TYPE excel2csv_libreoffice_runs_total counter")
+	fmt.Fprintf(w, "excel2csv_libreoffice_runs_total %d\n", excel2csv.LibreOfficeRuns())
+
+	if conversionCache != nil {
+		entries, size := conversionCache.size()
+		fmt.Fprintln(w, "# HELP excel2csv_cache_hits_total Conversions answered from the result cache.")
+		fmt.Fprintln(w, "# TYPE excel2csv_cache_hits_total counter")
+		fmt.Fprintf(w, "excel2csv_cache_hits_total %d\n", m.cacheHits)
+		fmt.Fprintln(w, "# HELP excel2csv_cache_misses_total Conversions looked up in the result cache and converted.")
+		fmt.Fprintln(w, "# TYPE excel2csv_cache_misses_total counter")
+		fmt.Fprintf(w, "excel2csv_cache_misses_total %d\n", m.cacheMisses)
+		fmt.Fprintln(w, "# HELP excel2csv_cache_entries Results in the result cache.")
+		fmt.Fprintln(w, "# TYPE excel2csv_cache_entries gauge")
+		fmt.Fprintf(w, "excel2csv_cache_entries %d\n", entries)
+		fmt.Fprintln(w, "# HELP excel2csv_cache_bytes Bytes of the results in the result cache.")
+		fmt.Fprintln(w, "# TYPE excel2csv_cache_bytes gauge")
+		fmt.Fprintf(w, "excel2csv_cache_bytes %d\n", size)
+	}
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.write(w)
+}
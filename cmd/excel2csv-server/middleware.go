@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// allowedOrigins reads the comma-separated ALLOWED_ORIGINS environment variable.
+// "*" allows any origin, an empty list disables CORS.
+func allowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// corsMiddleware adds CORS headers for allowed origins and answers preflight requests.
+// It wraps the whole router, because mux rejects OPTIONS on POST-only routes before
+// route middleware runs.
+func corsMiddleware(origins []string, next http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+
+		if origin == "" || !(allowed["*"] || allowed[origin]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if allowed["*"] {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, X-Processed-Rows, X-Output-Bytes")
+
+		// Preflight
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIKey rejects requests without the API key in an "Authorization: Bearer <key>"
+// or "X-API-Key" header. An empty key disables authentication.
+func requireAPIKey(apiKey string, next http.HandlerFunc) http.HandlerFunc {
+	if apiKey == "" {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = strings.TrimSpace(bearer)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// limitConcurrency lets at most limit requests run at once. Requests over the limit
+// get 429 right away instead of queueing, so a burst of uploads can't pile up
+// LibreOffice processes.
+func limitConcurrency(limit int, next http.HandlerFunc) http.HandlerFunc {
+	slots := make(chan struct{}, limit)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next(w, r)
+		default:
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Too many concurrent conversions, retry later", http.StatusTooManyRequests)
+		}
+	}
+}
+
+// withTimeout cancels the request context after timeout, which kills a running conversion
+func withTimeout(timeout time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// trackHandlers counts running requests in handlers, so shutdown can wait for
+// requests that Server.Close cut off to clean up
+func trackHandlers(handlers *sync.WaitGroup, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/oxyii/excel2csv"
+)
+
+// openAPIHandler serves an OpenAPI 3 description of the API. The schemas are generated
+// from the request and response structs, so they can't drift from what the handlers read.
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(openAPIDocument())
+}
+
+// openAPIDocument builds the OpenAPI document
+func openAPIDocument() map[string]any {
+	formProperties := map[string]any{
+		"file": map[string]any{
+			"type":        "string",
+			"format":      "binary",
+			"description": "Spreadsheet to convert: " + strings.Join(supportedExtensions(), ", ") + ", at most 50MB",
+		},
+		"source_url": map[string]any{
+			"type":        "string",
+			"format":      "uri",
+			"description": "Download the spreadsheet from this http or https URL instead of uploading a file",
+		},
+		"config": map[string]any{
+			"type":        "string",
+			"description": "All options as a ConvertRequest JSON object, form fields override it",
+		},
+	}
+	for name, schema := range structProperties(reflect.TypeFor[ConvertRequest]()) {
+		formProperties[name] = schema
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Excel2CSV API Server",
+			"version": excel2csv.Version(),
+		},
+		"paths": map[string]any{
+			"/health": map[string]any{
+				"get": map[string]any{
+					"summary": "Health check",
+					"parameters": []any{
+						map[string]any{
+							"name":        "deep",
+							"in":          "query",
+							"description": "Also convert a test workbook end to end, the result is reused for 30 seconds",
+							"schema":      map[string]any{"type": "boolean"},
+						},
+					},
+					"responses": map[string]any{
+						"200": jsonResponse("LibreOffice is available, and converts with deep=true", "HealthResponse"),
+						"400": textResponse("Invalid deep parameter"),
+						"503": jsonResponse("LibreOffice is not available, or fails to convert with deep=true", "HealthResponse"),
+					},
+				},
+			},
+			"/convert": map[string]any{
+				"post": map[string]any{
+					"summary":     "Convert Excel to CSV",
+					"description": "Converts one sheet to CSV, or all sheets to a ZIP archive of CSV files. Send either file or source_url. Authentication is only required when the server has an API_KEY.",
+					"security":    []any{map[string]any{"apiKey": []string{}}, map[string]any{"bearer": []string{}}, map[string]any{}},
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"multipart/form-data": map[string]any{
+								"schema": map[string]any{
+									"type":       "object",
+									"properties": formProperties,
+								},
+							},
+						},
+					},
+					"responses": convertResponses(),
+				},
+			},
+			"/info": map[string]any{
+				"get": map[string]any{
+					"summary": "API information",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Server name, version, endpoints and features",
+							"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"ConvertRequest":  structSchema(reflect.TypeFor[ConvertRequest]()),
+				"ConvertResponse": structSchema(reflect.TypeFor[ConvertResponse]()),
+				"HealthResponse":  structSchema(reflect.TypeFor[HealthResponse]()),
+			},
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// convertResponses describes the responses of /convert, including a response
+// for each status of conversionErrors
+func convertResponses() map[string]any {
+	responses := map[string]any{
+		"200": map[string]any{
+			"description": "Converted CSV, or a ZIP archive in all sheets mode. Failures without a known code are reported as ConvertResponse JSON.",
+			"headers": map[string]any{
+				"X-Processed-Rows": map[string]any{"description": "Rows written", "schema": map[string]any{"type": "integer"}},
+				"X-Output-Bytes":   map[string]any{"description": "Bytes written", "schema": map[string]any{"type": "integer"}},
+			},
+			"content": map[string]any{
+				"text/csv":        map[string]any{"schema": map[string]any{"type": "string"}},
+				"application/zip": map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+			},
+		},
+		"400": textResponse("Missing file, unsupported format or invalid option"),
+		"401": textResponse("Missing or wrong API key"),
+		"403": textResponse("source_url host or address is not allowed"),
+		"413": textResponse("File larger than 50MB"),
+		"429": textResponse("Too many conversions in progress, retry after the Retry-After seconds"),
+		"502": textResponse("source_url download failed"),
+		"504": textResponse("source_url download timed out"),
+	}
+
+	codes := map[int][]string{}
+	for _, known := range conversionErrors {
+		codes[known.status] = append(codes[known.status], known.code)
+	}
+	for status, statusCodes := range codes {
+		response := jsonResponse("Conversion failed, code "+strings.Join(statusCodes, " or "), "ConvertResponse")
+		// Statuses also used for plain text errors, like 413, describe both bodies
+		if existing, ok := responses[strconv.Itoa(status)].(map[string]any); ok {
+			response["description"] = existing["description"].(string) + "; " + response["description"].(string)
+			maps.Copy(response["content"].(map[string]any), existing["content"].(map[string]any))
+		}
+		responses[strconv.Itoa(status)] = response
+	}
+	return responses
+}
+
+// jsonResponse describes a response with a JSON body of a component schema
+func jsonResponse(description, schema string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/" + schema},
+			},
+		},
+	}
+}
+
+// textResponse describes a response with a plain text error message
+func textResponse(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"text/plain": map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+}
+
+// structSchema returns the object schema of a struct, fields without omitempty are required
+func structSchema(t reflect.Type) map[string]any {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": structProperties(t),
+	}
+
+	var required []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" && !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// structProperties returns the schemas of the JSON fields of a struct,
+// described by their doc tags
+func structProperties(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property := typeSchema(field.Type)
+		if doc := field.Tag.Get("doc"); doc != "" {
+			property["description"] = doc
+		}
+		properties[name] = property
+	}
+	return properties
+}
+
+// typeSchema returns the schema of a field type, pointers are optional values
+func typeSchema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// supportedExtensions returns the accepted upload extensions in a stable order
+func supportedExtensions() []string {
+	extensions := make([]string, 0, len(uploadFormats))
+	for ext := range uploadFormats {
+		extensions = append(extensions, ext)
+	}
+	slices.Sort(extensions)
+	return extensions
+}
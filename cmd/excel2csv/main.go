@@ -1,67 +1,199 @@
 package main
 
 import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/oxyii/excel2csv"
+	"github.com/oxyii/excel2csv/s3"
 )
 
+// multiFlag collects the values of a flag that may be repeated
+type multiFlag []string
+
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *multiFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 func main() {
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run converts as the command line arguments say, the temp files it creates are
+// removed before it returns
+func run(args []string) error {
+	flags := flag.NewFlagSet("excel2csv", flag.ExitOnError)
+	var filterFlags multiFlag
+	flags.Var(&filterFlags, "filter", "Keep only rows matching a condition, e.g. \"Status=Active\" (repeatable)")
+	var libreOfficeArgs multiFlag
+	flags.Var(&libreOfficeArgs, "lo-arg", "Extra LibreOffice argument, e.g. \"--infilter=Calc MS Excel 2007 XML\" (repeatable)")
+	var sheetOptions multiFlag
+	flags.Var(&sheetOptions, "sheet-option", "Per-sheet settings in all sheets mode, e.g. \"Prices EU:separator=semicolon,locale=eu\" (repeatable)")
+
 	var (
-		inputFile     = flag.String("input", "", "Path to input Excel file (.xls, .xlsx, .ods)")
-		outputFile    = flag.String("output", "", "Path to output CSV file (optional)")
-		separatorFlag = flag.String("separator", ",", "CSV separator: ',' (comma), ';' (semicolon), 'tab' (tab)")
-		startRowFlag  = flag.Int("start-row", -1, "Force data start from specific row (0-based), -1 for auto-detection")
-		sheetName     = flag.String("sheet-name", "", "Convert specific sheet by name")
-		sheetIndex    = flag.Int("sheet-index", -1, "Convert specific sheet by index (0-based), -1 for first sheet")
-		listSheets    = flag.Bool("list-sheets", false, "List all sheets in the Excel file and exit")
-		allSheets     = flag.Bool("all-sheets", false, "Convert all sheets to separate CSV files")
-		helpFlag      = flag.Bool("help", false, "Show help")
+		inputFile     = flags.String("input", "", "Path or s3://bucket/key URL of input Excel file (.xls, .xlsx, .xlsm, .xlsb, .ods), or CSV/TSV to tidy up")
+		maxEntryMB    = flags.Int64("max-entry-mb", excel2csv.DefaultMaxArchiveEntrySize>>20, "Largest file extracted from a .zip input, in MB")
+		forceFormat   = flags.String("force-format", "", "Read the input as this format whatever its extension: xlsx, xlsm, xlsb, xls, ods, csv or tsv")
+		password      = flags.String("password", "", "Password of an encrypted xlsx, xlsm or xlsb workbook")
+		outputFile    = flags.String("output", "", "Path or s3://bucket/key URL of output CSV file (optional)")
+		separatorFlag = flags.String("separator", ",", "CSV separator: ',' (comma), ';' (semicolon), 'tab' (tab), 'pipe' (|), 'space' or any single character")
+		lineEnding    = flags.String("line-ending", "lf", "Line ending of output rows: 'lf' or 'crlf'")
+		cellRange     = flags.String("range", "", "Convert only this cell range in A1 notation, e.g. 'B3:F120', skipping table detection")
+		namedRange    = flags.String("named-range", "", "Convert only this named range or table of an xlsx workbook, skipping table detection")
+		startRowFlag  = flags.Int("start-row", -1, "Force data start from specific row (0-based), -1 for auto-detection")
+		sheetName     = flags.String("sheet-name", "", "Convert specific sheet by name")
+		sheetIndex    = flags.Int("sheet-index", -1, "Convert specific sheet by index (0-based), -1 for first sheet")
+		listSheets    = flags.Bool("list-sheets", false, "List all sheets in the Excel file and exit")
+		validateFlag  = flags.Bool("validate", false, "Report detected tables for every sheet without writing output")
+		suggestFlag   = flags.Bool("suggest", false, "Run table detection on every sheet and print suggested commands, e.g. with -start-row, without writing output")
+		diagnostics   = flags.String("diagnostics", "", "Write a JSON file explaining table detection row by row, e.g. detection.json")
+		countFlag     = flags.Bool("count", false, "Report the row count of every sheet (or of -sheet-pattern matches) without writing output; sheets are still exported by LibreOffice")
+		allSheets     = flags.Bool("all-sheets", false, "Convert all sheets to separate CSV files")
+		sheetPattern  = flags.String("sheet-pattern", "", "Convert all sheets whose name matches this regular expression, e.g. \"^2024-\"")
+		multiTables   = flags.Bool("multiple-tables", false, "Write every table of a sheet (separated by blank rows) to its own file, out_table_1.csv, out_table_2.csv, ...")
+		reportFile    = flags.String("report", "", "Write per-sheet conversion results as JSON to this file (with -all-sheets)")
+		columnsFlag   = flags.String("columns", "", "Comma-separated header names of columns to output, e.g. \"Name,Email,Total\"")
+		renameFlag    = flags.String("rename", "", "Rename output headers: comma-separated Old=new pairs, e.g. \"Total Amount=total\"")
+		headerDedupe  = flags.String("header-dedupe", "keep", "Duplicate and blank header cells: 'keep', 'suffix' (Name_2, column_3), 'error' or 'drop-blank'")
+		fillDownFlag  = flags.String("fill-down", "", "Fill empty cells left by merged cells from above: comma-separated column indexes (0-based) or 'all'")
+		numberLocale  = flags.String("number-locale", "en", "Number format of cells: 'en' (1,234.56) or 'eu'/'de' (1.234,56)")
+		detectionFlag = flags.String("detection", "improved", "Table detection strategy: 'improved', 'structural' or 'none' (keep all rows)")
+		expectHeaders = flags.String("expect-headers", "", "Comma-separated header keywords; with 'improved' detection the row matching most of them is the header, e.g. \"Name,Email\"")
+		maxHeaderScan = flags.Int("max-header-scan", 50, "Rows scanned for the header row with 'improved' detection, 0 for the whole sheet")
+		syntheticHdrs = flags.Bool("synthetic-headers", false, "Add a header of column letters (A, B, C, ...) when the table starts with data instead of a header row")
+		dateFormat    = flags.String("date-format", "", "Rewrite date cells with a Go layout, e.g. '2006-01-02'")
+		sanitize      = flags.Bool("sanitize-formulas", false, "Prefix cells starting with =, +, -, @ with a quote to prevent CSV injection")
+		emptyValue    = flags.String("empty-value", "", "Write empty data cells as this value, e.g. '\\N' for PostgreSQL COPY")
+		transpose     = flags.Bool("transpose", false, "Swap rows and columns of the detected table")
+		sheetColumn   = flags.String("sheet-column", "", "Prepend a column with this header holding the sheet name")
+		includeEmpty  = flags.Bool("include-empty", false, "With -all-sheets, also write sheets without data rows")
+		nameTemplate  = flags.String("name-template", "", "With -all-sheets, name files with this Go template of {{.Base}}, {{.SheetIndex}}, {{.SheetNumber}}, {{.SheetName}} and {{.Ext}}, e.g. 'sales-{{.SheetName}}{{.Ext}}'")
+		ddl           = flags.String("ddl", "", "Also write a CREATE TABLE statement to a .sql file next to the output: 'postgres', 'mysql' or 'sqlite'")
+		metadata      = flags.Bool("metadata-comment", false, "Start the output with a '# source=... sheet=... rows=... generated=...' comment line (not strict CSV)")
+		commentPrefix = flags.String("comment-prefix", "#", "Prefix of the -metadata-comment line")
+		splitRows     = flags.Int("split-rows", 0, "Split the output into out.part001.csv, out.part002.csv, ... with at most this many data rows each, 0 for one file")
+		noClobber     = flags.Bool("no-clobber", false, "Fail instead of overwriting existing output files")
+		trimColumns   = flags.Bool("trim-columns", false, "Drop empty columns right of the last column holding data")
+		collapse      = flags.Bool("collapse-spaces", false, "Collapse runs of spaces in cells to one and trim cells")
+		noHeader      = flags.Bool("no-header", false, "Write only data rows, without the detected header row")
+		keepFormat    = flags.Bool("keep-formatting", true, "Export numbers as displayed (15%, $1,000.00), -keep-formatting=false for raw values")
+		formulas      = flags.Bool("formulas", false, "Export formula text (e.g. =A1+B1) instead of calculated values")
+		helpFlag      = flags.Bool("help", false, "Show help")
 	)
 
-	flag.Parse()
+	_ = flags.Parse(args)
 
 	if *helpFlag {
 		showHelp()
-		return
+		return nil
 	}
 
 	if *inputFile == "" {
-		fmt.Println("Error: input file must be specified")
 		showHelp()
-		os.Exit(1)
+		return errors.New("input file must be specified")
+	}
+
+	// Ctrl-C stops the conversion and kills LibreOffice, a directory conversion
+	// keeps the files converted until then in its manifest
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Create S3 client if input or output is an s3:// URL
+	var s3Client *awss3.Client
+	if s3.IsURL(*inputFile) || s3.IsURL(*outputFile) {
+		client, err := s3.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to create S3 client: %w", err)
+		}
+		s3Client = client
+	}
+
+	// Download S3 input to a local temp file, local input is used as is
+	inputPath := *inputFile
+	if s3.IsURL(*inputFile) {
+		bucket, key, err := s3.ParseURL(*inputFile)
+		if err != nil {
+			return fmt.Errorf("Invalid input: %w", err)
+		}
+		inputPath, err = s3.Download(ctx, s3Client, bucket, key, "")
+		if err != nil {
+			return fmt.Errorf("Failed to download input: %w", err)
+		}
+		defer func() { _ = os.Remove(inputPath) }()
+	} else if _, err := os.Stat(*inputFile); os.IsNotExist(err) {
+		return fmt.Errorf("Input file does not exist: %s", *inputFile)
 	}
 
-	// Check if input file exists
-	if _, err := os.Stat(*inputFile); os.IsNotExist(err) {
-		log.Fatalf("Input file does not exist: %s", *inputFile)
+	// A ZIP input holds several spreadsheets, converted to a directory or a ZIP archive
+	archive := strings.EqualFold(filepath.Ext(*inputFile), ".zip")
+
+	// A directory input is converted file by file, resuming after an interruption
+	directory := false
+	if info, err := os.Stat(inputPath); err == nil && info.IsDir() {
+		directory = true
 	}
 
 	// Create converter
 	converter := excel2csv.NewExcelConverter()
+	converter.ForceFormat = *forceFormat
+	converter.Password = *password
+	if *maxEntryMB <= 0 {
+		return fmt.Errorf("Invalid -max-entry-mb: %d", *maxEntryMB)
+	}
+	converter.MaxArchiveEntrySize = *maxEntryMB << 20
+	converter.ExtraLibreOfficeArgs = libreOfficeArgs
 
 	// Handle list sheets command
 	if *listSheets {
-		sheets, err := converter.ListSheets(*inputFile)
+		sheets, err := converter.ListSheets(inputPath)
 		if err != nil {
-			log.Fatalf("Failed to list sheets: %v", err)
+			return fmt.Errorf("Failed to list sheets: %w", err)
 		}
 
 		fmt.Printf("Sheets in file %s:\n", *inputFile)
 		for _, sheet := range sheets {
 			fmt.Printf("  %d: %s\n", sheet.Index, sheet.Name)
 		}
-		return
+		return nil
+	}
+
+	// Handle count command
+	if *countFlag {
+		converter.SheetPattern = *sheetPattern
+		counts, err := converter.CountRowsContext(ctx, inputPath)
+		if err != nil {
+			return fmt.Errorf("Failed to count rows: %w", err)
+		}
+		printRowCounts(*inputFile, counts)
+		return nil
 	}
 
 	// Set sheet selection
 	if *sheetName != "" && *sheetIndex >= 0 {
-		log.Fatalf("Cannot specify both -sheet-name and -sheet-index")
+		return errors.New("Cannot specify both -sheet-name and -sheet-index")
+	}
+	if *sheetPattern != "" && (*sheetName != "" || *sheetIndex >= 0) {
+		return errors.New("Cannot combine -sheet-pattern with -sheet-name or -sheet-index")
 	}
 
 	if *sheetName != "" {
@@ -70,15 +202,34 @@ func main() {
 		converter.SheetIndex = sheetIndex
 	}
 
-	// Set convert all sheets mode
+	// Set convert all sheets mode, a sheet pattern converts the matching sheets the same way
 	converter.AllSheetsMode = *allSheets
+	converter.SheetPattern = *sheetPattern
+	if *sheetPattern != "" {
+		*allSheets = true
+	}
+	converter.DetectMultipleTables = *multiTables
+	if len(sheetOptions) > 0 {
+		if !*allSheets {
+			return errors.New("-sheet-option requires -all-sheets or -sheet-pattern")
+		}
+		converter.SheetOverrides = make(map[string]excel2csv.SheetOptions)
+		for _, value := range sheetOptions {
+			if err := parseSheetOverride(value, converter.SheetOverrides); err != nil {
+				return err
+			}
+		}
+	}
 
 	// Generate output file name if not specified
 	if *outputFile == "" {
-		if *allSheets {
+		if directory {
+			// Next to the input files, the manifest keeps outputs from being converted again
+			*outputFile = *inputFile
+		} else if *allSheets || archive {
 			// For all sheets mode, use input directory
 			*outputFile = filepath.Dir(*inputFile)
-			if *outputFile == "" {
+			if *outputFile == "" || s3.IsURL(*inputFile) {
 				*outputFile = "."
 			}
 		} else {
@@ -91,33 +242,198 @@ func main() {
 			} else {
 				*outputFile = baseName + ".csv"
 			}
+			// Don't overwrite a CSV input
+			if *outputFile == *inputFile {
+				*outputFile = baseName + "_clean.csv"
+			}
 		}
 	}
 
+	// Set column selection
+	if *columnsFlag != "" {
+		for _, name := range strings.Split(*columnsFlag, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				converter.SelectColumns = append(converter.SelectColumns, name)
+			}
+		}
+	}
+
+	// Set header renames
+	if *renameFlag != "" {
+		converter.HeaderRename = make(map[string]string)
+		for _, pair := range strings.Split(*renameFlag, ",") {
+			from, to, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(from) == "" {
+				return fmt.Errorf("Invalid rename: %s", pair)
+			}
+			converter.HeaderRename[strings.TrimSpace(from)] = strings.TrimSpace(to)
+		}
+	}
+
+	// Set duplicate and blank header handling
+	switch *headerDedupe {
+	case "keep":
+		converter.HeaderDedupeMode = excel2csv.HeaderDedupeKeep
+	case "suffix":
+		converter.HeaderDedupeMode = excel2csv.HeaderDedupeSuffix
+	case "error":
+		converter.HeaderDedupeMode = excel2csv.HeaderDedupeError
+	case "drop-blank":
+		converter.HeaderDedupeMode = excel2csv.HeaderDedupeDropBlank
+	default:
+		return fmt.Errorf("Invalid header dedupe mode: %s", *headerDedupe)
+	}
+
+	// Set row filters
+	for _, expr := range filterFlags {
+		filter, err := excel2csv.ParseRowFilter(expr)
+		if err != nil {
+			return fmt.Errorf("Invalid filter: %w", err)
+		}
+		converter.RowFilters = append(converter.RowFilters, filter)
+	}
+
+	// Set fill down of merged cells
+	if *fillDownFlag != "" {
+		converter.FillMergedDown = true
+		if *fillDownFlag != "all" {
+			for _, col := range strings.Split(*fillDownFlag, ",") {
+				index, err := strconv.Atoi(strings.TrimSpace(col))
+				if err != nil || index < 0 {
+					return fmt.Errorf("Invalid fill down column: %s", col)
+				}
+				converter.FillColumns = append(converter.FillColumns, index)
+			}
+		}
+	}
+
+	// Set table detection strategy
+	switch *detectionFlag {
+	case "improved":
+		converter.DetectionStrategy = excel2csv.StrategyImproved
+	case "structural":
+		converter.DetectionStrategy = excel2csv.StrategyStructural
+	case "none":
+		converter.DetectionStrategy = excel2csv.StrategyNone
+	default:
+		return fmt.Errorf("Invalid detection strategy: %s", *detectionFlag)
+	}
+	if *expectHeaders != "" {
+		for _, keyword := range strings.Split(*expectHeaders, ",") {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				converter.ExpectedHeaders = append(converter.ExpectedHeaders, keyword)
+			}
+		}
+	}
+	if *maxHeaderScan < 0 {
+		return fmt.Errorf("Invalid -max-header-scan: %d", *maxHeaderScan)
+	}
+	converter.MaxHeaderScanRows = *maxHeaderScan
+	converter.SyntheticHeaders = *syntheticHdrs
+	converter.DiagnosticsPath = *diagnostics
+
+	// Set number locale
+	switch strings.ToLower(*numberLocale) {
+	case "en":
+		converter.NumberLocale = excel2csv.NumberLocaleEN
+	case "eu", "de", "fr":
+		converter.NumberLocale = excel2csv.NumberLocaleEU
+	default:
+		return fmt.Errorf("Invalid number locale: %s", *numberLocale)
+	}
+
+	if *formulas {
+		converter.FormulaMode = excel2csv.FormulaText
+	}
+
+	converter.OmitHeader = *noHeader
+	converter.TrimTrailingEmptyColumns = *trimColumns
+	converter.CollapseSpaces = *collapse
+	if *splitRows < 0 {
+		return fmt.Errorf("Invalid -split-rows: %d", *splitRows)
+	}
+	if *splitRows > 0 && *multiTables {
+		return errors.New("Cannot combine -split-rows with -multiple-tables")
+	}
+	converter.SplitRows = *splitRows
+	converter.CellRange = *cellRange
+	converter.NamedRange = *namedRange
+	converter.IncludeMetadataComment = *metadata
+	converter.MetadataCommentPrefix = *commentPrefix
+	converter.DateFormat = *dateFormat
+	converter.SanitizeFormulas = *sanitize
+	converter.EmptyCellValue = *emptyValue
+	converter.Transpose = *transpose
+	converter.SheetNameColumn = *sheetColumn
+	converter.IncludeEmptySheets = *includeEmpty
+	converter.FilenameTemplate = *nameTemplate
+	converter.EmitDDL = strings.ToLower(*ddl)
+	// An S3 output is converted into a temp file created beforehand
+	converter.NoClobber = *noClobber && !s3.IsURL(*outputFile)
+	converter.RawValues = !*keepFormat
+
 	// Set forced data start row if specified
 	if *startRowFlag >= 0 {
 		converter.ForceDataStartRow = startRowFlag
 	}
 
 	// Set CSV separator
-	switch *separatorFlag {
-	case ",":
-		converter.CSVSeparator = ','
-	case ";":
-		converter.CSVSeparator = ';'
-	case "tab":
-		converter.CSVSeparator = '\t'
+	separator, err := excel2csv.ParseSeparator(*separatorFlag)
+	if err != nil {
+		return err
+	}
+	converter.CSVSeparator = separator
+	if separator == '\t' {
+		// Tab output escapes embedded tabs and line breaks instead of quoting
+		converter.OutputFormat = excel2csv.FormatTSV
+	}
+
+	// Set line ending
+	switch strings.ToLower(*lineEnding) {
+	case "lf":
+		converter.LineEnding = excel2csv.LineEndingLF
+	case "crlf":
+		converter.LineEnding = excel2csv.LineEndingCRLF
 	default:
-		if len(*separatorFlag) == 1 {
-			converter.CSVSeparator = rune((*separatorFlag)[0])
-		} else {
-			log.Fatalf("Invalid separator: %s", *separatorFlag)
+		return fmt.Errorf("Invalid line ending: %s", *lineEnding)
+	}
+
+	// Handle validate command, with every option that changes what would be converted set
+	if *validateFlag {
+		report, err := converter.Validate(inputPath)
+		if err != nil {
+			return fmt.Errorf("Validation failed: %w", err)
+		}
+		printValidationReport(*inputFile, report)
+		return nil
+	}
+
+	// Handle suggest command, the suggested commands keep the detection options given
+	if *suggestFlag {
+		report, err := converter.Validate(inputPath)
+		if err != nil {
+			return fmt.Errorf("Detection failed: %w", err)
 		}
+		var detectionArgs []string
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "detection", "expect-headers", "max-header-scan", "number-locale":
+				detectionArgs = append(detectionArgs, "-"+f.Name, f.Value.String())
+			}
+		})
+		printSuggestions(*inputFile, detectionArgs, report)
+		return nil
 	}
 
 	// Print configuration
 	fmt.Printf("Converting file: %s\n", *inputFile)
-	if *allSheets {
+	if directory {
+		fmt.Printf("Converting directory files to: %s\n", *outputFile)
+	} else if archive {
+		fmt.Printf("Converting archive entries to: %s\n", *outputFile)
+	} else if *allSheets && strings.EqualFold(filepath.Ext(*outputFile), ".zip") {
+		fmt.Printf("Converting all sheets to ZIP archive: %s\n", *outputFile)
+	} else if *allSheets {
 		fmt.Printf("Converting all sheets to directory: %s\n", *outputFile)
 	} else {
 		fmt.Printf("Output file: %s\n", *outputFile)
@@ -131,22 +447,151 @@ func main() {
 	}
 	fmt.Printf("CSV separator: %s\n", getSeparatorName(*separatorFlag))
 
+	// Convert to a local temp file first when the output is an s3:// URL
+	outputPath := *outputFile
+	if s3.IsURL(*outputFile) {
+		if *allSheets || archive || directory || *splitRows > 0 || *multiTables {
+			return errors.New("Converting all sheets, an archive, a directory, split output or multiple tables to S3 is not supported")
+		}
+		tempFile, err := os.CreateTemp("", "excel2csv_*.csv")
+		if err != nil {
+			return fmt.Errorf("Failed to create temp file: %w", err)
+		}
+		outputPath = tempFile.Name()
+		_ = tempFile.Close()
+		defer func() { _ = os.Remove(outputPath) }()
+	}
+
+	if *reportFile != "" && (!*allSheets || archive || directory || strings.EqualFold(filepath.Ext(*outputFile), ".zip")) {
+		return errors.New("-report requires -all-sheets with an output directory")
+	}
+
 	// Convert file
-	err := converter.ConvertFile(*inputFile, *outputFile)
-	if err != nil {
-		log.Fatalf("Conversion error: %v", err)
+	if directory {
+		if _, err := converter.ConvertDirectoryContext(ctx, inputPath, outputPath); err != nil {
+			return fmt.Errorf("Conversion error: %w", err)
+		}
+	} else if archive {
+		if err := convertArchive(ctx, converter, inputPath, outputPath); err != nil {
+			return fmt.Errorf("Conversion error: %w", err)
+		}
+	} else if *reportFile != "" {
+		// Same directory ConvertFile uses in all sheets mode
+		results, err := converter.ConvertAllSheetsWithReportContext(ctx, inputPath, filepath.Dir(outputPath))
+		if err != nil {
+			return fmt.Errorf("Conversion error: %w", err)
+		}
+		if err := writeReport(*reportFile, results); err != nil {
+			return fmt.Errorf("Failed to write report: %w", err)
+		}
+	} else {
+		stats, err := converter.ConvertFileStatsContext(ctx, inputPath, outputPath)
+		if err != nil {
+			return fmt.Errorf("Conversion error: %w", err)
+		}
+		// All sheets mode already reports the sheets it skips
+		if !*allSheets && len(stats.EmptySheets) > 0 {
+			fmt.Printf("Warning: sheet %s has no data rows\n", stats.EmptySheets[0])
+		}
+		for _, part := range stats.OutputFiles {
+			fmt.Printf("Wrote %s\n", part)
+		}
 	}
 
-	if *allSheets {
+	// Upload the result when the output is an s3:// URL
+	if s3.IsURL(*outputFile) {
+		bucket, key, err := s3.ParseURL(*outputFile)
+		if err != nil {
+			return fmt.Errorf("Invalid output: %w", err)
+		}
+		if err := s3.Upload(ctx, s3Client, outputPath, bucket, key); err != nil {
+			return fmt.Errorf("Failed to upload output: %w", err)
+		}
+	}
+
+	if directory {
+		fmt.Println("Directory converted successfully!")
+	} else if archive {
+		fmt.Println("Archive converted successfully!")
+	} else if *allSheets {
 		fmt.Println("All sheets converted successfully!")
 	} else {
 		fmt.Println("Conversion completed successfully!")
 	}
+	return nil
+}
+
+// convertArchive converts the spreadsheets of a ZIP input to the outputPath directory,
+// or, for a .zip outputPath, to a ZIP archive holding the converted files
+func convertArchive(ctx context.Context, converter *excel2csv.ExcelConverter, inputPath, outputPath string) error {
+	if !strings.EqualFold(filepath.Ext(outputPath), ".zip") {
+		_, err := converter.ConvertArchiveContext(ctx, inputPath, outputPath)
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "excel2csv_archive_")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	// Failed entries are reported, the others still go into the archive
+	_, convertErr := converter.ConvertArchiveContext(ctx, inputPath, tempDir)
+	if err := zipDirectory(converter, tempDir, outputPath); err != nil {
+		return err
+	}
+	return convertErr
+}
+
+// zipDirectory writes the files of dir to a ZIP archive at outputPath, which is
+// created like the converter's outputs
+func zipDirectory(converter *excel2csv.ExcelConverter, dir, outputPath string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	dstFile, err := converter.CreateOutput(outputPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dstFile.Close() }()
+
+	zipWriter := zip.NewWriter(dstFile)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		writer, err := zipWriter.Create(entry.Name())
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return err
+	}
+	return dstFile.Close()
+}
+
+// writeReport saves the per-sheet conversion results as indented JSON
+func writeReport(path string, results []excel2csv.SheetResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
 }
 
 func showHelp() {
 	fmt.Println("Excel to CSV Converter (LibreOffice-based)")
-	fmt.Println("Convert Excel files (.xls/.xlsx/.ods) to CSV with multi-sheet support")
+	fmt.Println("Convert Excel files (.xls/.xlsx/.xlsm/.xlsb/.ods) to CSV with multi-sheet support")
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  go run . -input <excel_file_path> [options]")
@@ -155,23 +600,119 @@ func showHelp() {
 	fmt.Println("  -help")
 	fmt.Println("        Show help")
 	fmt.Println("  -input string")
-	fmt.Println("        Path to input Excel file (.xls, .xlsx, or .ods)")
+	fmt.Println("        Path or s3://bucket/key URL of input Excel file (.xls, .xlsx, .xlsm, .xlsb, or .ods),")
+	fmt.Println("        or a .csv/.tsv file to run through detection and cleaning, or a .zip or directory of such files")
+	fmt.Println("  -max-entry-mb int")
+	fmt.Println("        Largest file extracted from a .zip input, in MB; larger files fail (default 100)")
+	fmt.Println("  -force-format string")
+	fmt.Println("        Read the input as this format whatever its extension: xlsx, xlsm, xlsb, xls, ods, csv or tsv")
+	fmt.Println("  -password string")
+	fmt.Println("        Password of an encrypted xlsx, xlsm or xlsb workbook")
 	fmt.Println("  -output string")
-	fmt.Println("        Path to output CSV file (optional)")
+	fmt.Println("        Path or s3://bucket/key URL of output CSV file (optional)")
 	fmt.Println("  -separator string")
-	fmt.Println("        CSV separator: ',' (comma), ';' (semicolon), 'tab' (tab) (default \",\")")
+	fmt.Println("        CSV separator: ',' (comma), ';' (semicolon), 'tab' (tab), 'pipe' (|), 'space' or any single character (default \",\")")
+	fmt.Println("  -line-ending string")
+	fmt.Println("        Line ending of output rows: 'lf' or 'crlf' (default \"lf\")")
+	fmt.Println("  -range string")
+	fmt.Println("        Convert only this cell range in A1 notation, e.g. 'B3:F120', skipping table detection")
+	fmt.Println("  -named-range string")
+	fmt.Println("        Convert only this named range or table of an xlsx or xlsm workbook, skipping table detection")
 	fmt.Println("  -start-row int")
 	fmt.Println("        Force data start from specific row (0-based), -1 for auto-detection (default -1)")
+	fmt.Println("  -columns string")
+	fmt.Println("        Comma-separated header names of columns to output, e.g. \"Name,Email,Total\"")
+	fmt.Println("  -rename string")
+	fmt.Println("        Rename output headers: comma-separated Old=new pairs, e.g. \"Total Amount=total\"")
+	fmt.Println("  -header-dedupe string")
+	fmt.Println("        Duplicate and blank header cells: 'keep' as they are, 'suffix' to number repeats (Name_2)")
+	fmt.Println("        and name blanks by position (column_3), 'error' to fail, or 'drop-blank' to drop columns")
+	fmt.Println("        with a blank header and number repeats (default \"keep\")")
+	fmt.Println("  -filter string")
+	fmt.Println("        Keep only rows matching a condition (repeatable): Column=Value, Column!=Value,")
+	fmt.Println("        Column~Text (contains), Column>Number, Column>=Number, Column<Number, Column<=Number")
+	fmt.Println("  -fill-down string")
+	fmt.Println("        Fill empty cells left by merged cells from above: comma-separated column indexes (0-based) or 'all'")
+	fmt.Println("  -detection string")
+	fmt.Println("        Table detection strategy: 'improved', 'structural' or 'none' (keep all rows) (default \"improved\")")
+	fmt.Println("  -expect-headers string")
+	fmt.Println("        Comma-separated header keywords; with 'improved' detection the row matching most of them is the header")
+	fmt.Println("  -max-header-scan int")
+	fmt.Println("        Rows scanned for the header row with 'improved' detection, 0 for the whole sheet (default 50)")
+	fmt.Println("  -synthetic-headers")
+	fmt.Println("        Add a header of column letters (A, B, C, ...) when the table starts with data instead of a header row;")
+	fmt.Println("        a row picked by -expect-headers, -start-row or -range is always the header")
+	fmt.Println("  -date-format string")
+	fmt.Println("        Rewrite date cells with a Go layout, e.g. '2006-01-02' for ISO-8601 dates")
+	fmt.Println("  -sanitize-formulas")
+	fmt.Println("        Prefix cells starting with =, +, -, @ with a quote to prevent CSV injection")
+	fmt.Println("  -empty-value string")
+	fmt.Println("        Write empty data cells as this value, e.g. '\\N' for PostgreSQL COPY or 'NULL'")
+	fmt.Println("  -transpose")
+	fmt.Println("        Swap rows and columns of the detected table, for field names down the first column")
+	fmt.Println("  -sheet-column string")
+	fmt.Println("        Prepend a column with this header holding the sheet name, e.g. 'sheet'")
+	fmt.Println("  -name-template string")
+	fmt.Println("        With -all-sheets, name files with this Go template of {{.Base}}, {{.SheetIndex}} (0-based),")
+	fmt.Println("        {{.SheetNumber}} (1-based), {{.SheetName}} and {{.Ext}}, e.g. 'sales-{{.SheetName}}{{.Ext}}'")
+	fmt.Println("        (default '" + excel2csv.DefaultFilenameTemplate + "')")
+	fmt.Println("  -include-empty")
+	fmt.Println("        With -all-sheets, also write sheets without data rows (skipped by default)")
+	fmt.Println("  -ddl string")
+	fmt.Println("        Also write a CREATE TABLE statement to a .sql file next to the output: 'postgres', 'mysql' or 'sqlite'")
+	fmt.Println("  -metadata-comment")
+	fmt.Println("        Start the output with a '# source=... sheet=... rows=... generated=...' comment line (not strict CSV)")
+	fmt.Println("  -comment-prefix string")
+	fmt.Println("        Prefix of the -metadata-comment line (default \"#\")")
+	fmt.Println("  -split-rows int")
+	fmt.Println("        Split the output into out.part001.csv, out.part002.csv, ... with at most this many data rows each")
+	fmt.Println("  -no-clobber")
+	fmt.Println("        Fail instead of overwriting existing output files")
+	fmt.Println("  -trim-columns")
+	fmt.Println("        Drop empty columns right of the last column holding data")
+	fmt.Println("  -collapse-spaces")
+	fmt.Println("        Collapse runs of spaces in cells to one and trim cells")
+	fmt.Println("  -no-header")
+	fmt.Println("        Write only data rows, without the detected header row")
+	fmt.Println("  -number-locale string")
+	fmt.Println("        Number format of cells: 'en' (1,234.56) or 'eu'/'de' (1.234,56) (default \"en\")")
+	fmt.Println("  -keep-formatting")
+	fmt.Println("        Export numbers as displayed (15%, $1,000.00), -keep-formatting=false for raw values (default true)")
+	fmt.Println("  -formulas")
+	fmt.Println("        Export formula text (e.g. =A1+B1) instead of calculated values")
+	fmt.Println("  -lo-arg string")
+	fmt.Println("        Extra LibreOffice argument (repeatable), e.g. \"--infilter=Calc MS Excel 2007 XML\";")
+	fmt.Println("        --headless, --convert-to, --outdir and -env:UserInstallation are set by the converter")
 	fmt.Println()
 	fmt.Println("Sheet Selection:")
 	fmt.Println("  -list-sheets")
 	fmt.Println("        List all sheets in the Excel file and exit")
+	fmt.Println("  -suggest")
+	fmt.Println("        Run table detection on every sheet and print suggested commands, e.g. with -start-row, without writing output")
+	fmt.Println("  -diagnostics string")
+	fmt.Println("        Write a JSON file explaining table detection: every row with its non-empty and numeric cell counts,")
+	fmt.Println("        whether it is in the table and why. One file per sheet in all sheets mode (detection_sheet_2.json)")
+	fmt.Println("  -count")
+	fmt.Println("        Report the row count of every sheet (or of -sheet-pattern matches) in workbook order without writing output.")
+	fmt.Println("        Each sheet is still exported by LibreOffice, so counting takes about as long as converting")
+	fmt.Println("  -validate")
+	fmt.Println("        Report detected tables for every sheet without writing output")
 	fmt.Println("  -sheet-name string")
 	fmt.Println("        Convert specific sheet by name")
 	fmt.Println("  -sheet-index int")
 	fmt.Println("        Convert specific sheet by index (0-based), -1 for first sheet (default -1)")
 	fmt.Println("  -all-sheets")
 	fmt.Println("        Convert all sheets to separate CSV files")
+	fmt.Println("  -sheet-pattern string")
+	fmt.Println("        Convert all sheets whose name matches this regular expression, e.g. \"^2024-\"")
+	fmt.Println("  -sheet-option string")
+	fmt.Println("        Settings of one sheet in all sheets mode (repeatable), \"Sheet name:key=value,...\" with keys")
+	fmt.Println("        separator (comma, semicolon, tab, pipe, space or a character), format (csv, tsv, parquet),")
+	fmt.Println("        locale (en, eu) and date-format, e.g. \"Prices EU:separator=semicolon,locale=eu\"")
+	fmt.Println("  -multiple-tables")
+	fmt.Println("        Write every table of a sheet (separated by blank rows) to its own file, out_table_1.csv, ...")
+	fmt.Println("  -report string")
+	fmt.Println("        Write per-sheet conversion results as JSON to this file (with -all-sheets)")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  # Convert first sheet (default)")
@@ -180,6 +721,12 @@ func showHelp() {
 	fmt.Println("  # List all sheets")
 	fmt.Println("  go run . -input data.xlsx -list-sheets")
 	fmt.Println()
+	fmt.Println("  # Check what would be converted without writing files")
+	fmt.Println("  go run . -input data.xlsx -validate")
+	fmt.Println()
+	fmt.Println("  # Print suggested flags, like -start-row, for a new file")
+	fmt.Println("  go run . -input data.xlsx -suggest")
+	fmt.Println()
 	fmt.Println("  # Convert specific sheet by name")
 	fmt.Println("  go run . -input data.xlsx -sheet-name \"Sales Data\"")
 	fmt.Println()
@@ -189,15 +736,27 @@ func showHelp() {
 	fmt.Println("  # Convert all sheets to separate files")
 	fmt.Println("  go run . -input data.xlsx -all-sheets")
 	fmt.Println()
+	fmt.Println("  # Convert all sheets into a single ZIP archive")
+	fmt.Println("  go run . -input data.xlsx -all-sheets -output sheets.zip")
+	fmt.Println()
 	fmt.Println("  # Convert with custom separator")
 	fmt.Println("  go run . -input data.xlsx -sheet-name \"Report\" -separator ';'")
 	fmt.Println()
 	fmt.Println("  # Force start row and convert specific sheet")
 	fmt.Println("  go run . -input data.xlsx -sheet-index 2 -start-row 5")
 	fmt.Println()
+	fmt.Println("  # Output only selected columns, in this order")
+	fmt.Println("  go run . -input data.xlsx -columns \"Name,Email,Total\"")
+	fmt.Println()
+	fmt.Println("  # Keep only active rows with a total above 100")
+	fmt.Println("  go run . -input data.xlsx -filter \"Status=Active\" -filter \"Total>100\"")
+	fmt.Println()
+	fmt.Println("  # Convert a file stored in S3 and upload the result")
+	fmt.Println("  go run . -input s3://reports/data.xlsx -output s3://exports/data.csv")
+	fmt.Println()
 	fmt.Println("Features:")
 	fmt.Println("- 🔧 LibreOffice-powered conversion (reliable for all Excel formats)")
-	fmt.Println("- 📋 Support for .xls, .xlsx, and .ods formats")
+	fmt.Println("- 📋 Support for .xls, .xlsx, .xlsm, .xlsb, and .ods formats")
 	fmt.Println("- 📄 Multi-sheet support: select by name/index or convert all sheets")
 	fmt.Println("- ⚙️ Configurable CSV separator")
 	fmt.Println("- 🧹 Automatic cleanup of line breaks in data")
@@ -208,6 +767,40 @@ func showHelp() {
 	fmt.Println("- LibreOffice must be installed and available in PATH")
 }
 
+func printValidationReport(inputFile string, report *excel2csv.ValidationReport) {
+	fmt.Printf("Validation report for %s:\n", inputFile)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SHEET\tNAME\tTOTAL ROWS\tTABLE ROWS\tOUTPUT ROWS\tCOLUMNS\tWARNINGS")
+	for _, sheet := range report.Sheets {
+		tableRows := "-"
+		if sheet.StartRow >= 0 {
+			tableRows = fmt.Sprintf("%d-%d", sheet.StartRow+1, sheet.EndRow+1)
+		}
+		warnings := strings.Join(sheet.Warnings, "; ")
+		if warnings == "" {
+			warnings = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%d\t%d\t%s\n",
+			sheet.Index, sheet.Name, sheet.TotalRows, tableRows, sheet.Rows, sheet.Columns, warnings)
+	}
+	_ = tw.Flush()
+}
+
+func printRowCounts(inputFile string, counts []excel2csv.SheetRowCount) {
+	fmt.Printf("Row counts for %s:\n", inputFile)
+
+	total := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SHEET\tROWS")
+	for _, count := range counts {
+		fmt.Fprintf(tw, "%s\t%d\n", count.Name, count.Rows)
+		total += count.Rows
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\n", total)
+	_ = tw.Flush()
+}
+
 func getSeparatorName(sep string) string {
 	switch sep {
 	case ",":
@@ -216,6 +809,10 @@ func getSeparatorName(sep string) string {
 		return "semicolon (;)"
 	case "tab":
 		return "tab (\\t)"
+	case "pipe", "|":
+		return "pipe (|)"
+	case "space", " ":
+		return "space"
 	default:
 		return fmt.Sprintf("custom (%s)", sep)
 	}
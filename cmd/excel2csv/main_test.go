@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// runCLI runs the command line with args and returns what it printed
+func runCLI(t *testing.T, args ...string) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	err = run(args)
+	os.Stdout = stdout
+	_ = writer.Close()
+	printed := <-output
+	if err != nil {
+		t.Fatalf("run(%q) error = %v, printed:\n%s", args, err, printed)
+	}
+	return printed
+}
+
+// writeInput writes a CSV input with a title above and a total below the table
+func writeInput(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report.csv")
+	content := "Quarterly report,,,,\n" +
+		"Name,City,Qty,Price,Note\n" +
+		"a,Köln,1,\"1.234,5\",x\n" +
+		"b,Wien,2,\"3,5\",\n" +
+		"c,Graz,,\"7,25\",z\n" +
+		",,3,,\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateAppliesOptions(t *testing.T) {
+	input := writeInput(t)
+
+	// Columns of the report: sheet, name, total rows, table rows, output rows, columns, warnings
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"defaults", nil, "0 Sheet1 6 2-5 4 5 -"},
+		{"start row", []string{"-start-row", "2"}, "0 Sheet1 6 3-5 3 5 -"},
+		{"no detection", []string{"-detection", "none"}, "0 Sheet1 6 1-6 6 5 -"},
+		{"range", []string{"-range", "B2:D4"}, "0 Sheet1 6 2-4 3 3 -"},
+		{"columns", []string{"-columns", "Name,Qty"}, "0 Sheet1 6 2-5 4 2 -"},
+		{"filter", []string{"-filter", "Qty>1"}, "0 Sheet1 6 2-5 2 5 -"},
+		{"transpose", []string{"-transpose"}, "0 Sheet1 6 2-5 5 4 -"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := runCLI(t, append([]string{"-input", input, "-validate"}, tt.args...)...)
+
+			_, report, ok := strings.Cut(output, "Validation report for "+input+":\n")
+			lines := strings.Split(strings.TrimSpace(report), "\n")
+			if !ok || len(lines) != 2 {
+				t.Fatalf("-validate printed no report of one sheet:\n%s", output)
+			}
+			if got := strings.Join(strings.Fields(lines[1]), " "); got != tt.want {
+				t.Errorf("-validate reported %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestAppliesDetectionOptions(t *testing.T) {
+	input := writeInput(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string // lines the suggestions must hold
+	}{
+		{"defaults", nil, []string{
+			`# Sheet 0 "Sheet1": header detected at row 2 (-start-row 1), table rows 2-5 of 6, 5 columns`,
+			"excel2csv -input " + input + " -sheet-index 0 -start-row 1",
+			"excel2csv -input " + input + " -sheet-index 0 -detection none",
+		}},
+		{"no detection", []string{"-detection", "none"}, []string{
+			`# Sheet 0 "Sheet1": header detected at row 1 (-start-row 0), table rows 1-6 of 6, 5 columns`,
+			"excel2csv -input " + input + " -detection none -sheet-index 0 -range A1:E6",
+		}},
+		// The header is below the rows scanned, so the whole sheet is kept
+		{"header scan", []string{"-max-header-scan", "1"}, []string{
+			`# Sheet 0 "Sheet1": header detected at row 1 (-start-row 0), table rows 1-6 of 6, 5 columns`,
+			"excel2csv -input " + input + " -max-header-scan 1 -sheet-index 0",
+		}},
+		// Another strategy replaces the one given
+		{"structural", []string{"-detection", "structural", "-number-locale", "eu"}, []string{
+			"excel2csv -input " + input + " -detection structural -number-locale eu -sheet-index 0 -range A2:E5",
+			"excel2csv -input " + input + " -number-locale eu -sheet-index 0 -detection none",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := runCLI(t, append([]string{"-input", input, "-suggest"}, tt.args...)...)
+			lines := strings.Split(output, "\n")
+			for _, want := range tt.want {
+				if !slices.Contains(lines, want) {
+					t.Errorf("-suggest printed no line %q:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
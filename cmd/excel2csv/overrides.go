@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oxyii/excel2csv"
+)
+
+// parseSheetOverride parses a -sheet-option value such as "Prices EU:separator=semicolon,locale=eu"
+// into the sheet name and its options, merged into options of earlier values for the sheet.
+// Excel sheet names can't contain a colon, so the first one ends the name.
+func parseSheetOverride(value string, overrides map[string]excel2csv.SheetOptions) error {
+	sheet, settings, ok := strings.Cut(value, ":")
+	if !ok || sheet == "" {
+		return fmt.Errorf("invalid sheet option %q: use \"Sheet name:key=value,...\"", value)
+	}
+
+	options := overrides[sheet]
+	for _, setting := range strings.Split(settings, ",") {
+		key, val, ok := strings.Cut(setting, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || val == "" {
+			return fmt.Errorf("invalid sheet option %q: %q is not key=value", value, setting)
+		}
+
+		switch key {
+		case "separator":
+			separator, err := excel2csv.ParseSeparator(val)
+			if err != nil {
+				return fmt.Errorf("invalid sheet option %q: %w", value, err)
+			}
+			options.CSVSeparator = separator
+		case "format":
+			var format excel2csv.OutputFormat
+			switch strings.ToLower(val) {
+			case "csv":
+				format = excel2csv.FormatCSV
+			case "tsv":
+				format = excel2csv.FormatTSV
+			case "parquet":
+				format = excel2csv.FormatParquet
+			default:
+				return fmt.Errorf("invalid sheet option %q: format must be csv, tsv or parquet", value)
+			}
+			options.OutputFormat = &format
+		case "locale":
+			var locale excel2csv.NumberLocale
+			switch strings.ToLower(val) {
+			case "en":
+				locale = excel2csv.NumberLocaleEN
+			case "eu", "de", "fr":
+				locale = excel2csv.NumberLocaleEU
+			default:
+				return fmt.Errorf("invalid sheet option %q: locale must be en or eu", value)
+			}
+			options.NumberLocale = &locale
+		case "date-format":
+			options.DateFormat = val
+		default:
+			return fmt.Errorf("invalid sheet option %q: unknown key %q, use separator, format, locale or date-format", value, key)
+		}
+	}
+
+	overrides[sheet] = options
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/oxyii/excel2csv"
+)
+
+// shellSafe matches arguments that don't need quoting in a shell
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:@%+=,-]+$`)
+
+// printSuggestions prints the flags that reproduce or correct the detected tables as
+// commented, copy-pasteable commands that keep the flag and value pairs of detectionArgs
+func printSuggestions(inputFile string, detectionArgs []string, report *excel2csv.ValidationReport) {
+	command := "excel2csv -input " + shellQuote(inputFile)
+	redetect := command // for another -detection strategy than the one given
+	for i := 0; i+1 < len(detectionArgs); i += 2 {
+		arg := " " + shellQuote(detectionArgs[i]) + " " + shellQuote(detectionArgs[i+1])
+		command += arg
+		if detectionArgs[i] != "-detection" {
+			redetect += arg
+		}
+	}
+
+	fmt.Printf("\nSuggested commands for %s:\n", inputFile)
+
+	withData := 0
+	for _, sheet := range report.Sheets {
+		sheetCommand := fmt.Sprintf("%s -sheet-index %d", command, sheet.Index)
+		redetectCommand := fmt.Sprintf("%s -sheet-index %d", redetect, sheet.Index)
+		fmt.Printf("\n# Sheet %d %q: ", sheet.Index, sheet.Name)
+
+		if sheet.StartRow < 0 {
+			fmt.Printf("%s, nothing to convert\n", strings.Join(sheet.Warnings, "; "))
+			continue
+		}
+
+		fmt.Printf("header detected at row %d (-start-row %d), table rows %d-%d of %d, %d columns\n",
+			sheet.StartRow+1, sheet.StartRow, sheet.StartRow+1, sheet.EndRow+1, sheet.TotalRows, sheet.Columns)
+
+		if sheet.Rows > 1 {
+			withData++
+		}
+
+		if sheet.Columns < 2 || sheet.Rows <= 1 {
+			fmt.Println("# The table looks wrong (a single column or no data rows). Try the structural detection,")
+			fmt.Println("# keep every row, or pass the header row (0-based) with -start-row:")
+			fmt.Println(redetectCommand + " -detection structural")
+			fmt.Println(redetectCommand + " -detection none")
+			continue
+		}
+
+		switch {
+		case sheet.StartRow == 0 && sheet.EndRow == sheet.TotalRows-1:
+			fmt.Println("# The whole sheet is the table, the defaults work:")
+			fmt.Println(sheetCommand)
+		default:
+			if sheet.StartRow > 0 {
+				fmt.Printf("# %d rows above the header are skipped. If auto-detection looks wrong, pin the header row:\n", sheet.StartRow)
+				fmt.Printf("%s -start-row %d\n", sheetCommand, sheet.StartRow)
+			}
+			if below := sheet.TotalRows - 1 - sheet.EndRow; below > 0 {
+				fmt.Printf("# %d rows below the table are dropped. To keep every row:\n", below)
+				fmt.Println(redetectCommand + " -detection none")
+			}
+		}
+
+		fmt.Println("# To pin the exact cells of the detected table:")
+		fmt.Printf("%s -range A%d:%s%d\n", sheetCommand, sheet.StartRow+1, columnName(sheet.Columns-1), sheet.EndRow+1)
+	}
+
+	if withData > 1 {
+		fmt.Printf("\n# %d sheets hold tables, convert them all at once:\n", withData)
+		fmt.Println(command + " -all-sheets")
+	}
+}
+
+// columnName returns the A1 letters of a 0-based column index
+func columnName(col int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name
+}
+
+// shellQuote quotes an argument for POSIX shells when it needs it
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
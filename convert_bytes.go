@@ -0,0 +1,48 @@
+package excel2csv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConvertBytes converts an in-memory spreadsheet and returns the output bytes.
+// format is the file extension of data, with or without the dot (e.g. "xlsx").
+// opts configures the conversion, nil uses NewExcelConverter defaults. In all sheets
+// mode the result is a ZIP archive with one entry per sheet.
+func ConvertBytes(data []byte, format string, opts *ExcelConverter) ([]byte, error) {
+	if opts == nil {
+		opts = NewExcelConverter()
+	}
+
+	ext := "." + strings.ToLower(strings.TrimPrefix(format, "."))
+	if err := opts.checkInputFormat("input" + ext); err != nil {
+		return nil, err
+	}
+
+	// LibreOffice reads files only, spool the data where it can reach it
+	tempDir, err := opts.createTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	inputPath := filepath.Join(tempDir, "input"+ext)
+	if err := os.WriteFile(inputPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	var output bytes.Buffer
+	if opts.AllSheetsMode || opts.SheetPattern != "" {
+		err = opts.ConvertAllSheetsToZip(inputPath, &output)
+	} else {
+		err = opts.ConvertTo(inputPath, &output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
@@ -1,27 +1,165 @@
 package excel2csv
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
 )
 
-// ExcelConverter handles Excel to CSV conversion using LibreOffice
+// OutputFormat selects how converted data is written
+type OutputFormat int
+
+const (
+	// FormatCSV writes delimited text using CSVSeparator
+	FormatCSV OutputFormat = iota
+	// FormatTSV writes tab-separated values, escaping embedded tabs, line breaks
+	// and backslashes as \t, \n, \r and \\ instead of quoting the field
+	FormatTSV
+	// FormatParquet writes an Apache Parquet file with the header row as field names.
+	// Column types are inferred by scanning all rows of a column: int64 when every
+	// non-empty cell is an integer, double when every one is a number, otherwise
+	// string, so mixed columns fall back to string.
+	FormatParquet
+)
+
+// LineEnding selects the row terminator of text output
+type LineEnding int
+
+const (
+	// LineEndingLF ends rows with \n
+	LineEndingLF LineEnding = iota
+	// LineEndingCRLF ends rows with \r\n
+	LineEndingCRLF
+)
+
+// NumberLocale selects how numbers are written in cells, used for table detection,
+// numeric filters and Parquet column types
+type NumberLocale int
+
+const (
+	// NumberLocaleEN reads 1,234.56: comma thousands separator, dot decimal separator
+	NumberLocaleEN NumberLocale = iota
+	// NumberLocaleEU reads 1.234,56 and 1 234,56, as in German and French sheets
+	NumberLocaleEU
+)
+
+// FormulaMode selects what is exported for formula cells
+type FormulaMode int
+
+const (
+	// FormulaCachedValue exports the calculated value of formula cells
+	FormulaCachedValue FormulaMode = iota
+	// FormulaText exports the formula itself, e.g. =A1+B1
+	FormulaText
+)
+
+// HeaderDedupeMode selects how duplicate and blank header cells are handled
+type HeaderDedupeMode int
+
+const (
+	// HeaderDedupeKeep writes the header as it is. NDJSON keys, Parquet fields and
+	// schema names are still made unique the way HeaderDedupeSuffix does it.
+	HeaderDedupeKeep HeaderDedupeMode = iota
+	// HeaderDedupeSuffix trims header cells, names blank ones column_N by position
+	// and numbers repeated names: Name, Name_2, Name_3
+	HeaderDedupeSuffix
+	// HeaderDedupeError fails the conversion on a duplicate or blank header cell
+	HeaderDedupeError
+	// HeaderDedupeDropBlank drops the columns with a blank header cell and numbers
+	// repeated names like HeaderDedupeSuffix
+	HeaderDedupeDropBlank
+)
+
+// DetectionStrategy selects the table boundary detection algorithm
+type DetectionStrategy int
+
+const (
+	// StrategyImproved uses the widest mostly-text row as the header and stops at footers
+	StrategyImproved DetectionStrategy = iota
+	// StrategyStructural looks for a run of consistently structured data rows,
+	// which sometimes detects narrow tables better
+	StrategyStructural
+	// StrategyNone keeps every exported row
+	StrategyNone
+)
+
+// ExcelConverter handles Excel to CSV conversion using LibreOffice.
+// Conversions don't modify the converter and every one gets its own temp directory,
+// so a converter can run concurrent conversions as long as its fields aren't changed
+// meanwhile. To change options per conversion, change a Clone.
 type ExcelConverter struct {
-	CSVSeparator      rune   // CSV separator (comma, semicolon, tab)
-	CleanLineBreaks   bool   // replace line breaks with spaces
-	ForceDataStartRow *int   // force data start from specific row (0-based), nil for auto-detection
-	ForceDataEndRow   *int   // force data end at specific row (0-based), nil for auto-detection
-	SheetName         string // specific sheet name to convert
-	SheetIndex        *int   // specific sheet index to convert (0-based)
-	AllSheetsMode     bool   // convert all sheets to separate CSV files
-	TempDir           string // custom temp directory (if empty, uses default)
+	OutputFormat             OutputFormat                        // output format, Parquet is also picked for .parquet output paths
+	ForceFormat              string                              // read the input as this format (e.g. "xlsx") whatever its extension, empty to go by the extension
+	Password                 string                              // opens encrypted xlsx, xlsm and xlsb workbooks; decrypted before LibreOffice runs, so it never appears in arguments or logs
+	CSVSeparator             rune                                // CSV separator (comma, semicolon, tab)
+	LineEnding               LineEnding                          // row terminator of CSV and TSV output, applies to every row including the header
+	CleanLineBreaks          bool                                // replace line breaks with spaces
+	CollapseSpaces           bool                                // collapse runs of spaces to one and trim cells, off by default so padded values are kept
+	OmitHeader               bool                                // write only data rows, without the detected header row; ignored for Parquet which takes field names from it
+	ForceDataStartRow        *int                                // force data start from specific row (0-based), nil for auto-detection
+	ForceDataEndRow          *int                                // force data end at specific row (0-based), nil for auto-detection
+	CellRange                string                              // convert only this A1 range, e.g. "B3:F120", the first row of the range is the header; overrides detection and forced rows
+	NamedRange               string                              // convert only this defined name or table object of an xlsx or xlsm workbook, it selects its sheet and the cells like CellRange
+	FormulaMode              FormulaMode                         // export calculated values (default) or formula text
+	RawValues                bool                                // export raw values (0.15, 1000) rather than numbers as displayed (15%, $1,000.00)
+	SheetName                string                              // specific sheet name to convert
+	SheetIndex               *int                                // specific sheet index to convert (0-based)
+	AllSheetsMode            bool                                // convert all sheets to separate CSV files
+	SheetPattern             string                              // convert all sheets whose name matches this regular expression, like AllSheetsMode
+	SheetOverrides           map[string]SheetOptions             // per-sheet separator, format, number locale or date format in all sheets mode, keyed by sheet name
+	FilenameTemplate         string                              // text/template of the file names in all sheets mode, see SheetFileData; empty for DefaultFilenameTemplate. Names used twice are numbered
+	TempDir                  string                              // parent of per-conversion temp directories (if empty, uses os.TempDir())
+	NoClobber                bool                                // fail when an output file exists instead of replacing it
+	MaxRetries               int                                 // extra LibreOffice attempts after a failed export, with exponential backoff
+	ExtraLibreOfficeArgs     []string                            // extra LibreOffice arguments, e.g. "--infilter=..." or "-env:...", added before --convert-to; the managed ones are rejected
+	MaxHeaderScanRows        int                                 // max rows scanned for a header row, 0 for the whole sheet
+	ExpectedHeaders          []string                            // header keywords, matched case-insensitively within cells: the row matching most of them is the header row
+	SyntheticHeaders         bool                                // when the table starts with data instead of a header row, add a header of column letters (A, B, C, ...); rows picked by ExpectedHeaders, CellRange or ForceDataStartRow are always the header
+	DiagnosticsPath          string                              // write a JSON file explaining the detection: every row with its cell counts, whether it is in the table and why; numbered per sheet in all sheets mode
+	MaxRows                  int                                 // fail with ErrRowLimitExceeded when the exported sheet has more rows, 0 for no limit
+	MaxArchiveEntrySize      int64                               // largest ZIP archive entry ConvertArchive extracts, in bytes; 0 for DefaultMaxArchiveEntrySize
+	SplitRows                int                                 // split single sheet ConvertFile output into out.part001.csv, out.part002.csv, ... of at most this many data rows, each with the header
+	DetectionStrategy        DetectionStrategy                   // table boundary detection algorithm
+	DetectMultipleTables     bool                                // write every table of a sheet, split at blank rows, to its own file, in all sheets mode and when converting a sheet to a file
+	IncludeEmptySheets       bool                                // in all sheets mode, also write sheets without data rows, which are skipped by default
+	NumberLocale             NumberLocale                        // decimal and thousands separators used to recognize numbers
+	SelectColumns            []string                            // output only columns whose header contains these names, in this order
+	RowFilters               []RowFilter                         // keep only data rows matching all filters
+	FillMergedDown           bool                                // forward-fill empty cells from the cell above, e.g. for merged category cells
+	FillColumns              []int                               // columns (0-based) to fill down, empty for all columns
+	Transpose                bool                                // swap rows and columns of the detected table, for tables with field names down the first column
+	TrimTrailingEmptyColumns bool                                // drop columns right of the last column holding data in any kept row
+	DateFormat               string                              // Go layout (e.g. "2006-01-02") to rewrite cells recognized as dates, empty to keep them as exported
+	SanitizeFormulas         bool                                // prefix cells starting with =, +, -, @, tab or CR with a single quote so spreadsheets don't run them as formulas
+	EmptyCellValue           string                              // written for empty data cells, e.g. \N for PostgreSQL COPY
+	CellTransformers         []func(string) string               // applied in order to every output cell, after the CleanLineBreaks cleaner
+	HeaderRename             map[string]string                   // rename header cells, matched case-insensitively on the trimmed text
+	HeaderDedupeMode         HeaderDedupeMode                    // keep duplicate and blank header cells (default), number and name them, fail on them, or drop blank ones
+	SheetNameColumn          string                              // if set, prepend a column with this header holding the sheet name on every data row
+	IncludeMetadataComment   bool                                // start CSV and TSV output with a "# source=... sheet=... rows=... generated=..." line, which strict CSV readers don't accept
+	MetadataCommentPrefix    string                              // starts the metadata comment line, "#" if empty
+	EmitDDL                  string                              // write a CREATE TABLE statement for this dialect (postgres, mysql, sqlite) next to the output file
+	OnProgress               func(done, total int, stage string) // called after every sheet ("sheets") and every progressRowInterval written rows ("rows"); total is 0 while StreamNDJSON streams rows of unknown count
 }
 
 // SheetInfo contains information about a worksheet
@@ -33,58 +171,359 @@ type SheetInfo struct {
 // NewExcelConverter creates a new converter with default settings
 func NewExcelConverter() *ExcelConverter {
 	return &ExcelConverter{
-		CSVSeparator:    ',',  // comma separator by default
-		CleanLineBreaks: true, // clean line breaks by default
+		CSVSeparator:      ',',  // comma separator by default
+		CleanLineBreaks:   true, // clean line breaks by default
+		MaxRetries:        2,    // LibreOffice fails intermittently under load
+		MaxHeaderScanRows: 50,   // look for headers near the top of the sheet
+	}
+}
+
+// Clone returns a copy of the converter that shares no slices, maps or pointers with it,
+// so either can be changed without affecting the other. CellTransformers and OnProgress
+// functions are shared and must be safe for concurrent use if the copies run concurrently.
+func (ec *ExcelConverter) Clone() *ExcelConverter {
+	clone := *ec
+	clone.ForceDataStartRow = cloneInt(ec.ForceDataStartRow)
+	clone.ForceDataEndRow = cloneInt(ec.ForceDataEndRow)
+	clone.SheetIndex = cloneInt(ec.SheetIndex)
+	clone.ExpectedHeaders = slices.Clone(ec.ExpectedHeaders)
+	clone.ExtraLibreOfficeArgs = slices.Clone(ec.ExtraLibreOfficeArgs)
+	clone.SelectColumns = slices.Clone(ec.SelectColumns)
+	clone.FillColumns = slices.Clone(ec.FillColumns)
+	clone.CellTransformers = slices.Clone(ec.CellTransformers)
+	clone.HeaderRename = maps.Clone(ec.HeaderRename)
+	if ec.SheetOverrides != nil {
+		clone.SheetOverrides = make(map[string]SheetOptions, len(ec.SheetOverrides))
+		for name, options := range ec.SheetOverrides {
+			clone.SheetOverrides[name] = options.clone()
+		}
+	}
+
+	clone.RowFilters = slices.Clone(ec.RowFilters)
+	for i := range clone.RowFilters {
+		clone.RowFilters[i].ColumnIndex = cloneInt(ec.RowFilters[i].ColumnIndex)
 	}
+
+	return &clone
+}
+
+// cloneInt returns a pointer to a copy of *p, or nil if p is nil
+func cloneInt(p *int) *int {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// ParseSeparator maps a separator name (comma, semicolon, tab, pipe, space) or a
+// single character to a CSVSeparator. Quotes and line breaks can't separate fields.
+func ParseSeparator(value string) (rune, error) {
+	switch value {
+	case "", "comma", ",":
+		return ',', nil
+	case "semicolon", ";":
+		return ';', nil
+	case "tab", "\t":
+		return '\t', nil
+	case "pipe", "|":
+		return '|', nil
+	case "space", " ":
+		return ' ', nil
+	}
+
+	if utf8.RuneCountInString(value) != 1 || strings.ContainsAny(value, "\"\r\n") {
+		return 0, fmt.Errorf("invalid separator %q: use comma, semicolon, tab, pipe, space or a single character", value)
+	}
+	separator, _ := utf8.DecodeRuneInString(value)
+	return separator, nil
 }
 
 // ConvertFile converts an Excel file to CSV using LibreOffice
 func (ec *ExcelConverter) ConvertFile(inputPath, outputPath string) error {
-	ext := strings.ToLower(filepath.Ext(inputPath))
+	return ec.ConvertFileContext(context.Background(), inputPath, outputPath)
+}
 
-	// Check if the file is a supported Excel format
-	switch ext {
-	case ".xlsx", ".xls", ".ods":
-		return ec.convertViaLibreOffice(inputPath, outputPath)
-	default:
-		return fmt.Errorf("unsupported file format: %s. Supported formats: .xlsx, .xls, .ods", ext)
+// ConvertFileContext is like ConvertFile, but stops converting and kills
+// LibreOffice when ctx is done
+func (ec *ExcelConverter) ConvertFileContext(ctx context.Context, inputPath, outputPath string) error {
+	_, err := ec.ConvertFileStatsContext(ctx, inputPath, outputPath)
+	return err
+}
+
+// convertAllSheetsToZipFile writes all sheets to a ZIP archive at outputPath
+func (ec *ExcelConverter) convertAllSheetsToZipFile(ctx context.Context, inputPath, outputPath string) (ConvertStats, error) {
+	dstFile, err := ec.CreateOutput(outputPath)
+	if err != nil {
+		return ConvertStats{DetectedHeaderRow: -1}, err
+	}
+
+	stats, err := ec.convertAllSheetsToZip(ctx, inputPath, dstFile)
+	if err != nil {
+		_ = dstFile.Close()
+		_ = os.Remove(outputPath)
+		return stats, err
 	}
+
+	return stats, dstFile.Close()
 }
 
-// convertViaLibreOffice converts Excel files using LibreOffice headless mode
-func (ec *ExcelConverter) convertViaLibreOffice(inputPath, outputPath string) error {
-	// Check if LibreOffice is available
-	_, err := exec.LookPath("libreoffice")
+// CreateOutput creates an output file like conversions do, failing with an error
+// wrapping os.ErrExist if it exists when NoClobber is set
+func (ec *ExcelConverter) CreateOutput(path string) (*os.File, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if ec.NoClobber {
+		flag |= os.O_EXCL
+	}
+
+	file, err := os.OpenFile(path, flag, 0666)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("output %s: %w", path, os.ErrExist)
+	}
+	return file, err
+}
+
+// ConvertTo converts an Excel file and writes the resulting CSV to w
+func (ec *ExcelConverter) ConvertTo(inputPath string, w io.Writer) error {
+	return ec.ConvertToContext(context.Background(), inputPath, w)
+}
+
+// ConvertToContext is like ConvertTo, but kills LibreOffice and all of its
+// child processes when ctx is done
+func (ec *ExcelConverter) ConvertToContext(ctx context.Context, inputPath string, w io.Writer) error {
+	converter, err := ec.withNamedRange(inputPath)
+	if err != nil {
+		return err
+	}
+	_, err = converter.convertTo(ctx, inputPath, w)
+	return err
+}
+
+// convertTo converts the selected sheet to w and reports what was written
+func (ec *ExcelConverter) convertTo(ctx context.Context, inputPath string, w io.Writer) (ConvertStats, error) {
+	stats := ConvertStats{DetectedHeaderRow: -1}
+	counter := &countingWriter{w: w}
+
+	if ec.isPassThrough() && !ec.isDelimitedText(inputPath) {
+		if err := ec.checkInputFormat(inputPath); err != nil {
+			return stats, err
+		}
+		rows := &csvRowCounter{w: counter, max: ec.MaxRows}
+		err := ec.exportViaLibreOffice(ctx, inputPath, func(csvPath string) error {
+			return copyCSVFile(rows, csvPath)
+		})
+		if err != nil {
+			return stats, err
+		}
+		// Without detection the table starts at the first row
+		stats.RowsWritten = rows.count()
+		stats.BytesWritten = counter.n
+		stats.SheetsConverted = 1
+		if stats.RowsWritten > 0 {
+			stats.DetectedHeaderRow = 0
+		}
+		if stats.RowsWritten <= 1 {
+			stats.EmptySheets = []string{ec.convertedSheetName()}
+		}
+		return stats, nil
+	}
+
+	records, headerRow, err := ec.convertedTable(ctx, inputPath)
+	stats.DetectedHeaderRow = headerRow
 	if err != nil {
-		return fmt.Errorf("LibreOffice is not available. Please install LibreOffice")
+		return stats, err
 	}
 
-	// Handle ConvertAllSheets mode
-	if ec.AllSheetsMode {
-		outputDir := filepath.Dir(outputPath)
-		return ec.ConvertAllSheetsToFiles(inputPath, outputDir)
+	return ec.writeTable(counter, inputPath, records, stats)
+}
+
+// writeTable writes the converted table of a sheet of inputPath to counter and fills in stats
+func (ec *ExcelConverter) writeTable(counter *countingWriter, inputPath string, records [][]string, stats ConvertStats) (ConvertStats, error) {
+	if err := ec.writeMetadataComment(counter, inputPath, ec.convertedSheetName(), records); err != nil {
+		return stats, err
+	}
+	if err := ec.writeRecords(counter, records); err != nil {
+		return stats, err
 	}
 
-	// Create temp directory with better permissions for HTTP context
-	homeDir, _ := os.UserHomeDir()
-	tempDir := ec.TempDir
-	if tempDir == "" {
-		tempDir = filepath.Join(homeDir, "excel2csv_temp")
+	stats.RowsWritten = len(ec.outputRows(records))
+	stats.BytesWritten = counter.n
+	stats.SheetsConverted = 1
+	if ec.EmitDDL != "" {
+		stats.columns = ec.inferColumns(records, true)
+	}
+	if len(records) <= 1 {
+		stats.EmptySheets = []string{ec.convertedSheetName()}
+	}
+	return stats, nil
+}
+
+// convertRecords exports the selected sheet and returns the processed table records
+func (ec *ExcelConverter) convertRecords(ctx context.Context, inputPath string) ([][]string, error) {
+	records, err := ec.exportRecords(ctx, inputPath)
+	if err != nil {
+		return nil, err
 	}
 
-	// For HTTP context, ensure we use a subdirectory in home dir for better LibreOffice compatibility
-	if strings.HasPrefix(tempDir, "/tmp/") {
-		fmt.Printf("Warning: Using /tmp directory may cause LibreOffice issues, switching to home directory\n")
-		tempDir = filepath.Join(homeDir, "excel2csv_temp_http")
+	return ec.processRecords(records)
+}
+
+// exportRecords exports the selected sheet and returns all of its rows
+func (ec *ExcelConverter) exportRecords(ctx context.Context, inputPath string) ([][]string, error) {
+	if err := ec.checkInputFormat(inputPath); err != nil {
+		return nil, err
 	}
 
-	_ = os.MkdirAll(tempDir, 0755)
-	defer func() {
-		if !strings.Contains(tempDir, "excel2csv_temp") {
-			// Only remove if it's our temp directory
-			_ = os.RemoveAll(tempDir)
+	if ec.AllSheetsMode || ec.SheetPattern != "" {
+		return nil, fmt.Errorf("all sheets mode produces multiple files, use ConvertAllSheetsToZip or ConvertAllSheetsToFiles")
+	}
+
+	// Fail on a malformed range before running LibreOffice
+	if ec.CellRange != "" {
+		if _, err := parseCellRange(ec.CellRange); err != nil {
+			return nil, err
 		}
-	}()
+	}
+
+	records, err := ec.readSheet(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err = ec.applyCellRange(records)
+	if err != nil {
+		return nil, err
+	}
+
+	if ec.DetectMultipleTables {
+		ec.warnMultipleTables(records)
+	}
+
+	return records, nil
+}
+
+// checkInputFormat checks if the file is a supported Excel format, or CSV/TSV to tidy up
+func (ec *ExcelConverter) checkInputFormat(inputPath string) error {
+	ext := ec.inputExt(inputPath)
+
+	switch ext {
+	case ".xlsx", ".xlsm", ".xlsb", ".xls", ".ods", ".csv", ".tsv":
+		return nil
+	default:
+		return fmt.Errorf("unsupported file format: %s. Supported formats: .xlsx, .xlsm, .xlsb, .xls, .ods, .csv, .tsv", ext)
+	}
+}
+
+// inputExt returns the lowercase extension that decides how inputPath is read,
+// ForceFormat if set, so misnamed files and files without a known extension can be read
+func (ec *ExcelConverter) inputExt(inputPath string) string {
+	if ec.ForceFormat != "" {
+		return "." + strings.ToLower(strings.TrimPrefix(ec.ForceFormat, "."))
+	}
+	return strings.ToLower(filepath.Ext(inputPath))
+}
+
+// importFilters are the LibreOffice import filters of the formats ForceFormat can pick
+var importFilters = map[string]string{
+	".xlsx": "Calc MS Excel 2007 XML",
+	".xlsm": "Calc MS Excel 2007 VBA XML",
+	".xlsb": "Calc MS Excel 2007 Binary",
+	".xls":  "MS Excel 97",
+	".ods":  "calc8",
+}
+
+// importFilterArgs returns the LibreOffice option that makes it read the input as ForceFormat.
+// Without ForceFormat LibreOffice detects the format itself.
+func (ec *ExcelConverter) importFilterArgs() []string {
+	if ec.ForceFormat == "" {
+		return nil
+	}
+	return []string{"--infilter=" + importFilters[ec.inputExt("")]}
+}
+
+// managedLibreOfficeArgs are the LibreOffice arguments the converter sets itself,
+// ExtraLibreOfficeArgs can't override them
+var managedLibreOfficeArgs = []string{"headless", "convert-to", "outdir", "env:UserInstallation"}
+
+// checkExtraLibreOfficeArgs rejects extra arguments that aren't options or that
+// would override the managed arguments
+func (ec *ExcelConverter) checkExtraLibreOfficeArgs() error {
+	for _, arg := range ec.ExtraLibreOfficeArgs {
+		// LibreOffice accepts options with one or two dashes and values after "="
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("extra LibreOffice argument %q is not an option", arg)
+		}
+		if slices.Contains(managedLibreOfficeArgs, name) {
+			return fmt.Errorf("extra LibreOffice argument %q conflicts with the arguments the converter sets", arg)
+		}
+	}
+	return nil
+}
+
+// libreOfficeArgs returns the arguments of a LibreOffice run before the conversion
+// arguments: the profile in workDir, headless mode, the import filter and ExtraLibreOfficeArgs
+func (ec *ExcelConverter) libreOfficeArgs(workDir string) []string {
+	args := append([]string{profileArg(workDir), "--headless"}, ec.importFilterArgs()...)
+	return append(args, ec.ExtraLibreOfficeArgs...)
+}
+
+// isPassThrough reports whether LibreOffice's CSV export is already the final output,
+// so it can be copied as is instead of being parsed and written again
+func (ec *ExcelConverter) isPassThrough() bool {
+	return ec.OutputFormat == FormatCSV &&
+		ec.DetectionStrategy == StrategyNone &&
+		ec.ForceDataStartRow == nil && ec.ForceDataEndRow == nil && ec.CellRange == "" &&
+		!ec.CleanLineBreaks && !ec.CollapseSpaces && !ec.OmitHeader &&
+		!ec.FillMergedDown && len(ec.RowFilters) == 0 && len(ec.SelectColumns) == 0 &&
+		!ec.TrimTrailingEmptyColumns && ec.DateFormat == "" && !ec.SanitizeFormulas &&
+		!ec.IncludeMetadataComment &&
+		ec.EmptyCellValue == "" && !ec.Transpose && ec.SheetNameColumn == "" &&
+		ec.EmitDDL == "" &&
+		len(ec.CellTransformers) == 0 && len(ec.HeaderRename) == 0 &&
+		ec.HeaderDedupeMode == HeaderDedupeKeep && !ec.SyntheticHeaders && ec.DiagnosticsPath == "" &&
+		!ec.AllSheetsMode && ec.SheetPattern == "" &&
+		// LibreOffice ends lines with the platform line ending
+		ec.LineEnding == LineEndingLF && runtime.GOOS != "windows"
+}
+
+// convertViaLibreOffice converts Excel files to CSV using LibreOffice headless mode
+// and returns the exported records
+func (ec *ExcelConverter) convertViaLibreOffice(ctx context.Context, inputPath string) ([][]string, error) {
+	var records [][]string
+	err := ec.exportViaLibreOffice(ctx, inputPath, func(csvPath string) error {
+		var err error
+		records, err = readCSVFile(csvPath, ec.exportSeparator(), ec.MaxRows)
+		return err
+	})
+	return records, err
+}
+
+// exportViaLibreOffice exports the selected sheet to CSV in a temp directory
+// and calls handle with the CSV path before the directory is removed
+func (ec *ExcelConverter) exportViaLibreOffice(ctx context.Context, inputPath string, handle func(csvPath string) error) error {
+	if err := ec.checkExtraLibreOfficeArgs(); err != nil {
+		return err
+	}
+
+	// Check if LibreOffice is available
+	libreOfficePath, err := FindLibreOffice()
+	if err != nil {
+		return err
+	}
+
+	// Create a temp directory of our own so concurrent conversions don't pick up each other's CSVs
+	tempDir, err := ec.createTempDir()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	// Encrypted workbooks fail opaquely in LibreOffice, decrypt them or report them clearly
+	inputPath, err = ec.decryptInput(inputPath, tempDir)
+	if err != nil {
+		return err
+	}
 
 	// Convert using LibreOffice - improved for HTTP context
 	absInputPath, _ := filepath.Abs(inputPath)
@@ -96,111 +535,506 @@ func (ec *ExcelConverter) convertViaLibreOffice(inputPath, outputPath string) er
 		fmt.Printf("Input file: %s (size: %d bytes, mode: %v)\n", absInputPath, stat.Size(), stat.Mode())
 	}
 
-	// For now, we'll only convert the first/default sheet since --sheet parameter is not supported
-	// TODO: Implement proper multi-sheet support using LibreOffice UNO API or other methods
-	if ec.SheetName != "" {
-		fmt.Printf("Warning: sheet selection by name '%s' is not fully supported yet, converting default sheet\n", ec.SheetName)
+	sheetNumber, err := ec.selectedSheetNumber(inputPath)
+	if err != nil {
+		return err
 	}
-	if ec.SheetIndex != nil {
-		fmt.Printf("Warning: sheet selection by index %d is not fully supported yet, converting default sheet\n", *ec.SheetIndex)
+	if sheetNumber > 0 {
+		if err := checkSheetSelection(libreOfficePath); err != nil {
+			return err
+		}
 	}
 
-	cmd := exec.Command("libreoffice", "--headless", "--convert-to", "csv", "--outdir", tempDir, absInputPath)
+	// LibreOffice fails intermittently under load, retry with a clean directory and profile
+	var tempCSVPath string
+	for attempt := 0; ; attempt++ {
+		attemptDir := filepath.Join(tempDir, fmt.Sprintf("attempt_%d", attempt+1))
+		tempCSVPath, err = ec.runLibreOfficeExport(ctx, libreOfficePath, absInputPath, attemptDir, sheetNumber)
+		if err == nil || attempt >= ec.MaxRetries || ctx.Err() != nil {
+			break
+		}
+
+		delay := time.Duration(500<<attempt) * time.Millisecond
+		fmt.Printf("LibreOffice attempt %d failed: %v, retrying in %v\n", attempt+1, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return handle(tempCSVPath)
+}
+
+// runLibreOfficeExport runs a single LibreOffice CSV export of sheet sheetNumber (1-based,
+// 0 for the default sheet) in workDir, with its own user profile there, and returns the
+// path of the generated CSV file
+func (ec *ExcelConverter) runLibreOfficeExport(ctx context.Context, libreOfficePath, absInputPath, workDir string, sheetNumber int) (string, error) {
+	outDir := filepath.Join(workDir, "out")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	args := append(ec.libreOfficeArgs(workDir), "--convert-to", ec.csvExportFilter(sheetNumber), "--outdir", outDir, absInputPath)
+	cmd := exec.CommandContext(ctx, libreOfficePath, args...)
+	libreOfficeRuns.Add(1)
 
 	// Set environment variables to fix LibreOffice issues in HTTP context
+	homeDir, _ := os.UserHomeDir()
 	cmd.Env = append(os.Environ(),
 		"HOME="+homeDir,
-		"TMPDIR="+tempDir,
+		"TMPDIR="+workDir,
 		"DISPLAY=", // Empty DISPLAY for headless mode
 		"LANG=en_US.UTF-8",
 	)
 
-	output, err := cmd.CombinedOutput()
+	output, err := runProcessTree(cmd, true)
 	fmt.Printf("LibreOffice output: %s\n", string(output))
 
 	if err != nil {
-		return fmt.Errorf("LibreOffice conversion failed: %w", err)
+		return "", libreOfficeError(fmt.Errorf("%w: %w", ErrLibreOfficeLoadFailed, err), output)
 	}
 
 	time.Sleep(200 * time.Millisecond)
 
 	// Find generated CSV file
-	files, err := os.ReadDir(tempDir)
+	files, err := os.ReadDir(outDir)
 	if err != nil {
-		fmt.Printf("Error reading temp directory %s: %v\n", tempDir, err)
-		return fmt.Errorf("failed to read temp directory: %w", err)
+		fmt.Printf("Error reading temp directory %s: %v\n", outDir, err)
+		return "", fmt.Errorf("failed to read temp directory: %w", err)
 	}
 
-	fmt.Printf("Files in temp directory %s: %d files\n", tempDir, len(files))
+	fmt.Printf("Files in temp directory %s: %d files\n", outDir, len(files))
 	for _, file := range files {
 		fmt.Printf("  - %s (isDir: %v)\n", file.Name(), file.IsDir())
 	}
 
-	var tempCSVPath string
 	for _, file := range files {
 		if strings.HasSuffix(strings.ToLower(file.Name()), ".csv") {
-			tempCSVPath = filepath.Join(tempDir, file.Name())
+			tempCSVPath := filepath.Join(outDir, file.Name())
 			fmt.Printf("Found CSV file: %s\n", tempCSVPath)
-			break
+			return tempCSVPath, nil
 		}
 	}
 
-	if tempCSVPath == "" {
-		fmt.Printf("No CSV files found in temp directory %s\n", tempDir)
-		return fmt.Errorf("LibreOffice did not generate CSV file")
+	fmt.Printf("No CSV files found in temp directory %s\n", outDir)
+
+	// LibreOffice exits successfully when it can't load the input, the output tells
+	if strings.Contains(string(output), "could not be loaded") {
+		return "", libreOfficeError(ErrLibreOfficeLoadFailed, output)
 	}
+	return "", libreOfficeError(ErrNoOutputProduced, output)
+}
 
-	// Read and copy CSV file
-	return ec.copyCSVFile(tempCSVPath, outputPath)
+// profileArg returns the LibreOffice option that puts its user profile in a "profile"
+// subdirectory of dir. Every run gets its own profile, because runs sharing the default
+// ~/.config/libreoffice profile block each other on its lock.
+func profileArg(dir string) string {
+	profileDir, _ := filepath.Abs(filepath.Join(dir, "profile"))
+	return "-env:UserInstallation=" + fileURL(profileDir)
 }
 
-func (ec *ExcelConverter) copyCSVFile(srcPath, dstPath string) error {
+// fileURL converts an absolute path to the file:// URL form LibreOffice expects for -env options
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows drive paths become file:///C:/...
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// createTempDir creates a unique temp directory under TempDir for a single LibreOffice run.
+// The caller is responsible for removing it.
+func (ec *ExcelConverter) createTempDir() (string, error) {
+	parentDir := ec.TempDir
+	if parentDir == "" {
+		parentDir = os.TempDir()
+	}
+
+	// Snap packaged LibreOffice can't read /tmp, use a subdirectory in home dir instead
+	if isSnapLibreOffice() && (parentDir == "/tmp" || strings.HasPrefix(parentDir, "/tmp/")) {
+		fmt.Printf("Warning: Using /tmp directory may cause LibreOffice snap issues, switching to home directory\n")
+		homeDir, _ := os.UserHomeDir()
+		parentDir = filepath.Join(homeDir, "excel2csv_temp")
+	}
+
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp(parentDir, "excel2csv_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	return tempDir, nil
+}
+
+// isSnapLibreOffice reports whether LibreOffice is installed as a snap package
+func isSnapLibreOffice() bool {
+	path, err := FindLibreOffice()
+	if err != nil {
+		return false
+	}
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil && strings.HasPrefix(resolved, "/snap/") {
+		return true
+	}
+	return strings.HasPrefix(path, "/snap/")
+}
+
+// csvExportFilter returns the LibreOffice --convert-to target for CSV export of sheet
+// sheetNumber (1-based), or of the default sheet for 0. It is always UTF-8 since
+// LibreOffice otherwise picks the charset from the system locale.
+func (ec *ExcelConverter) csvExportFilter(sheetNumber int) string {
+	// Filter options: field separator, text delimiter, charset, first line, cell formats,
+	// language, quote all text, detect special numbers, save as shown, export formulas
+	filter := fmt.Sprintf("csv:Text - txt - csv (StarCalc):%d,34,UTF8,1,,0,false,true,%t,%t",
+		ec.exportSeparator(), !ec.RawValues, ec.FormulaMode == FormulaText)
+	if sheetNumber > 0 {
+		// Then remove spaces, an import option, and the sheet to export
+		filter += fmt.Sprintf(",false,%d", sheetNumber)
+	}
+	return filter
+}
+
+// exportSeparator is the field separator LibreOffice exports with. CSV output gets
+// CSVSeparator straight from LibreOffice, other formats are re-encoded from commas.
+func (ec *ExcelConverter) exportSeparator() rune {
+	if ec.OutputFormat == FormatCSV && ec.CSVSeparator != 0 {
+		return ec.CSVSeparator
+	}
+	return ','
+}
+
+// ErrRowLimitExceeded is returned when the exported sheet has more rows than MaxRows
+var ErrRowLimitExceeded = errors.New("row limit exceeded")
+
+// readCSVFile reads the records of a CSV file exported by LibreOffice as it streams
+// from the file, failing with ErrRowLimitExceeded after maxRows records unless
+// maxRows is 0, so MaxRows bounds the memory a sheet takes
+func readCSVFile(srcPath string, separator rune, maxRows int) ([][]string, error) {
+	var records [][]string
+	err := eachCSVRecord(srcPath, separator, maxRows, func(record []string) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// errStopRecords stops eachCSVRecord without an error
+var errStopRecords = errors.New("stop reading records")
+
+// eachCSVRecord calls handle with each record of a CSV file exported by LibreOffice,
+// failing with ErrRowLimitExceeded after maxRows records unless maxRows is 0.
+// handle returns errStopRecords to stop early.
+func eachCSVRecord(srcPath string, separator rune, maxRows int, handle func(record []string) error) error {
 	srcFile, err := os.Open(srcPath)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = srcFile.Close() }()
 
-	dstFile, err := os.Create(dstPath)
-	if err != nil {
-		return err
+	source := bufio.NewReader(srcFile)
+	if bom, _ := source.Peek(3); bytes.Equal(bom, []byte("\xEF\xBB\xBF")) {
+		_, _ = source.Discard(3)
 	}
-	defer func() { _ = dstFile.Close() }()
 
-	reader := csv.NewReader(srcFile)
-	writer := csv.NewWriter(dstFile)
-	defer writer.Flush()
+	reader := csv.NewReader(&textDecoder{r: source})
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1 // LibreOffice pads rows, CSV inputs may not
 
-	// Set CSV separator
-	writer.Comma = ec.CSVSeparator
+	for count := 0; ; count++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if maxRows > 0 && count == maxRows {
+			return fmt.Errorf("%w: sheet has more than %d rows", ErrRowLimitExceeded, maxRows)
+		}
+		if err := handle(record); err != nil {
+			if errors.Is(err, errStopRecords) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// textDecoder passes UTF-8 through and reads the rest of the text as Latin-1 from the
+// first byte that isn't valid UTF-8. Export is requested as UTF-8, but Latin-1 is
+// read rather than returning mojibake; text before that byte is kept as UTF-8.
+type textDecoder struct {
+	r       *bufio.Reader
+	latin1  bool
+	pending []byte // the rest of a character that didn't fit the last Read
+}
+
+func (d *textDecoder) Read(p []byte) (int, error) {
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+
+	var encoded [utf8.UTFMax]byte
+	for n < len(p) {
+		// Copy buffered UTF-8 as is, up to an invalid or incomplete character
+		if !d.latin1 {
+			if d.r.Buffered() == 0 {
+				if _, err := d.r.Peek(1); err != nil {
+					return readResult(n, err)
+				}
+			}
+			chunk, _ := d.r.Peek(min(d.r.Buffered(), len(p)-n))
+			if valid := validUTF8Prefix(chunk); valid > 0 {
+				n += copy(p[n:], chunk[:valid])
+				_, _ = d.r.Discard(valid)
+				continue
+			}
+		}
+
+		var r rune
+		if d.latin1 {
+			b, err := d.r.ReadByte()
+			if err != nil {
+				return readResult(n, err)
+			}
+			// Every Latin-1 byte is the code point of its character
+			r = rune(b)
+		} else {
+			var size int
+			var err error
+			r, size, err = d.r.ReadRune()
+			if err != nil {
+				return readResult(n, err)
+			}
+			if r == utf8.RuneError && size == 1 {
+				fmt.Printf("Warning: LibreOffice output is not UTF-8, reading it as Latin-1\n")
+				_ = d.r.UnreadRune()
+				d.latin1 = true
+				continue
+			}
+		}
+
+		size := utf8.EncodeRune(encoded[:], r)
+		copied := copy(p[n:], encoded[:size])
+		d.pending = append(d.pending, encoded[copied:size]...)
+		n += copied
+	}
+	return n, nil
+}
+
+// validUTF8Prefix returns the length of the longest prefix of data made of
+// complete, valid UTF-8 characters
+func validUTF8Prefix(data []byte) int {
+	if utf8.Valid(data) {
+		return len(data)
+	}
+
+	i := 0
+	for i < len(data) {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// readResult reports the bytes read before err, leaving io.EOF to the next Read
+func readResult(n int, err error) (int, error) {
+	if n > 0 && err == io.EOF {
+		return n, nil
+	}
+	return n, err
+}
 
-	records, err := reader.ReadAll()
+// copyCSVFile copies a CSV file exported by LibreOffice to w, without a UTF-8 BOM
+func copyCSVFile(w io.Writer, srcPath string) error {
+	srcFile, err := os.Open(srcPath)
 	if err != nil {
 		return err
 	}
+	defer func() { _ = srcFile.Close() }()
+
+	reader := bufio.NewReader(srcFile)
+	if bom, _ := reader.Peek(3); bytes.Equal(bom, []byte("\xEF\xBB\xBF")) {
+		_, _ = reader.Discard(3)
+	}
 
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// processRecords extracts the table from exported records and applies all transformations
+func (ec *ExcelConverter) processRecords(records [][]string) ([][]string, error) {
 	// Apply intelligent processing to detect table boundaries
-	processedRecords := ec.processTableData(records)
+	table, err := ec.processTableData(records)
+	if err != nil {
+		return nil, err
+	}
+	return ec.transformTable(table)
+}
 
-	for _, record := range processedRecords {
-		// Clean line breaks if needed
-		if ec.CleanLineBreaks {
-			for i, cell := range record {
-				record[i] = ec.cleanCellData(cell)
-			}
-		}
+// transformTable applies all transformations to the rows of a detected table, header first
+func (ec *ExcelConverter) transformTable(processedRecords [][]string) ([][]string, error) {
+	if ec.Transpose {
+		processedRecords = transpose(processedRecords)
+	}
+
+	processedRecords = ec.addSyntheticHeader(processedRecords)
+
+	ec.fillMergedDown(processedRecords)
+
+	processedRecords, err := ec.filterRows(processedRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	processedRecords, err = ec.selectColumns(processedRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	processedRecords = ec.trimTrailingEmptyColumns(processedRecords)
+	ec.transformCells(processedRecords)
+
+	processedRecords, err = ec.dedupeHeader(processedRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	ec.renameHeaders(processedRecords)
+	processedRecords = ec.addSheetNameColumn(processedRecords)
+
+	return processedRecords, nil
+}
+
+// writeRecords writes records to w in the configured output format
+func (ec *ExcelConverter) writeRecords(w io.Writer, records [][]string) error {
+	records = ec.outputRows(records)
+
+	switch ec.OutputFormat {
+	case FormatTSV:
+		return ec.writeTSV(w, records)
+	case FormatParquet:
+		return ec.writeParquet(w, records)
+	default:
+		return ec.writeCSV(w, records)
+	}
+}
+
+// progressRowInterval is the number of written rows between "rows" progress calls
+const progressRowInterval = 10000
+
+// progress calls OnProgress if set
+func (ec *ExcelConverter) progress(done, total int, stage string) {
+	if ec.OnProgress != nil {
+		ec.OnProgress(done, total, stage)
+	}
+}
+
+// rowProgress reports written rows every progressRowInterval rows and after the last one
+func (ec *ExcelConverter) rowProgress(done, total int) {
+	if done%progressRowInterval == 0 || done == total {
+		ec.progress(done, total, "rows")
+	}
+}
+
+// outputRows drops the header row when OmitHeader is set.
+// Parquet output always keeps it, since it provides the field names.
+func (ec *ExcelConverter) outputRows(records [][]string) [][]string {
+	if !ec.OmitHeader || ec.OutputFormat == FormatParquet || len(records) == 0 {
+		return records
+	}
+	return records[1:]
+}
+
+// writeCSV writes records as delimited text using the configured separator
+func (ec *ExcelConverter) writeCSV(w io.Writer, records [][]string) error {
+	writer := csv.NewWriter(w)
+
+	// Set CSV separator
+	writer.Comma = ec.CSVSeparator
+	writer.UseCRLF = ec.LineEnding == LineEndingCRLF
+
+	for i, record := range records {
 		if err := writer.Write(record); err != nil {
 			return err
 		}
+		ec.rowProgress(i+1, len(records))
 	}
 
-	return nil
+	writer.Flush()
+	return writer.Error()
+}
+
+// tsvEscaper escapes characters that would otherwise break TSV fields and rows
+var tsvEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\t", "\\t",
+	"\n", "\\n",
+	"\r", "\\r",
+)
+
+// writeTSV writes records as tab-separated values without quoting
+func (ec *ExcelConverter) writeTSV(w io.Writer, records [][]string) error {
+	writer := bufio.NewWriter(w)
+
+	lineEnding := "\n"
+	if ec.LineEnding == LineEndingCRLF {
+		lineEnding = "\r\n"
+	}
+
+	for row, record := range records {
+		for i, cell := range record {
+			if i > 0 {
+				_, _ = writer.WriteString("\t")
+			}
+			_, _ = tsvEscaper.WriteString(writer, cell)
+		}
+		_, _ = writer.WriteString(lineEnding)
+		ec.rowProgress(row+1, len(records))
+	}
+
+	return writer.Flush()
 }
 
 // processTableData intelligently processes table data based on structure analysis
-func (ec *ExcelConverter) processTableData(records [][]string) [][]string {
+func (ec *ExcelConverter) processTableData(records [][]string) ([][]string, error) {
 	if len(records) == 0 {
-		return records
+		return records, ec.writeDiagnostics(records, 0, 0)
+	}
+
+	tableStart, tableEnd := ec.tableBoundaries(records)
+	if err := ec.writeDiagnostics(records, tableStart, tableEnd); err != nil {
+		return nil, err
+	}
+	return records[tableStart : tableEnd+1], nil
+}
+
+// tableBoundaries returns the first and last row (0-based) of the table to keep
+func (ec *ExcelConverter) tableBoundaries(records [][]string) (int, int) {
+	// A cell range is checked against the sheet by applyCellRange
+	if ec.CellRange != "" {
+		if bounds, err := parseCellRange(ec.CellRange); err == nil && bounds.lastRow < len(records) {
+			fmt.Printf("Using cell range %s\n", ec.CellRange)
+			return bounds.firstRow, bounds.lastRow
+		}
 	}
 
 	// If manual boundaries are specified, use them
@@ -209,24 +1043,34 @@ func (ec *ExcelConverter) processTableData(records [][]string) [][]string {
 		end := *ec.ForceDataEndRow
 		if start >= 0 && end >= start && start < len(records) && end < len(records) {
 			fmt.Printf("Using manual boundaries: rows %d to %d\n", start+1, end+1)
-			return records[start : end+1]
+			return start, end
 		}
 	}
 
-	// Use only the improved boundary detection
-	tableStart, tableEnd := ec.detectTableBoundariesImproved(records)
+	tableStart, tableEnd := ec.detectTableBoundaries(records)
 
 	fmt.Printf("Detected table boundaries: start row %d, end row %d\n", tableStart+1, tableEnd+1)
 
 	if tableStart >= 0 && tableEnd >= tableStart && tableEnd < len(records) {
-		result := records[tableStart : tableEnd+1]
-		fmt.Printf("Returning %d rows from the table\n", len(result))
-		return result
+		fmt.Printf("Returning %d rows from the table\n", tableEnd-tableStart+1)
+		return tableStart, tableEnd
 	}
 
 	// Fallback: return all records
 	fmt.Printf("Fallback: returning all %d records\n", len(records))
-	return records
+	return 0, len(records) - 1
+}
+
+// detectTableBoundaries detects table boundaries using the configured strategy
+func (ec *ExcelConverter) detectTableBoundaries(records [][]string) (int, int) {
+	switch ec.DetectionStrategy {
+	case StrategyStructural:
+		return ec.detectTableBoundariesStructural(records)
+	case StrategyNone:
+		return 0, len(records) - 1
+	default:
+		return ec.detectTableBoundariesImproved(records)
+	}
 }
 
 // detectTableBoundariesImproved uses the insights from structure analysis
@@ -239,14 +1083,36 @@ func (ec *ExcelConverter) detectTableBoundariesImproved(records [][]string) (int
 	headerRow := -1
 	maxNonEmpty := 0
 
-	for i, record := range records {
-		nonEmpty := ec.countNonEmptyCells(record)
-		numeric := ec.countNumericCells(record)
+	if ec.ForceDataStartRow != nil && *ec.ForceDataStartRow >= 0 && *ec.ForceDataStartRow < len(records) {
+		// Forced start row is the header row, only the end needs to be detected
+		headerRow = *ec.ForceDataStartRow
+		maxNonEmpty = ec.countNonEmptyCells(records[headerRow])
+	} else {
+		// Limit the header search window so large sheets without a header give up early
+		scanRows := len(records)
+		if ec.MaxHeaderScanRows > 0 && ec.MaxHeaderScanRows < scanRows {
+			scanRows = ec.MaxHeaderScanRows
+		}
 
-		// Good header candidate: many non-empty cells, few numbers
-		if nonEmpty >= 5 && numeric <= 1 && nonEmpty > maxNonEmpty {
-			maxNonEmpty = nonEmpty
-			headerRow = i
+		// Known column names beat the structural guess
+		headerRow = ec.findExpectedHeaderRow(records[:scanRows])
+		if headerRow >= 0 {
+			maxNonEmpty = ec.countNonEmptyCells(records[headerRow])
+		} else {
+			for i, record := range records[:scanRows] {
+				nonEmpty := ec.countNonEmptyCells(record)
+				numeric := ec.countNumericCells(record)
+
+				// Good header candidate: many non-empty cells, few numbers
+				if nonEmpty >= 5 && numeric <= 1 && nonEmpty > maxNonEmpty {
+					maxNonEmpty = nonEmpty
+					headerRow = i
+				}
+			}
+		}
+
+		if headerRow == -1 && scanRows < len(records) {
+			fmt.Printf("No header row found in the first %d rows, use a forced start row or increase MaxHeaderScanRows\n", scanRows)
 		}
 	}
 
@@ -287,8 +1153,40 @@ func (ec *ExcelConverter) detectTableBoundariesImproved(records [][]string) (int
 	return headerRow, tableEnd
 }
 
-// detectTableBoundaries detects table boundaries based on data structure analysis
-func (ec *ExcelConverter) detectTableBoundaries(records [][]string) (int, int) {
+// findExpectedHeaderRow returns the first row matching the most ExpectedHeaders,
+// or -1 if no row matches any
+func (ec *ExcelConverter) findExpectedHeaderRow(records [][]string) int {
+	headerRow := -1
+	maxMatches := 0
+	for i, record := range records {
+		if matches := ec.countExpectedHeaders(record); matches > maxMatches {
+			maxMatches = matches
+			headerRow = i
+		}
+	}
+	return headerRow
+}
+
+// countExpectedHeaders counts the ExpectedHeaders found in any cell of record
+func (ec *ExcelConverter) countExpectedHeaders(record []string) int {
+	count := 0
+	for _, keyword := range ec.ExpectedHeaders {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" {
+			continue
+		}
+		for _, cell := range record {
+			if strings.Contains(strings.ToLower(cell), keyword) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// detectTableBoundariesStructural detects table boundaries based on data structure analysis
+func (ec *ExcelConverter) detectTableBoundariesStructural(records [][]string) (int, int) {
 	if len(records) == 0 {
 		return 0, 0
 	}
@@ -461,147 +1359,346 @@ func (ec *ExcelConverter) checkStructuralConsistency(records [][]string, startRo
 	return float64(matches) / float64(totalRows)
 }
 
-// ListSheets returns information about all sheets in the Excel file
-func (ec *ExcelConverter) ListSheets(inputPath string) ([]SheetInfo, error) {
-	// Check if LibreOffice is available
-	_, err := exec.LookPath("libreoffice")
-	if err != nil {
-		return nil, fmt.Errorf("LibreOffice is not available. Please install LibreOffice")
+// sheetsToConvert lists the sheets converted in all sheets mode in workbook order,
+// limited to the sheets matching SheetPattern if set
+func (ec *ExcelConverter) sheetsToConvert(inputPath string) ([]SheetInfo, error) {
+	var pattern *regexp.Regexp
+	if ec.SheetPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(ec.SheetPattern); err != nil {
+			return nil, fmt.Errorf("invalid sheet pattern: %w", err)
+		}
 	}
 
-	// Create temp directory
-	homeDir, _ := os.UserHomeDir()
-	tempDir := filepath.Join(homeDir, "excel2csv_temp_sheets")
-	_ = os.MkdirAll(tempDir, 0755)
-	defer func() { _ = os.RemoveAll(tempDir) }()
-
-	// Use simpler fallback method by default (more reliable)
-	return ec.fallbackListSheets(inputPath, tempDir)
-}
+	sheets, err := ec.ListSheets(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sheets: %w", err)
+	}
 
-// fallbackListSheets tries to detect sheets by attempting conversions
-func (ec *ExcelConverter) fallbackListSheets(inputPath, tempDir string) ([]SheetInfo, error) {
-	var sheets []SheetInfo
-	absInputPath, _ := filepath.Abs(inputPath)
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("no sheets found in file")
+	}
+	if err := ec.checkSheetOverrides(sheets); err != nil {
+		return nil, err
+	}
 
-	fmt.Printf("Detecting sheets in %s...\n", filepath.Base(inputPath))
-
-	// Since --sheet parameter is not supported, we can only reliably detect the first sheet
-	// For now, just try to convert the default sheet and assume it exists
-	fmt.Printf("Checking sheet 0... ")
-
-	cmd := exec.Command("libreoffice", "--headless", "--convert-to", "csv",
-		"--outdir", tempDir, absInputPath)
-
-	// Set a timeout to avoid hanging
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	cmd = exec.CommandContext(ctx, cmd.Args[0], cmd.Args[1:]...)
-
-	_, err := cmd.CombinedOutput()
-	if err == nil {
-		// Check if a CSV file was actually created
-		files, _ := os.ReadDir(tempDir)
-		csvFound := false
-		for _, file := range files {
-			if strings.HasSuffix(strings.ToLower(file.Name()), ".csv") {
-				csvFound = true
-				// Clean up the CSV file
-				os.Remove(filepath.Join(tempDir, file.Name()))
-				break
+	if pattern != nil {
+		var matching []SheetInfo
+		for _, sheet := range sheets {
+			if pattern.MatchString(sheet.Name) {
+				matching = append(matching, sheet)
 			}
 		}
-
-		if csvFound {
-			sheets = append(sheets, SheetInfo{
-				Index: 0,
-				Name:  "Sheet1",
-			})
-			fmt.Printf("✓ found\n")
-		} else {
-			fmt.Printf("✗ no output\n")
+		if len(matching) == 0 {
+			return nil, fmt.Errorf("no sheets match pattern %q", ec.SheetPattern)
 		}
-	} else {
-		fmt.Printf("✗ error\n")
-	}
-
-	if len(sheets) == 0 {
-		// Fallback - assume at least one sheet exists
-		sheets = append(sheets, SheetInfo{
-			Index: 0,
-			Name:  "Sheet1",
-		})
+		sheets = matching
 	}
 
-	fmt.Printf("Note: Advanced multi-sheet detection requires LibreOffice version with --sheet support\n")
+	// Output files are numbered by index, keep them in workbook order
+	sort.SliceStable(sheets, func(i, j int) bool { return sheets[i].Index < sheets[j].Index })
 	return sheets, nil
 }
 
 // ConvertAllSheetsToFiles converts all sheets to separate CSV files
 func (ec *ExcelConverter) ConvertAllSheetsToFiles(inputPath, outputDir string) error {
-	sheets, err := ec.ListSheets(inputPath)
+	_, err := ec.ConvertAllSheetsWithReport(inputPath, outputDir)
+	return err
+}
+
+// convertedTable exports the selected sheet and returns its detected table, transformed
+// for output, and the row (0-based) the table starts at in the sheet, -1 for an empty sheet
+func (ec *ExcelConverter) convertedTable(ctx context.Context, inputPath string) ([][]string, int, error) {
+	records, err := ec.exportRecords(ctx, inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to list sheets: %w", err)
+		return nil, -1, err
 	}
+	return ec.detectedTable(records)
+}
 
-	if len(sheets) == 0 {
-		return fmt.Errorf("no sheets found in file")
+// detectedTable detects the table in the rows of a sheet and transforms it for output
+func (ec *ExcelConverter) detectedTable(records [][]string) ([][]string, int, error) {
+	tableStart := -1
+	if len(records) > 0 {
+		var tableEnd int
+		tableStart, tableEnd = ec.tableBoundaries(records)
+		if err := ec.writeDiagnostics(records, tableStart, tableEnd); err != nil {
+			return nil, tableStart, err
+		}
+		records = records[tableStart : tableEnd+1]
+	} else if err := ec.writeDiagnostics(records, 0, 0); err != nil {
+		return nil, tableStart, err
 	}
 
-	// Create output directory if it doesn't exist
-	err = os.MkdirAll(outputDir, 0755)
+	records, err := ec.transformTable(records)
+	return records, tableStart, err
+}
+
+// ConvertAllSheetsToZip converts all sheets and streams them to w as a ZIP archive,
+// one CSV entry per sheet (or per table with DetectMultipleTables) in workbook order
+func (ec *ExcelConverter) ConvertAllSheetsToZip(inputPath string, w io.Writer) error {
+	return ec.ConvertAllSheetsToZipContext(context.Background(), inputPath, w)
+}
+
+// ConvertAllSheetsToZipContext is like ConvertAllSheetsToZip, but stops converting
+// and kills LibreOffice when ctx is done
+func (ec *ExcelConverter) ConvertAllSheetsToZipContext(ctx context.Context, inputPath string, w io.Writer) error {
+	_, err := ec.convertAllSheetsToZip(ctx, inputPath, w)
+	return err
+}
+
+// convertAllSheetsToZip writes the ZIP archive of all sheets to w and reports what was written
+func (ec *ExcelConverter) convertAllSheetsToZip(ctx context.Context, inputPath string, w io.Writer) (ConvertStats, error) {
+	stats := ConvertStats{DetectedHeaderRow: -1}
+
+	if err := ec.checkFilenameTemplate(); err != nil {
+		return stats, err
+	}
+
+	sheets, err := ec.sheetsToConvert(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		return stats, err
 	}
 
-	// Convert each sheet
-	for _, sheet := range sheets {
-		// Generate output filename
-		baseName := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
-		outputFile := filepath.Join(outputDir, fmt.Sprintf("%s_sheet_%d_%s.csv", baseName, sheet.Index+1, sheet.Name))
+	counter := &countingWriter{w: w}
+	zipWriter := zip.NewWriter(counter)
+	names := make(uniqueFileNames)
 
-		// Clean filename
-		outputFile = strings.ReplaceAll(outputFile, " ", "_")
-		outputFile = strings.ReplaceAll(outputFile, "/", "_")
-		outputFile = strings.ReplaceAll(outputFile, "\\", "_")
+	for done, sheet := range sheets {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
 
-		fmt.Printf("Converting sheet %d (%s) to %s\n", sheet.Index+1, sheet.Name, outputFile)
+		fmt.Printf("Converting sheet %d (%s) to ZIP\n", sheet.Index+1, sheet.Name)
 
 		// Create a temporary converter for this sheet
-		tempConverter := *ec
-		tempConverter.SheetIndex = &sheet.Index
-		tempConverter.AllSheetsMode = false
+		tempConverter := ec.sheetConverter(sheet)
 
-		err = tempConverter.ConvertFile(inputPath, outputFile)
+		tables, err := tempConverter.sheetTables(ctx, inputPath)
+		if errors.Is(err, ErrRowLimitExceeded) {
+			// A limit applies to the whole archive, don't send it without the sheet
+			return stats, fmt.Errorf("sheet %s: %w", sheet.Name, err)
+		}
 		if err != nil {
 			fmt.Printf("Warning: failed to convert sheet %s: %v\n", sheet.Name, err)
+			stats.FailedSheets = append(stats.FailedSheets, sheet.Name)
+			ec.progress(done+1, len(sheets), "sheets")
+			continue
+		}
+
+		if !hasDataRows(tables) {
+			stats.EmptySheets = append(stats.EmptySheets, sheet.Name)
+			if !ec.IncludeEmptySheets {
+				fmt.Printf("Skipping empty sheet %s\n", sheet.Name)
+				ec.progress(done+1, len(sheets), "sheets")
+				continue
+			}
 		}
+
+		if err := tempConverter.writeZipTables(zipWriter, names, inputPath, sheet, tables, &stats); err != nil {
+			if counter.err != nil {
+				// The archive itself can't be written anymore
+				return stats, err
+			}
+			// The entry written so far stays in the archive, truncated
+			fmt.Printf("Warning: failed to write sheet %s: %v\n", sheet.Name, err)
+			stats.FailedSheets = append(stats.FailedSheets, sheet.Name)
+			ec.progress(done+1, len(sheets), "sheets")
+			continue
+		}
+
+		stats.SheetsConverted++
+		ec.progress(done+1, len(sheets), "sheets")
 	}
 
+	err = zipWriter.Close()
+	stats.BytesWritten = counter.n
+	return stats, err
+}
+
+// writeZipTables writes the tables of a sheet, and their DDL if EmitDDL is set, as ZIP entries
+func (ec *ExcelConverter) writeZipTables(zipWriter *zip.Writer, names uniqueFileNames, inputPath string, sheet SheetInfo, tables [][][]string, stats *ConvertStats) error {
+	for i, table := range tables {
+		entryName, err := ec.sheetFileName(inputPath, sheet)
+		if err != nil {
+			return err
+		}
+		if len(tables) > 1 {
+			entryName = tableFileName(entryName, i)
+		}
+		entryName = names.claim(entryName)
+
+		entry := &zipEntryWriter{zip: zipWriter, name: entryName}
+		if err := ec.writeMetadataComment(entry, inputPath, sheet.Name, table); err != nil {
+			return fmt.Errorf("failed to write ZIP entry %s: %w", entryName, err)
+		}
+		if err := ec.writeRecords(entry, table); err != nil {
+			return fmt.Errorf("failed to write ZIP entry %s: %w", entryName, err)
+		}
+		stats.RowsWritten += len(ec.outputRows(table))
+
+		if ec.EmitDDL != "" {
+			if err := ec.writeZipDDL(zipWriter, ddlPath(entryName), table); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-// convertSpecificSheet converts a specific sheet by index or name
-func (ec *ExcelConverter) convertSpecificSheet(inputPath, tempDir string, sheetIndex int, sheetName string) error {
-	absInputPath, _ := filepath.Abs(inputPath)
+// sheetConverter returns a copy of ec that converts one sheet of all sheets mode,
+// with the sheet's name, diagnostics file and SheetOverrides
+func (ec *ExcelConverter) sheetConverter(sheet SheetInfo) ExcelConverter {
+	converter := *ec
+	converter.SheetName = sheet.Name
+	converter.SheetIndex = &sheet.Index
+	converter.AllSheetsMode = false
+	converter.SheetPattern = ""
+	converter.DiagnosticsPath = ec.sheetDiagnosticsPath(sheet.Index)
+	converter.applySheetOverrides(sheet.Name)
+	return converter
+}
 
-	var cmd *exec.Cmd
-	if sheetName != "" {
-		// Convert by sheet name
-		cmd = exec.Command("libreoffice", "--headless", "--convert-to", "csv",
-			"--outdir", tempDir, "--sheet", sheetName, absInputPath)
-	} else {
-		// Convert by sheet index
-		cmd = exec.Command("libreoffice", "--headless", "--convert-to", "csv",
-			"--outdir", tempDir, "--sheet", fmt.Sprintf("%d", sheetIndex), absInputPath)
+// zipEntryWriter creates its ZIP entry on the first write,
+// so sheets that fail before producing output leave no empty entries
+type zipEntryWriter struct {
+	zip   *zip.Writer
+	name  string
+	entry io.Writer
+}
+
+func (z *zipEntryWriter) Write(p []byte) (int, error) {
+	if z.entry == nil {
+		entry, err := z.zip.Create(z.name)
+		if err != nil {
+			return 0, err
+		}
+		z.entry = entry
 	}
+	return z.entry.Write(p)
+}
 
-	output, err := cmd.CombinedOutput()
+// DefaultFilenameTemplate names the files of all sheets mode like "report_sheet_1_Sales.csv"
+const DefaultFilenameTemplate = "{{.Base}}_sheet_{{.SheetNumber}}_{{.SheetName}}{{.Ext}}"
+
+// SheetFileData holds the values available to FilenameTemplate
+type SheetFileData struct {
+	Base        string // input file name without extension
+	SheetIndex  int    // 0-based sheet index
+	SheetNumber int    // 1-based sheet number
+	SheetName   string
+	Ext         string // extension of the output format: ".csv", ".tsv" or ".parquet"
+}
+
+// filenameTemplate parses FilenameTemplate, or DefaultFilenameTemplate if it is empty
+func (ec *ExcelConverter) filenameTemplate() (*template.Template, error) {
+	text := ec.FilenameTemplate
+	if text == "" {
+		text = DefaultFilenameTemplate
+	}
+	tmpl, err := template.New("filename").Parse(text)
 	if err != nil {
-		return fmt.Errorf("LibreOffice conversion failed: %w, output: %s", err, string(output))
+		return nil, fmt.Errorf("invalid filename template: %w", err)
 	}
+	return tmpl, nil
+}
 
-	return nil
+// checkFilenameTemplate renders FilenameTemplate for a sample sheet, so a broken
+// template fails before any sheet is converted
+func (ec *ExcelConverter) checkFilenameTemplate() error {
+	_, err := ec.sheetFileName("book.xlsx", SheetInfo{Index: 0, Name: "Sheet1"})
+	return err
+}
+
+// sheetFileName builds the per-sheet output file name used in all sheets mode
+// from FilenameTemplate, made safe for the file system
+func (ec *ExcelConverter) sheetFileName(inputPath string, sheet SheetInfo) (string, error) {
+	tmpl, err := ec.filenameTemplate()
+	if err != nil {
+		return "", err
+	}
+
+	var name strings.Builder
+	err = tmpl.Execute(&name, SheetFileData{
+		Base:        strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath)),
+		SheetIndex:  sheet.Index,
+		SheetNumber: sheet.Index + 1,
+		SheetName:   sheet.Name,
+		Ext:         ec.outputExt(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid filename template: %w", err)
+	}
+
+	fileName := SafeFileName(name.String())
+	if strings.Trim(fileName, ".") == "" {
+		return "", fmt.Errorf("filename template gives the file name %q for sheet %s", fileName, sheet.Name)
+	}
+	return fileName, nil
+}
+
+// uniqueFileNames tracks the file names written in all sheets mode, so sheets whose
+// names render to the same file don't overwrite each other
+type uniqueFileNames map[string]bool
+
+// claim returns name, or if a file of the same name without extension was written
+// already, name numbered before the extension. Names are compared case-insensitively
+// like on Windows and macOS, and without extension so DDL files stay apart too.
+func (u uniqueFileNames) claim(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	unique := name
+	for n := 2; u[strings.ToLower(strings.TrimSuffix(unique, ext))]; n++ {
+		unique = fmt.Sprintf("%s_%d%s", base, n, ext)
+	}
+	u[strings.ToLower(strings.TrimSuffix(unique, ext))] = true
+
+	if unique != name {
+		fmt.Printf("Warning: file name %s is already used, writing %s\n", name, unique)
+	}
+	return unique
+}
+
+// outputExt returns the file extension of the output format
+func (ec *ExcelConverter) outputExt() string {
+	switch ec.OutputFormat {
+	case FormatTSV:
+		return ".tsv"
+	case FormatParquet:
+		return ".parquet"
+	default:
+		return ".csv"
+	}
+}
+
+// windowsReservedNames are device names Windows doesn't allow as file names,
+// whatever their case and extension
+var windowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// SafeFileName replaces spaces, path separators and characters not allowed in file names
+// on Windows, so names taken from sheets or URLs can be used as file names on any system.
+// Trailing dots, which Windows drops, become underscores, and reserved device names
+// such as CON or NUL.csv get an underscore prefix.
+func SafeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(` /\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if trimmed := strings.TrimRight(name, "."); trimmed != name && trimmed != "" {
+		name = trimmed + strings.Repeat("_", len(name)-len(trimmed))
+	}
+	stem, _, _ := strings.Cut(name, ".")
+	if slices.ContainsFunc(windowsReservedNames, func(reserved string) bool { return strings.EqualFold(stem, reserved) }) {
+		name = "_" + name
+	}
+	return name
 }
 
 // Helper functions
@@ -634,17 +1731,54 @@ func (ec *ExcelConverter) countNumericCells(record []string) int {
 	return count
 }
 
+// ParseNumber parses a cell the way table detection, numeric filters and Parquet column
+// types see it. Thousands separators and spaces of the NumberLocale are ignored, so
+// "1,234.5" (or "1.234,5" with NumberLocaleEU) is 1234.5. Signs and exponents are
+// accepted ("-5", "1.5e3"). Currency symbols and percent signs are not, so "$5" and
+// "15%" are text, and so are "NaN" and "Inf".
+func (ec *ExcelConverter) ParseNumber(value string) (float64, bool) {
+	return ec.parseNumber(value)
+}
+
+// CleanCell applies the built-in cell cleaning to text: line breaks are replaced with
+// spaces if CleanLineBreaks is set, runs of spaces are collapsed and the text is trimmed
+// if CollapseSpaces is set. Other whitespace, such as tabs, is kept.
+func (ec *ExcelConverter) CleanCell(text string) string {
+	return ec.cleanCellData(text)
+}
+
 func (ec *ExcelConverter) looksLikeNumber(value string) bool {
+	_, ok := ec.parseNumber(value)
+	return ok
+}
+
+// parseNumber parses a cell value as a number, ignoring common number formatting
+func (ec *ExcelConverter) parseNumber(value string) (float64, bool) {
 	if value == "" {
-		return false
+		return 0, false
+	}
+
+	number, err := strconv.ParseFloat(ec.normalizeNumber(value), 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, false
+	}
+	return number, true
+}
+
+// normalizeNumber removes the number formatting of the configured locale,
+// leaving a value strconv can parse
+func (ec *ExcelConverter) normalizeNumber(value string) string {
+	if ec.NumberLocale == NumberLocaleEU {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, " ", "")
+		value = strings.ReplaceAll(value, "\u00a0", "") // no-break space
+		value = strings.ReplaceAll(value, "\u202f", "") // narrow no-break space, used by French formatting
+		return strings.ReplaceAll(value, ",", ".")
 	}
 
-	// Remove common number formatting
 	value = strings.ReplaceAll(value, ",", "")
 	value = strings.ReplaceAll(value, " ", "")
-
-	_, err := strconv.ParseFloat(value, 64)
-	return err == nil
+	return value
 }
 
 func (ec *ExcelConverter) getExpectedColumnCount(records [][]string, startRow int) int {
@@ -671,23 +1805,23 @@ func abs(x int) int {
 	return x
 }
 
-// cleanCellData cleans problematic characters from cell data
+// cleanCellData replaces line breaks with spaces, if CleanLineBreaks is set, and
+// collapses runs of spaces and trims the cell, if CollapseSpaces is set
 func (ec *ExcelConverter) cleanCellData(text string) string {
-	if !ec.CleanLineBreaks {
-		return text
+	if ec.CleanLineBreaks {
+		text = strings.ReplaceAll(text, "\r\n", " ")
+		text = strings.ReplaceAll(text, "\n", " ")
+		text = strings.ReplaceAll(text, "\r", " ")
 	}
 
-	// Replace line breaks with spaces
-	text = strings.ReplaceAll(text, "\n", " ")
-	text = strings.ReplaceAll(text, "\r", " ")
-	text = strings.ReplaceAll(text, "\r\n", " ")
-
-	// Clean up multiple spaces
-	for strings.Contains(text, "  ") {
-		text = strings.ReplaceAll(text, "  ", " ")
+	if ec.CollapseSpaces {
+		for strings.Contains(text, "  ") {
+			text = strings.ReplaceAll(text, "  ", " ")
+		}
+		text = strings.TrimSpace(text)
 	}
 
-	return strings.TrimSpace(text)
+	return text
 }
 
 // Helper function for min (renamed to avoid collision with builtin)
@@ -0,0 +1,496 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSafeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Sales", "Sales"},
+		{"Q1/Q2 *draft*", "Q1_Q2__draft_"},
+		{`a\b:c?d"e<f>g|h`, "a_b_c_d_e_f_g_h"},
+		{"tab\there", "tab_here"},
+		{"Totals.", "Totals_"},
+		{"v1.2..", "v1.2__"},
+		{"CON", "_CON"},
+		{"nul.csv", "_nul.csv"},
+		{"Com1", "_Com1"},
+		{"CONSOLE", "CONSOLE"},
+		{"LPT10", "LPT10"},
+		{"Übersicht", "Übersicht"},
+	}
+
+	for _, tt := range tests {
+		if got := SafeFileName(tt.name); got != tt.want {
+			t.Errorf("SafeFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSheetFileName(t *testing.T) {
+	tests := []struct {
+		template string
+		sheet    SheetInfo
+		want     string
+	}{
+		{"", SheetInfo{Index: 1, Name: "Q1/Q2 *draft*"}, "report_sheet_2_Q1_Q2__draft_.csv"},
+		{"", SheetInfo{Index: 0, Name: "Sales"}, "report_sheet_1_Sales.csv"},
+		{"{{.SheetName}}{{.Ext}}", SheetInfo{Index: 0, Name: "CON"}, "_CON.csv"},
+		{"{{.SheetNumber}}-{{.SheetName}}{{.Ext}}", SheetInfo{Index: 2, Name: "a:b"}, "3-a_b.csv"},
+	}
+
+	for _, tt := range tests {
+		converter := NewExcelConverter()
+		converter.FilenameTemplate = tt.template
+		got, err := converter.sheetFileName("in/report.xlsx", tt.sheet)
+		if err != nil {
+			t.Errorf("sheetFileName(%q, %q): %v", tt.template, tt.sheet.Name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sheetFileName(%q, %q) = %q, want %q", tt.template, tt.sheet.Name, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueFileNames(t *testing.T) {
+	names := make(uniqueFileNames)
+	for _, tt := range []struct{ name, want string }{
+		{"Sales.csv", "Sales.csv"},
+		{"sales.csv", "sales_2.csv"},
+		{"Sales.csv", "Sales_3.csv"},
+		{"Sales.tsv", "Sales_4.tsv"}, // would share Sales.sql with Sales.csv
+		{"Sales_2.csv", "Sales_2_2.csv"},
+		{"Costs.csv", "Costs.csv"},
+	} {
+		if got := names.claim(tt.name); got != tt.want {
+			t.Errorf("claim(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadCSVFile(t *testing.T) {
+	long := strings.Repeat("äöü€", 3000) // runes cross the read buffers
+	tests := []struct {
+		name    string
+		content string
+		maxRows int
+		want    [][]string
+		wantErr error
+	}{
+		{"utf8", "Name,City\nJosé,Zürich\n", 0, [][]string{{"Name", "City"}, {"José", "Zürich"}}, nil},
+		{"bom", "\xEF\xBB\xBFName\nx\n", 0, [][]string{{"Name"}, {"x"}}, nil},
+		{"latin1", "Name,City\nJos\xE9,Z\xFCrich\n", 0, [][]string{{"Name", "City"}, {"José", "Zürich"}}, nil},
+		{"replacement character", "a,\uFFFD\n", 0, [][]string{{"a", "\uFFFD"}}, nil},
+		{"long cells", long + "," + long + "\n", 0, [][]string{{long, long}}, nil},
+		{"quoted line break", "a,\"b\nc\"\n", 0, [][]string{{"a", "b\nc"}}, nil},
+		{"within limit", "a\nb\n", 2, [][]string{{"a"}, {"b"}}, nil},
+		{"over limit", "a\nb\nc\n", 2, nil, ErrRowLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "export.csv")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readCSVFile(path, ',', tt.maxRows)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readCSVFile() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readCSVFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testSheet is a sheet of a workbook written by writeWorkbook
+type testSheet struct {
+	name    string
+	records [][]string
+	names   []testName  // defined names, listed in the workbook
+	tables  []testTable // table objects on the sheet
+}
+
+// testName is a defined name of a workbook written by writeWorkbook
+type testName struct {
+	name, value string
+	local       bool // scoped to the sheet it is given with, not the workbook
+}
+
+// testTable is a table object of a sheet written by writeWorkbook
+type testTable struct {
+	name, ref  string
+	totalsRows int
+}
+
+// writeXLSX writes records as the only sheet of a minimal xlsx workbook, every
+// cell an inline string so LibreOffice exports the text unchanged
+func writeXLSX(t *testing.T, path string, records [][]string) {
+	t.Helper()
+	writeWorkbook(t, path, testSheet{name: "Sheet1", records: records})
+}
+
+// writeWorkbook writes a minimal xlsx workbook with sheets in order
+func writeWorkbook(t *testing.T, path string, sheets ...testSheet) {
+	t.Helper()
+	var overrides, sheetList, relationships, definedNames strings.Builder
+	var worksheets []zipPart
+	tableCount := 0
+	for n, s := range sheets {
+		var sheet strings.Builder
+		sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+		for i, record := range s.records {
+			fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+			for j, cell := range record {
+				if cell == "" {
+					continue
+				}
+				fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, cellName(i, j))
+				_ = xml.EscapeText(&sheet, []byte(cell))
+				sheet.WriteString(`</t></is></c>`)
+			}
+			sheet.WriteString(`</row>`)
+		}
+		sheet.WriteString(`</sheetData></worksheet>`)
+
+		partName := fmt.Sprintf("worksheets/sheet%d.xml", n+1)
+		worksheets = append(worksheets, zipPart{"xl/" + partName, sheet.String()})
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/%s" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, partName)
+		fmt.Fprintf(&sheetList, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlAttr(s.name), n+1, n+1)
+		fmt.Fprintf(&relationships, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="%s"/>`, n+1, partName)
+
+		for _, name := range s.names {
+			scope := ""
+			if name.local {
+				scope = fmt.Sprintf(` localSheetId="%d"`, n)
+			}
+			fmt.Fprintf(&definedNames, `<definedName name="%s"%s>`, xmlAttr(name.name), scope)
+			_ = xml.EscapeText(&definedNames, []byte(name.value))
+			definedNames.WriteString(`</definedName>`)
+		}
+
+		// Tables are related to the sheet, with targets relative to the sheet part
+		if len(s.tables) > 0 {
+			var tableRels strings.Builder
+			for i, table := range s.tables {
+				tableCount++
+				fmt.Fprintf(&tableRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/table" Target="../tables/table%d.xml"/>`, i+1, tableCount)
+				worksheets = append(worksheets, zipPart{fmt.Sprintf("xl/tables/table%d.xml", tableCount),
+					`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+						fmt.Sprintf(`<table xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" id="%d" name="%s" displayName="%s" ref="%s" totalsRowCount="%d"/>`,
+							tableCount, xmlAttr(table.name), xmlAttr(table.name), table.ref, table.totalsRows)})
+			}
+			worksheets = append(worksheets, zipPart{fmt.Sprintf("xl/worksheets/_rels/sheet%d.xml.rels", n+1),
+				`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+					`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+					tableRels.String() + `</Relationships>`})
+		}
+	}
+	workbookNames := ""
+	if definedNames.Len() > 0 {
+		workbookNames = `<definedNames>` + definedNames.String() + `</definedNames>`
+	}
+
+	parts := append([]zipPart{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheetList.String() + `</sheets>` + workbookNames + `</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			relationships.String() + `</Relationships>`},
+	}, worksheets...)
+	writeZip(t, path, parts...)
+}
+
+// xmlAttr escapes s for an XML attribute value
+func xmlAttr(s string) string {
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(s))
+	return escaped.String()
+}
+
+// zipPart is a file written by writeZip
+type zipPart struct{ name, content string }
+
+// writeZip writes parts in order as a ZIP archive
+func writeZip(t *testing.T, path string, parts ...zipPart) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	archive := zip.NewWriter(file)
+	for _, part := range parts {
+		w, err := archive.Create(part.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConvertFileGolden converts the fixtures through LibreOffice and compares
+// the output with the same golden files as TestConvertRecordsGolden
+func TestConvertFileGolden(t *testing.T) {
+	if _, err := FindLibreOffice(); err != nil {
+		t.Skip("LibreOffice is not installed")
+	}
+	if *updateGolden {
+		t.Skip("golden files are written by TestConvertRecordsGolden")
+	}
+
+	tests := []struct {
+		fixture string
+		golden  string
+	}{
+		{"report.csv", "report.golden.csv"},
+		{"ledger.csv", "ledger.golden.csv"},
+		{"cities.csv", "cities.golden.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, strings.TrimSuffix(tt.fixture, ".csv")+".xlsx")
+			writeXLSX(t, inputPath, readFixture(t, tt.fixture))
+
+			outputPath := filepath.Join(dir, "out.csv")
+			converter := NewExcelConverter()
+			converter.TempDir = dir
+			if err := converter.ConvertFile(inputPath, outputPath); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, got)
+		})
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		lineEnding LineEnding
+		records    [][]string
+		want       string
+	}{
+		{"plain", LineEndingLF, [][]string{{"Name", "Qty"}, {"a", "1"}}, "Name\tQty\na\t1\n"},
+		{"tab", LineEndingLF, [][]string{{"a\tb", "c"}}, "a\\tb\tc\n"},
+		{"line breaks", LineEndingLF, [][]string{{"one\ntwo", "three\r\nfour", "five\rsix"}}, "one\\ntwo\tthree\\r\\nfour\tfive\\rsix\n"},
+		{"backslash", LineEndingLF, [][]string{{`C:\temp\new`, `\t`}}, "C:\\\\temp\\\\new\t\\\\t\n"},
+		{"quotes and commas", LineEndingLF, [][]string{{`he said "hi"`, "a,b"}}, "he said \"hi\"\ta,b\n"},
+		{"crlf", LineEndingCRLF, [][]string{{"a", "b"}, {"c\nd", ""}}, "a\tb\r\nc\\nd\t\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			converter.LineEnding = tt.lineEnding
+
+			var out strings.Builder
+			if err := converter.writeTSV(&out, tt.records); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("writeTSV() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVExportFilterCharset(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(ec *ExcelConverter)
+	}{
+		{"default", func(ec *ExcelConverter) {}},
+		{"separator", func(ec *ExcelConverter) { ec.CSVSeparator = ';' }},
+		{"raw values", func(ec *ExcelConverter) { ec.RawValues = true }},
+		{"tsv", func(ec *ExcelConverter) { ec.OutputFormat = FormatTSV }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			tt.configure(converter)
+
+			filter := converter.csvExportFilter(0)
+			_, options, _ := strings.Cut(filter, "(StarCalc):")
+			if fields := strings.Split(options, ","); len(fields) < 3 || fields[2] != "UTF8" {
+				t.Errorf("csvExportFilter(0) = %q, want the UTF8 charset", filter)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		value  string
+		locale NumberLocale
+		want   float64
+		wantOK bool
+	}{
+		{"42", NumberLocaleEN, 42, true},
+		{"-5", NumberLocaleEN, -5, true},
+		{"+5", NumberLocaleEN, 5, true},
+		{"3.14", NumberLocaleEN, 3.14, true},
+		{".5", NumberLocaleEN, 0.5, true},
+		{"1,234.5", NumberLocaleEN, 1234.5, true},
+		{"1 234 567", NumberLocaleEN, 1234567, true},
+		{"1.5e3", NumberLocaleEN, 1500, true},
+		{"-2E-2", NumberLocaleEN, -0.02, true},
+		{"1.234,5", NumberLocaleEN, 1.2345, true}, // commas only group digits in NumberLocaleEN
+		{"1.234,5", NumberLocaleEU, 1234.5, true},
+		{"1.234", NumberLocaleEU, 1234, true},
+		{"3,14", NumberLocaleEU, 3.14, true},
+		{"-0,5", NumberLocaleEU, -0.5, true},
+		{"1 234,5", NumberLocaleEU, 1234.5, true},
+		{"1\u00a0234,5", NumberLocaleEU, 1234.5, true},
+		{"1\u202f234,5", NumberLocaleEU, 1234.5, true},
+		{"$5", NumberLocaleEN, 0, false},
+		{"5 €", NumberLocaleEU, 0, false},
+		{"15%", NumberLocaleEN, 0, false},
+		{"(5)", NumberLocaleEN, 0, false},
+		{"12 apples", NumberLocaleEN, 0, false},
+		{"N/A", NumberLocaleEN, 0, false},
+		{"NaN", NumberLocaleEN, 0, false},
+		{"Inf", NumberLocaleEN, 0, false},
+		{"-Infinity", NumberLocaleEN, 0, false},
+		{"", NumberLocaleEN, 0, false},
+		{" ", NumberLocaleEN, 0, false},
+	}
+
+	for _, tt := range tests {
+		converter := NewExcelConverter()
+		converter.NumberLocale = tt.locale
+		got, ok := converter.ParseNumber(tt.value)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("ParseNumber(%q) with locale %d = %v, %t, want %v, %t", tt.value, tt.locale, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCleanCell(t *testing.T) {
+	tests := []struct {
+		text            string
+		cleanLineBreaks bool
+		collapseSpaces  bool
+		want            string
+	}{
+		{"a\nb", true, false, "a b"},
+		{"a\r\nb", true, false, "a b"},
+		{"a\rb", true, false, "a b"},
+		{"a\nb", false, false, "a\nb"},
+		{"  a   b  ", false, true, "a b"},
+		{"  a   b  ", false, false, "  a   b  "},
+		{"a \n b", true, true, "a b"},
+		{"a\tb", true, true, "a\tb"},
+		{"", true, true, ""},
+	}
+
+	for _, tt := range tests {
+		converter := NewExcelConverter()
+		converter.CleanLineBreaks = tt.cleanLineBreaks
+		converter.CollapseSpaces = tt.collapseSpaces
+		if got := converter.CleanCell(tt.text); got != tt.want {
+			t.Errorf("CleanCell(%q) with line breaks %t, spaces %t = %q, want %q",
+				tt.text, tt.cleanLineBreaks, tt.collapseSpaces, got, tt.want)
+		}
+	}
+}
+
+func TestCSVQuotingRoundTrip(t *testing.T) {
+	records := [][]string{
+		{"Name", "Note", "Quote"},
+		{"a,b", "first line\nsecond line", `he said "hi"`},
+		{"a;b|c\td", "trailing\n", `""`},
+		{" leading space", "", `"quoted"`},
+	}
+
+	for _, separator := range []rune{',', ';', '\t', '|'} {
+		for _, lineEnding := range []LineEnding{LineEndingLF, LineEndingCRLF} {
+			converter := NewExcelConverter()
+			converter.CSVSeparator = separator
+			converter.LineEnding = lineEnding
+			converter.CleanLineBreaks = false
+			converter.DetectionStrategy = StrategyNone
+
+			var out bytes.Buffer
+			if _, err := converter.ConvertRecords(records, &out); err != nil {
+				t.Fatal(err)
+			}
+
+			reader := csv.NewReader(&out)
+			reader.Comma = separator
+			got, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("separator %q, line ending %d: reading output: %v", separator, lineEnding, err)
+			}
+			if !reflect.DeepEqual(got, records) {
+				t.Errorf("separator %q, line ending %d: read back %q, want %q", separator, lineEnding, got, records)
+			}
+		}
+	}
+}
+
+func TestWriteCSVQuoting(t *testing.T) {
+	converter := NewExcelConverter()
+	var out strings.Builder
+	err := converter.writeCSV(&out, [][]string{{"a,b", `he said "hi"`, "one\ntwo", "plain"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\"a,b\",\"he said \"\"hi\"\"\",\"one\ntwo\",plain\n"
+	if out.String() != want {
+		t.Errorf("writeCSV() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestCSVExportFilterSheet(t *testing.T) {
+	converter := NewExcelConverter()
+	if fields := strings.Split(converter.csvExportFilter(0), ","); len(fields) != 10 {
+		t.Errorf("csvExportFilter(0) has %d options, want 10 without a sheet", len(fields))
+	}
+	// The sheet is the twelfth option, after the import only "remove spaces"
+	filter := converter.csvExportFilter(3)
+	if fields := strings.Split(filter, ","); len(fields) != 12 || fields[11] != "3" {
+		t.Errorf("csvExportFilter(3) = %q, want sheet 3 as the twelfth option", filter)
+	}
+}
@@ -0,0 +1,302 @@
+//go:build unix
+
+package excel2csv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLibreOffice installs a soffice script that exports a CSV with the input's base
+// name in its only data row and appends its profile directory to the returned log.
+// Like LibreOffice it leaves a scratch file in TMPDIR.
+func fakeLibreOffice(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "profiles.log")
+	script := filepath.Join(dir, "soffice")
+	content := `#!/bin/sh
+for arg; do
+	case $prev in --outdir) out=$arg ;; esac
+	case $arg in -env:UserInstallation=*) profile=${arg#-env:UserInstallation=} ;; esac
+	prev=$arg
+done
+name=$(basename "$arg" .xlsx)
+echo "$profile" >> ` + logPath + `
+touch "$TMPDIR/lu_scratch.tmp"
+printf 'Name,Qty\n%s,1\n' "$name" > "$out/$name.csv"
+`
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LIBREOFFICE_PATH", script)
+	return logPath
+}
+
+func TestConcurrentConversions(t *testing.T) {
+	logPath := fakeLibreOffice(t)
+	inputDir := t.TempDir()
+	tempDir := t.TempDir()
+
+	const conversions = 8
+	outputs := make([]strings.Builder, conversions)
+	errs := make([]error, conversions)
+	var wg sync.WaitGroup
+	for i := range conversions {
+		inputPath := filepath.Join(inputDir, "book"+string(rune('a'+i))+".xlsx")
+		if err := os.WriteFile(inputPath, []byte("PK\x03\x04"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			converter := NewExcelConverter()
+			converter.TempDir = tempDir
+			errs[i] = converter.ConvertTo(inputPath, &outputs[i])
+		}()
+	}
+	wg.Wait()
+
+	for i := range conversions {
+		want := "Name,Qty\nbook" + string(rune('a'+i)) + ",1\n"
+		if errs[i] != nil {
+			t.Errorf("conversion %d: %v", i, errs[i])
+		} else if outputs[i].String() != want {
+			t.Errorf("conversion %d wrote %q, want %q", i, outputs[i].String(), want)
+		}
+	}
+
+	// Every LibreOffice run needs a profile of its own
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles := strings.Fields(string(data))
+	seen := make(map[string]bool)
+	for _, profile := range profiles {
+		if seen[profile] {
+			t.Errorf("profile %s was used by more than one run", profile)
+		}
+		seen[profile] = true
+	}
+	if len(profiles) != conversions {
+		t.Errorf("LibreOffice ran %d times, want %d", len(profiles), conversions)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("temp directory not cleaned up, %d entries left", len(entries))
+	}
+}
+
+func TestTempFilesStayInTempDir(t *testing.T) {
+	tests := []struct {
+		name          string
+		configTempDir bool
+	}{
+		{"TempDir set", true},
+		{"system temp directory", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeLibreOffice(t)
+			inputPath := filepath.Join(t.TempDir(), "book.xlsx")
+			if err := os.WriteFile(inputPath, []byte("PK\x03\x04"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			homeDir, systemTempDir, tempDir := t.TempDir(), t.TempDir(), t.TempDir()
+			t.Setenv("HOME", homeDir)
+			t.Setenv("TMPDIR", systemTempDir)
+
+			converter := NewExcelConverter()
+			if tt.configTempDir {
+				converter.TempDir = tempDir
+			}
+			var out strings.Builder
+			if err := converter.ConvertTo(inputPath, &out); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, dir := range []string{homeDir, systemTempDir, tempDir} {
+				entries, err := os.ReadDir(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, entry := range entries {
+					t.Errorf("%s left in %s", entry.Name(), dir)
+				}
+			}
+		})
+	}
+}
+
+// fakeSheetLibreOffice installs a soffice script that reports version and exports a
+// CSV with the number of the sheet the export filter selects, "default" if none,
+// separated by the filter's field separator
+func fakeSheetLibreOffice(t *testing.T, version string) {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "soffice")
+	content := `#!/bin/sh
+sheet=default
+for arg; do
+	case $arg in --version) echo "LibreOffice ` + version + ` 40(Build:1)"; exit 0 ;; esac
+	case $prev in --outdir) out=$arg ;; --convert-to) filter=$arg ;; esac
+	prev=$arg
+done
+case $filter in *,*,*,*,*,*,*,*,*,*,*,*) sheet=${filter##*,} ;; esac
+code=${filter##*:}
+sep=$(printf "\\$(printf %o "${code%%,*}")")
+name=$(basename "$arg" .xlsx)
+printf 'Sheet%sBook\n%s%s%s\n' "$sep" "$sheet" "$sep" "$name" > "$out/$name-$sheet.csv"
+`
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LIBREOFFICE_PATH", script)
+}
+
+func TestConvertSelectedSheet(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	dir := t.TempDir()
+	workbook := filepath.Join(dir, "book.xlsx")
+	writeWorkbook(t, workbook,
+		testSheet{name: "Summary"}, testSheet{name: "Q1 Sales"}, testSheet{name: "Q2 Sales"}, testSheet{name: "Notes"})
+	single := filepath.Join(dir, "single.xlsx")
+	writeWorkbook(t, single, testSheet{name: "Data"})
+
+	tests := []struct {
+		name      string
+		version   string
+		path      string
+		configure func(ec *ExcelConverter)
+		want      string
+		wantErr   string
+	}{
+		{"default sheet", "7.6.4.1", workbook, func(ec *ExcelConverter) {}, "Sheet,Book\ndefault,book\n", ""},
+		{"sheet name", "7.6.4.1", workbook, func(ec *ExcelConverter) { ec.SheetName = "q2 sales" }, "Sheet,Book\n3,book\n", ""},
+		{"sheet index", "24.2.1.2", workbook, func(ec *ExcelConverter) { ec.SheetIndex = intPtr(3) }, "Sheet,Book\n4,book\n", ""},
+		{"unknown sheet", "7.6.4.1", workbook, func(ec *ExcelConverter) { ec.SheetName = "Q3 Sales" }, "", "no sheet named"},
+		{"old LibreOffice", "7.1.8.1", workbook, func(ec *ExcelConverter) { ec.SheetIndex = intPtr(1) }, "", "needs LibreOffice 7.2"},
+		// A single sheet is exported by default, whatever the version
+		{"old LibreOffice single sheet", "7.1.8.1", single, func(ec *ExcelConverter) { ec.SheetName = "Data" }, "Sheet,Book\ndefault,single\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeSheetLibreOffice(t, tt.version)
+			converter := NewExcelConverter()
+			tt.configure(converter)
+
+			var out strings.Builder
+			err := converter.ConvertTo(tt.path, &out)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ConvertTo() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("ConvertTo() wrote %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertAllSheetsPattern(t *testing.T) {
+	fakeSheetLibreOffice(t, "7.6.4.1")
+	dir := t.TempDir()
+	workbook := filepath.Join(dir, "book.xlsx")
+	writeWorkbook(t, workbook,
+		testSheet{name: "Summary"}, testSheet{name: "Q1 Sales"}, testSheet{name: "Q2 Sales"}, testSheet{name: "Notes"})
+
+	converter := NewExcelConverter()
+	converter.SheetPattern = "^Q[1-4]"
+	outputDir := filepath.Join(dir, "out")
+	results, err := converter.ConvertAllSheetsWithReport(workbook, outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ name, file, sheet string }{
+		{"Q1 Sales", "book_sheet_2_Q1_Sales.csv", "2"},
+		{"Q2 Sales", "book_sheet_3_Q2_Sales.csv", "3"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("converted %d sheets, want %d: %+v", len(results), len(want), results)
+	}
+	for i, result := range results {
+		if result.Name != want[i].name || result.Error != "" {
+			t.Errorf("result %d = %+v, want sheet %q", i, result, want[i].name)
+			continue
+		}
+		if filepath.Base(result.OutputPath) != want[i].file {
+			t.Errorf("sheet %q written to %s, want %s", result.Name, result.OutputPath, want[i].file)
+		}
+		data, err := os.ReadFile(result.OutputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := "Sheet,Book\n" + want[i].sheet + ",book\n"; string(data) != got {
+			t.Errorf("sheet %q exported %q, want %q", result.Name, data, got)
+		}
+	}
+}
+
+func TestDetectTablesSheet(t *testing.T) {
+	fakeSheetLibreOffice(t, "7.6.4.1")
+	workbook := filepath.Join(t.TempDir(), "book.xlsx")
+	writeWorkbook(t, workbook, testSheet{name: "Summary"}, testSheet{name: "Data"})
+
+	regions, err := NewExcelConverter().DetectTables(workbook, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 1 || regions[0].Start != 0 || regions[0].End != 1 {
+		t.Errorf("DetectTables() = %+v, want one table in rows 0-1", regions)
+	}
+
+	if _, err := NewExcelConverter().DetectTables(workbook, 2); !errors.Is(err, ErrSheetNotFound) {
+		t.Errorf("DetectTables() of a missing sheet error = %v, want ErrSheetNotFound", err)
+	}
+}
+
+func TestConvertAllSheetsOverrides(t *testing.T) {
+	fakeSheetLibreOffice(t, "7.6.4.1")
+	dir := t.TempDir()
+	workbook := filepath.Join(dir, "book.xlsx")
+	writeWorkbook(t, workbook, testSheet{name: "Prices US"}, testSheet{name: "Prices EU"})
+
+	converter := NewExcelConverter()
+	converter.AllSheetsMode = true
+	converter.SheetOverrides = map[string]SheetOptions{"Prices EU": {CSVSeparator: ';'}}
+	results, err := converter.ConvertAllSheetsWithReport(workbook, filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"Prices US": "Sheet,Book\n1,book\n", "Prices EU": "Sheet;Book\n2;book\n"}
+	if len(results) != len(want) {
+		t.Fatalf("converted %d sheets, want %d: %+v", len(results), len(want), results)
+	}
+	for _, result := range results {
+		data, err := os.ReadFile(result.OutputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want[result.Name] {
+			t.Errorf("sheet %q written as %q, want %q", result.Name, data, want[result.Name])
+		}
+	}
+}
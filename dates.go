@@ -0,0 +1,69 @@
+package excel2csv
+
+import (
+	"strings"
+	"time"
+)
+
+// dateLayouts are the date formats LibreOffice uses when exporting date cells,
+// tried in order. Slash dates are month first for NumberLocaleEN.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"01/02/2006",
+	"1/2/2006",
+	"01/02/06",
+	"1/2/06",
+	"02.01.2006",
+	"2.1.2006",
+	"02.01.06",
+}
+
+// dateLayoutsEU are the date formats for NumberLocaleEU, with day first slash dates
+var dateLayoutsEU = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"02/01/2006",
+	"2/1/2006",
+	"02/01/06",
+	"2/1/06",
+	"02.01.2006",
+	"2.1.2006",
+	"02.01.06",
+}
+
+// timeSuffixes are the optional time parts following a date
+var timeSuffixes = []string{"", " 15:04:05", " 15:04", "T15:04:05", " 03:04:05 PM", " 03:04 PM"}
+
+// formatDate rewrites a cell that parses as a date with DateFormat.
+// Cells that don't look like a date are returned unchanged.
+func (ec *ExcelConverter) formatDate(value string) string {
+	if t, ok := ec.parseDate(value); ok {
+		return t.Format(ec.DateFormat)
+	}
+	return value
+}
+
+// parseDate parses a cell in one of the date formats LibreOffice exports
+func (ec *ExcelConverter) parseDate(value string) (time.Time, bool) {
+	trimmed := strings.TrimSpace(value)
+	// Cheap check before trying every layout: dates start with a digit and have a separator
+	if trimmed == "" || trimmed[0] < '0' || trimmed[0] > '9' || !strings.ContainsAny(trimmed, "-/.") {
+		return time.Time{}, false
+	}
+
+	layouts := dateLayouts
+	if ec.NumberLocale == NumberLocaleEU {
+		layouts = dateLayoutsEU
+	}
+
+	for _, layout := range layouts {
+		for _, suffix := range timeSuffixes {
+			if t, err := time.Parse(layout+suffix, trimmed); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
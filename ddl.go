@@ -0,0 +1,156 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ddlDialect holds the SQL types and identifier quoting of a database
+type ddlDialect struct {
+	types map[ColumnType]string
+	// quote doesn't escape " or `, it is safe only because sqlIdentifier strips them.
+	// Raw header or sheet names must never be passed to it.
+	quote func(name string) string
+}
+
+// ddlDialects are the values accepted by EmitDDL
+var ddlDialects = map[string]ddlDialect{
+	"postgres": {
+		types: map[ColumnType]string{
+			ColumnInt: "BIGINT", ColumnFloat: "DOUBLE PRECISION", ColumnDate: "DATE",
+			ColumnBool: "BOOLEAN", ColumnString: "TEXT",
+		},
+		quote: func(name string) string { return `"` + name + `"` },
+	},
+	"mysql": {
+		types: map[ColumnType]string{
+			ColumnInt: "BIGINT", ColumnFloat: "DOUBLE", ColumnDate: "DATE",
+			ColumnBool: "BOOLEAN", ColumnString: "TEXT",
+		},
+		quote: func(name string) string { return "`" + name + "`" },
+	},
+	"sqlite": {
+		types: map[ColumnType]string{
+			ColumnInt: "INTEGER", ColumnFloat: "REAL", ColumnDate: "TEXT",
+			ColumnBool: "INTEGER", ColumnString: "TEXT",
+		},
+		quote: func(name string) string { return `"` + name + `"` },
+	},
+}
+
+// checkDDLDialect fails for an EmitDDL value other than a known dialect or empty
+func checkDDLDialect(dialect string) error {
+	if _, ok := ddlDialects[dialect]; !ok && dialect != "" {
+		return fmt.Errorf("unknown DDL dialect %q, use postgres, mysql or sqlite", dialect)
+	}
+	return nil
+}
+
+// ddlPath returns the .sql file written next to outputPath
+func ddlPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".sql"
+}
+
+// writeDDL writes the CREATE TABLE statement of the converted table to path.
+// Nothing is written for a table without columns.
+func (ec *ExcelConverter) writeDDL(path, tableName string, columns []ColumnSchema) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	statement, err := ec.createTableStatement(tableName, columns)
+	if err != nil {
+		return err
+	}
+
+	file, err := ec.CreateOutput(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(statement); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeZipDDL adds the CREATE TABLE statement of a converted table to a ZIP archive
+func (ec *ExcelConverter) writeZipDDL(zipWriter *zip.Writer, name string, records [][]string) error {
+	columns := ec.inferColumns(records, true)
+	if len(columns) == 0 {
+		return nil
+	}
+
+	statement, err := ec.createTableStatement(ec.convertedSheetName(), columns)
+	if err != nil {
+		return err
+	}
+
+	entry, err := zipWriter.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to write ZIP entry %s: %w", name, err)
+	}
+	_, err = io.WriteString(entry, statement)
+	return err
+}
+
+// createTableStatement builds a CREATE TABLE statement in the EmitDDL dialect
+func (ec *ExcelConverter) createTableStatement(tableName string, columns []ColumnSchema) (string, error) {
+	dialect, ok := ddlDialects[ec.EmitDDL]
+	if !ok {
+		return "", checkDDLDialect(ec.EmitDDL)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "CREATE TABLE %s (\n", dialect.quote(sqlIdentifier(tableName, "data")))
+
+	seen := make(map[string]bool, len(columns))
+	for i, column := range columns {
+		name := sqlIdentifier(column.Name, fmt.Sprintf("column_%d", i+1))
+		base := name
+		for n := 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		seen[name] = true
+
+		fmt.Fprintf(&sb, "    %s %s", dialect.quote(name), dialect.types[column.Type])
+		if !column.Nullable {
+			sb.WriteString(" NOT NULL")
+		}
+		if i < len(columns)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(");\n")
+
+	return sb.String(), nil
+}
+
+// sqlIdentifier turns a header into a lowercase identifier of letters, digits and
+// underscores that doesn't start with a digit, or fallback if nothing is left
+func sqlIdentifier(name, fallback string) string {
+	var sb strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			underscore = false
+		} else if !underscore && sb.Len() > 0 {
+			sb.WriteByte('_')
+			underscore = true
+		}
+	}
+
+	identifier := strings.TrimSuffix(sb.String(), "_")
+	if identifier == "" {
+		return fallback
+	}
+	if identifier[0] >= '0' && identifier[0] <= '9' {
+		identifier = "_" + identifier
+	}
+	return identifier
+}
@@ -0,0 +1,108 @@
+package excel2csv
+
+import "testing"
+
+func TestSQLIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Name", "name"},
+		{"  Unit Price (EUR)  ", "unit_price_eur"},
+		{"a -- b", "a_b"},
+		{"2024 Total", "_2024_total"},
+		{"42", "_42"},
+		{"--Qty--", "qty"},
+		{`x"); DROP TABLE t; --`, "x_drop_table_t"},
+		{"col`name", "col_name"},
+		{"Übersicht", "bersicht"},
+		{"", "column_3"},
+		{"  ", "column_3"},
+		{"€ / %", "column_3"},
+	}
+
+	for _, tt := range tests {
+		if got := sqlIdentifier(tt.name, "column_3"); got != tt.want {
+			t.Errorf("sqlIdentifier(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateTableStatement(t *testing.T) {
+	columns := []ColumnSchema{
+		{Name: "ID", Type: ColumnInt},
+		{Name: "id", Type: ColumnFloat, Nullable: true},
+		{Name: "Id!", Type: ColumnDate},
+		{Name: "", Type: ColumnBool, Nullable: true},
+		{Name: `Note "quoted"`, Type: ColumnString, Nullable: true},
+	}
+
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{"postgres", "CREATE TABLE \"q1_sales\" (\n" +
+			"    \"id\" BIGINT NOT NULL,\n" +
+			"    \"id_2\" DOUBLE PRECISION,\n" +
+			"    \"id_3\" DATE NOT NULL,\n" +
+			"    \"column_4\" BOOLEAN,\n" +
+			"    \"note_quoted\" TEXT\n" +
+			");\n"},
+		{"mysql", "CREATE TABLE `q1_sales` (\n" +
+			"    `id` BIGINT NOT NULL,\n" +
+			"    `id_2` DOUBLE,\n" +
+			"    `id_3` DATE NOT NULL,\n" +
+			"    `column_4` BOOLEAN,\n" +
+			"    `note_quoted` TEXT\n" +
+			");\n"},
+		{"sqlite", "CREATE TABLE \"q1_sales\" (\n" +
+			"    \"id\" INTEGER NOT NULL,\n" +
+			"    \"id_2\" REAL,\n" +
+			"    \"id_3\" TEXT NOT NULL,\n" +
+			"    \"column_4\" INTEGER,\n" +
+			"    \"note_quoted\" TEXT\n" +
+			");\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			converter := NewExcelConverter()
+			converter.EmitDDL = tt.dialect
+
+			got, err := converter.createTableStatement("Q1 Sales", columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("createTableStatement() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateTableStatementNames(t *testing.T) {
+	converter := NewExcelConverter()
+	converter.EmitDDL = "postgres"
+
+	// A table name with nothing left falls back to data, a generated name that
+	// clashes with a header is numbered like any other repeat
+	got, err := converter.createTableStatement("???", []ColumnSchema{
+		{Name: "column_2", Type: ColumnString},
+		{Name: "", Type: ColumnString},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CREATE TABLE \"data\" (\n" +
+		"    \"column_2\" TEXT NOT NULL,\n" +
+		"    \"column_2_2\" TEXT NOT NULL\n" +
+		");\n"
+	if got != want {
+		t.Errorf("createTableStatement() =\n%s\nwant\n%s", got, want)
+	}
+
+	converter.EmitDDL = "oracle"
+	if _, err := converter.createTableStatement("data", nil); err == nil {
+		t.Error("createTableStatement() with an unknown dialect succeeded")
+	}
+}
@@ -0,0 +1,148 @@
+package excel2csv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DetectionDiagnostics explains how the table of a sheet was picked, written to
+// DiagnosticsPath. Row numbers are 1-based, as in the spreadsheet.
+type DetectionDiagnostics struct {
+	Sheet           string           `json:"sheet"`
+	Strategy        string           `json:"strategy"`
+	HeaderRow       int              `json:"header_row"` // 0 for an empty sheet
+	EndRow          int              `json:"end_row"`
+	HeaderReason    string           `json:"header_reason"`
+	ExpectedColumns int              `json:"expected_columns"` // non-empty cells of the header row
+	Rows            []RowDiagnostics `json:"rows"`
+}
+
+// RowDiagnostics is the classification of one exported row
+type RowDiagnostics struct {
+	Row      int    `json:"row"`
+	NonEmpty int    `json:"non_empty"`
+	Numeric  int    `json:"numeric"`
+	Included bool   `json:"included"`
+	Reason   string `json:"reason"`
+}
+
+// strategyNames name the detection strategies in diagnostics
+var strategyNames = map[DetectionStrategy]string{
+	StrategyImproved:   "improved",
+	StrategyStructural: "structural",
+	StrategyNone:       "none",
+}
+
+// writeDiagnostics writes the diagnostics of the table detected in records, rows
+// start to end, to DiagnosticsPath if set
+func (ec *ExcelConverter) writeDiagnostics(records [][]string, start, end int) error {
+	if ec.DiagnosticsPath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(ec.detectionDiagnostics(records, start, end), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(ec.DiagnosticsPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+	return nil
+}
+
+// detectionDiagnostics classifies every row of a sheet against the detected table
+func (ec *ExcelConverter) detectionDiagnostics(records [][]string, start, end int) DetectionDiagnostics {
+	diagnostics := DetectionDiagnostics{
+		Sheet:    ec.convertedSheetName(),
+		Strategy: strategyNames[ec.DetectionStrategy],
+		Rows:     make([]RowDiagnostics, len(records)),
+	}
+	if len(records) == 0 {
+		return diagnostics
+	}
+
+	diagnostics.HeaderRow = start + 1
+	diagnostics.EndRow = end + 1
+	diagnostics.HeaderReason = ec.headerReason(records, start)
+	diagnostics.ExpectedColumns = ec.countNonEmptyCells(records[start])
+
+	for i, record := range records {
+		row := RowDiagnostics{
+			Row:      i + 1,
+			NonEmpty: ec.countNonEmptyCells(record),
+			Numeric:  ec.countNumericCells(record),
+			Included: i >= start && i <= end,
+		}
+
+		switch {
+		case i < start:
+			row.Reason = "above the table"
+		case i == start:
+			row.Reason = "header row"
+		case i <= end:
+			row.Reason = "table row"
+		case i == end+1:
+			row.Reason = ec.stopReason(row.NonEmpty, diagnostics.ExpectedColumns)
+		default:
+			row.Reason = "below the table"
+		}
+		diagnostics.Rows[i] = row
+	}
+
+	return diagnostics
+}
+
+// headerReason explains why the row at start begins the table
+func (ec *ExcelConverter) headerReason(records [][]string, start int) string {
+	header := records[start]
+	switch {
+	case ec.CellRange != "":
+		return "first row of cell range " + ec.CellRange
+	case ec.ForceDataStartRow != nil && *ec.ForceDataStartRow == start:
+		return "forced start row"
+	case ec.DetectionStrategy == StrategyNone:
+		return "first row, detection is off"
+	case ec.DetectionStrategy == StrategyStructural:
+		if start+1 < len(records) && ec.looksLikeHeaderRow(header, records[start+1]) {
+			return "text row above the first consistently structured data rows"
+		}
+		return "first of the consistently structured data rows"
+	}
+
+	if matches := ec.countExpectedHeaders(header); matches > 0 {
+		return fmt.Sprintf("matches %d of %d expected headers", matches, len(ec.ExpectedHeaders))
+	}
+	if ec.countNonEmptyCells(header) >= 5 && ec.countNumericCells(header) <= 1 {
+		return "widest row with at most one number"
+	}
+	return "first row with data, no header row found"
+}
+
+// stopReason explains why the row after the table isn't part of it
+func (ec *ExcelConverter) stopReason(nonEmpty, expectedColumns int) string {
+	switch {
+	case ec.CellRange != "":
+		return "below cell range " + ec.CellRange
+	case ec.ForceDataEndRow != nil:
+		return "after the forced end row"
+	case nonEmpty == 0:
+		return "empty row ends the table"
+	case ec.DetectionStrategy == StrategyImproved && nonEmpty < expectedColumns/3:
+		return fmt.Sprintf("footer, %d cells against %d in the header", nonEmpty, expectedColumns)
+	default:
+		return fmt.Sprintf("structure differs from the table, %d cells against %d in the header", nonEmpty, expectedColumns)
+	}
+}
+
+// sheetDiagnosticsPath returns the DiagnosticsPath of a sheet in all sheets mode,
+// numbered like the output files: report.json becomes report_sheet_2.json
+func (ec *ExcelConverter) sheetDiagnosticsPath(sheet int) string {
+	if ec.DiagnosticsPath == "" {
+		return ""
+	}
+	ext := filepath.Ext(ec.DiagnosticsPath)
+	return fmt.Sprintf("%s_sheet_%d%s", strings.TrimSuffix(ec.DiagnosticsPath, ext), sheet+1, ext)
+}
@@ -0,0 +1,190 @@
+package excel2csv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// manifestName is the file in the output directory of ConvertDirectory that records
+// the converted files
+const manifestName = "manifest.json"
+
+// directoryManifest records the files ConvertDirectory converted, by file name
+type directoryManifest struct {
+	Files map[string]manifestEntry `json:"files"`
+}
+
+// manifestEntry identifies the converted version of a file, a changed file is converted again
+type manifestEntry struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	Outputs     []string  `json:"outputs"` // names of the written files in the output directory
+	ConvertedAt time.Time `json:"converted_at"`
+}
+
+// ConvertDirectory converts every spreadsheet, CSV and TSV file in dir, not in its
+// subdirectories, to outDir like ConvertArchive and returns the stats of each in name order.
+// Converted files are recorded in manifest.json in outDir, which is updated after
+// every file, so running it again after an interruption skips the files that are done,
+// unless they changed since. Failed files aren't recorded and are retried on the next run.
+func (ec *ExcelConverter) ConvertDirectory(dir, outDir string) ([]ConvertStats, error) {
+	return ec.ConvertDirectoryContext(context.Background(), dir, outDir)
+}
+
+// ConvertDirectoryContext is like ConvertDirectory, but stops converting and kills
+// LibreOffice when ctx is done. The files converted until then stay in the manifest.
+func (ec *ExcelConverter) ConvertDirectoryContext(ctx context.Context, dir, outDir string) ([]ConvertStats, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	manifestPath := filepath.Join(outDir, manifestName)
+	manifest, err := readManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files are read by their own extension
+	converter := *ec
+	converter.ForceFormat = ""
+
+	// Outputs written into dir must not be converted as CSV inputs on the next run
+	outputs := make(map[string]bool)
+	for _, done := range manifest.Files {
+		for _, output := range done.Outputs {
+			outputs[absPath(filepath.Join(outDir, output))] = true
+		}
+	}
+
+	var results []ConvertStats
+	var errs []error
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		name := entry.Name()
+		inputPath := filepath.Join(dir, name)
+		if !entry.Type().IsRegular() || !isSpreadsheetFile(name) || outputs[absPath(inputPath)] || converter.checkInputFormat(name) != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if done, ok := manifest.Files[name]; ok && done.Size == info.Size() && done.ModTime.Equal(info.ModTime()) {
+			fmt.Printf("Skipping %s, already converted\n", name)
+			continue
+		}
+
+		ext := filepath.Ext(name)
+		outputPath := filepath.Join(outDir, strings.TrimSuffix(name, ext)+converter.archiveOutputExt(ext))
+		// Don't overwrite a CSV input when converting in place
+		if absPath(outputPath) == absPath(inputPath) {
+			outputPath = filepath.Join(outDir, strings.TrimSuffix(name, ext)+"_clean"+ext)
+		}
+
+		fmt.Printf("Converting %s to %s\n", name, outputPath)
+
+		stats, err := converter.ConvertFileStatsContext(ctx, inputPath, outputPath)
+		stats.Source = name
+		if err != nil {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			fmt.Printf("Warning: failed to convert %s: %v\n", name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			results = append(results, stats)
+			continue
+		}
+		results = append(results, stats)
+
+		// Split and all sheets output list their files, a single output path is the file otherwise
+		written := stats.OutputFiles
+		if len(written) == 0 && !converter.AllSheetsMode && converter.SheetPattern == "" {
+			written = []string{outputPath}
+		}
+		entry := manifestEntry{Size: info.Size(), ModTime: info.ModTime(), ConvertedAt: time.Now().UTC()}
+		for _, path := range written {
+			entry.Outputs = append(entry.Outputs, filepath.Base(path))
+			outputs[absPath(path)] = true
+		}
+		manifest.Files[name] = entry
+		if err := writeManifest(manifestPath, manifest); err != nil {
+			return results, err
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// isSpreadsheetFile skips hidden files, macOS ._ files among them, and Excel ~$ lock files
+func isSpreadsheetFile(name string) bool {
+	return !strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "~$")
+}
+
+// absPath returns the absolute path of path, or path if it can't be determined
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// readManifest reads the manifest at path, a missing manifest is empty
+func readManifest(path string) (*directoryManifest, error) {
+	manifest := &directoryManifest{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+		}
+	}
+
+	if manifest.Files == nil {
+		manifest.Files = map[string]manifestEntry{}
+	}
+	return manifest, nil
+}
+
+// writeManifest replaces the manifest at path atomically, so an interruption
+// leaves either the old or the new manifest
+func writeManifest(path string, manifest *directoryManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".manifest-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
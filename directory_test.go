@@ -0,0 +1,117 @@
+package excel2csv
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// convertedSources returns the Source of each stats
+func convertedSources(results []ConvertStats) []string {
+	sources := []string{}
+	for _, stats := range results {
+		sources = append(sources, stats.Source)
+	}
+	return sources
+}
+
+func TestConvertDirectoryResume(t *testing.T) {
+	dir, outDir := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.csv", "b.csv", "c.tsv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("Name,Qty\na,1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{".hidden.csv", "~$b.csv", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Interrupt the run once the first file is written
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	converter := NewExcelConverter()
+	converter.DetectionStrategy = StrategyNone
+	converter.OnProgress = func(done, total int, stage string) { cancel() }
+	results, err := converter.ConvertDirectoryContext(ctx, dir, outDir)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ConvertDirectoryContext() error = %v, want context.Canceled", err)
+	}
+	if got := convertedSources(results); !reflect.DeepEqual(got, []string{"a.csv"}) {
+		t.Fatalf("interrupted run converted %q, want a.csv", got)
+	}
+
+	// The next run picks up after the finished file
+	converter.OnProgress = nil
+	results, err = converter.ConvertDirectory(dir, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := convertedSources(results); !reflect.DeepEqual(got, []string{"b.csv", "c.tsv"}) {
+		t.Errorf("resumed run converted %q, want b.csv and c.tsv", got)
+	}
+	for _, name := range []string{"a.csv", "b.csv", "c.csv", manifestName} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Errorf("output %s: %v", name, err)
+		}
+	}
+
+	// A changed file is converted again, the others are skipped
+	changed := filepath.Join(dir, "b.csv")
+	if err := os.WriteFile(changed, []byte("Name,Qty\nb,2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(changed, later, later); err != nil {
+		t.Fatal(err)
+	}
+	results, err = converter.ConvertDirectory(dir, outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := convertedSources(results); !reflect.DeepEqual(got, []string{"b.csv"}) {
+		t.Errorf("run after a change converted %q, want b.csv", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outDir, "b.csv")); string(data) != "Name,Qty\nb,2\n" {
+		t.Errorf("b.csv = %q, want the changed table", data)
+	}
+}
+
+func TestConvertDirectoryInPlace(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.csv", "b.tsv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("Name\tQty\na\t1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	converter := NewExcelConverter()
+	converter.DetectionStrategy = StrategyNone
+	results, err := converter.ConvertDirectory(dir, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := convertedSources(results); !reflect.DeepEqual(got, []string{"a.csv", "b.tsv"}) {
+		t.Fatalf("first run converted %q, want a.csv and b.tsv", got)
+	}
+	// A CSV input isn't overwritten by its output
+	for _, name := range []string{"a_clean.csv", "b.csv"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("output %s: %v", name, err)
+		}
+	}
+
+	// Outputs written into dir aren't inputs of the next run
+	results, err = converter.ConvertDirectory(dir, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := convertedSources(results); len(got) != 0 {
+		t.Errorf("second run converted %q, want nothing", got)
+	}
+}
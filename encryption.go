@@ -0,0 +1,179 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oxyii/excel2csv/internal/cfb"
+	"github.com/oxyii/excel2csv/internal/officecrypto"
+)
+
+// ErrPasswordRequired is returned for password-protected workbooks when no
+// Password is set, or when the format can't be decrypted
+var ErrPasswordRequired = errors.New("workbook is password-protected")
+
+// ErrWrongPassword is returned when Password doesn't open an encrypted workbook
+var ErrWrongPassword = officecrypto.ErrWrongPassword
+
+// xlsDefaultPassword is the password Excel encrypts write-protected xls files with,
+// LibreOffice opens them like unencrypted ones
+const xlsDefaultPassword = "VelvetSweatshop"
+
+// isPasswordProtected checks whether an xlsx, xlsm, xlsb, xls or ods file, going by ext, is encrypted
+func isPasswordProtected(inputPath, ext string) (bool, error) {
+	switch ext {
+	case ".xlsx", ".xlsm", ".xlsb":
+		return isEncryptedOOXML(inputPath)
+	case ".xls":
+		return isEncryptedXLS(inputPath)
+	case ".ods":
+		return isEncryptedODS(inputPath)
+	default:
+		return false, nil
+	}
+}
+
+// checkEncryption reports whether a workbook of format ext is encrypted, with an
+// error when it can't be decrypted: Password is empty or the format isn't supported.
+// Only xlsx, xlsm and xlsb files are decrypted, LibreOffice can't be given a password
+// on its command line.
+func (ec *ExcelConverter) checkEncryption(inputPath, ext string) (bool, error) {
+	protected, err := isPasswordProtected(inputPath, ext)
+	if err != nil {
+		return false, fmt.Errorf("input file not accessible: %w", err)
+	}
+	if !protected {
+		return false, nil
+	}
+
+	name := filepath.Base(inputPath)
+	if ec.Password == "" {
+		return true, fmt.Errorf("%s: %w", name, ErrPasswordRequired)
+	}
+	if ext != ".xlsx" && ext != ".xlsm" && ext != ".xlsb" {
+		return true, fmt.Errorf("%s: decrypting %s files is not supported, save the workbook as xlsx: %w", name, ext, ErrPasswordRequired)
+	}
+	return true, nil
+}
+
+// decryptInput returns the file LibreOffice should open: inputPath itself, or for
+// encrypted workbooks a copy in tempDir decrypted with Password
+func (ec *ExcelConverter) decryptInput(inputPath, tempDir string) (string, error) {
+	protected, err := ec.checkEncryption(inputPath, ec.inputExt(inputPath))
+	if err != nil || !protected {
+		return inputPath, err
+	}
+
+	name := filepath.Base(inputPath)
+	decryptedDir := filepath.Join(tempDir, "decrypted")
+	if err := os.MkdirAll(decryptedDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	decryptedPath := filepath.Join(decryptedDir, name)
+	if err := officecrypto.Decrypt(inputPath, decryptedPath, ec.Password); err != nil {
+		return "", fmt.Errorf("%s: failed to decrypt: %w", name, err)
+	}
+
+	// The encrypted container hides the package format, name the copy after its content
+	ext := filepath.Ext(decryptedPath)
+	if format, err := sniffZipFormat(decryptedPath); err == nil && format != "" && "."+format != strings.ToLower(ext) {
+		renamed := strings.TrimSuffix(decryptedPath, ext) + "." + format
+		if err := os.Rename(decryptedPath, renamed); err == nil {
+			decryptedPath = renamed
+		}
+	}
+	return decryptedPath, nil
+}
+
+// isEncryptedOOXML checks for the encryption streams of a compound file.
+// Plain xlsx files are ZIP archives and are never encrypted.
+func isEncryptedOOXML(inputPath string) (bool, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = file.Close() }()
+
+	cf, err := cfb.Open(file)
+	if errors.Is(err, cfb.ErrCorrupt) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return cf.HasStream("EncryptionInfo") && cf.HasStream("EncryptedPackage"), nil
+}
+
+// isEncryptedXLS looks for a FilePass record in the workbook globals of an xls file.
+// Files encrypted with the default password of write-protected workbooks don't count.
+func isEncryptedXLS(inputPath string) (bool, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = file.Close() }()
+
+	cf, err := cfb.Open(file)
+	if errors.Is(err, cfb.ErrCorrupt) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	name := "Workbook"
+	if !cf.HasStream(name) {
+		name = "Book" // BIFF5
+	}
+	stream, err := cf.OpenStream(name)
+	if err != nil {
+		// Not a workbook, maybe an encrypted xlsx named .xls: let LibreOffice report it
+		return false, nil
+	}
+
+	// Damaged globals are left to LibreOffice
+	encrypted := false
+	_ = walkWorkbookGlobals(stream, func(recordType uint16, data []byte) (bool, error) {
+		if recordType == biffFilePass {
+			encrypted = !officecrypto.XLSPasswordMatches(data, xlsDefaultPassword)
+			return false, nil
+		}
+		return true, nil
+	})
+	return encrypted, nil
+}
+
+// isEncryptedODS checks the ODF manifest for encryption data
+func isEncryptedODS(inputPath string) (bool, error) {
+	reader, err := zip.OpenReader(inputPath)
+	if err != nil {
+		// Not a valid ODS archive, let LibreOffice report the problem
+		return false, nil
+	}
+	defer func() { _ = reader.Close() }()
+
+	for _, file := range reader.File {
+		if file.Name != "META-INF/manifest.xml" {
+			continue
+		}
+
+		manifest, err := file.Open()
+		if err != nil {
+			return false, err
+		}
+		defer func() { _ = manifest.Close() }()
+
+		data, err := io.ReadAll(manifest)
+		if err != nil {
+			return false, err
+		}
+		return bytes.Contains(data, []byte("encryption-data")), nil
+	}
+
+	return false, nil
+}
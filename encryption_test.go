@@ -0,0 +1,99 @@
+package excel2csv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oxyii/excel2csv/internal/cfb/cfbtest"
+	"github.com/oxyii/excel2csv/internal/officecrypto/officecryptotest"
+)
+
+func TestDecryptInput(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "book.xlsx")
+	if err := os.WriteFile(inputPath, officecryptotest.EncryptAgile(t, []byte("not a zip"), "secret", 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	converter := NewExcelConverter()
+	if _, err := converter.decryptInput(inputPath, dir); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("decryptInput() without password error = %v, want ErrPasswordRequired", err)
+	}
+
+	converter.Password = "wrong"
+	if _, err := converter.decryptInput(inputPath, dir); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("decryptInput() with wrong password error = %v, want ErrWrongPassword", err)
+	}
+
+	converter.Password = "secret"
+	decryptedPath, err := converter.decryptInput(inputPath, dir)
+	if err != nil {
+		t.Fatalf("decryptInput() error = %v", err)
+	}
+	if data, _ := os.ReadFile(decryptedPath); string(data) != "not a zip" {
+		t.Errorf("decryptInput() wrote %q", data)
+	}
+
+	plainPath := filepath.Join(dir, "plain.csv")
+	if got, err := converter.decryptInput(plainPath, dir); err != nil || got != plainPath {
+		t.Errorf("decryptInput() of an unencrypted file = %q, %v", got, err)
+	}
+}
+
+// xlsWithFilePass builds an xls file whose globals start with a FilePass record
+func xlsWithFilePass(t *testing.T, filePass []byte) []byte {
+	t.Helper()
+	record := func(recordType uint16, data []byte) []byte {
+		header := binary.LittleEndian.AppendUint16(nil, recordType)
+		header = binary.LittleEndian.AppendUint16(header, uint16(len(data)))
+		return append(header, data...)
+	}
+
+	workbook := record(0x0809, make([]byte, 16)) // BOF
+	if filePass != nil {
+		workbook = append(workbook, record(0x002F, filePass)...)
+	}
+	workbook = append(workbook, record(0x0042, []byte{0xE4, 0x04})...) // CodePage
+	workbook = append(workbook, record(0x000A, nil)...)                // EOF
+	workbook = append(workbook, bytes.Repeat([]byte{0}, 5000)...)      // large enough to leave the mini stream
+
+	return cfbtest.Build(t, []string{"Workbook"}, map[string][]byte{"Workbook": workbook})
+}
+
+func TestIsEncryptedXLS(t *testing.T) {
+	tests := []struct {
+		name     string
+		filePass []byte
+		want     bool
+	}{
+		{"not encrypted", nil, false},
+		{"xor", officecryptotest.XORFilePass("secret"), true},
+		{"xor default password", officecryptotest.XORFilePass(xlsDefaultPassword), false},
+		{"rc4", officecryptotest.RC4FilePass(t, "secret"), true},
+		{"rc4 default password", officecryptotest.RC4FilePass(t, xlsDefaultPassword), false},
+		{"cryptoapi", officecryptotest.CryptoAPIFilePass(t, "secret"), true},
+		{"cryptoapi default password", officecryptotest.CryptoAPIFilePass(t, xlsDefaultPassword), false},
+		{"unknown encryption", []byte{1, 0, 9, 0, 9, 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := filepath.Join(t.TempDir(), "book.xls")
+			if err := os.WriteFile(inputPath, xlsWithFilePass(t, tt.filePass), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := isPasswordProtected(inputPath, ".xls")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("isPasswordProtected() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
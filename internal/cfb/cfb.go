@@ -0,0 +1,321 @@
+// Package cfb reads the streams of OLE2 compound files, the container of xls files
+// and of encrypted xlsx, xlsm and xlsb files. It reads only what detecting and
+// decrypting workbooks needs: the root storage's streams, by name.
+package cfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrCorrupt is returned for files that aren't readable compound files
+var ErrCorrupt = errors.New("not a readable compound file")
+
+// Signature starts every compound file
+var Signature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Special sector numbers of the compound file allocation table
+const (
+	MaxSector  = 0xFFFFFFFA
+	EndOfChain = 0xFFFFFFFE
+)
+
+// miniSectorSize is the sector size of the mini stream holding small streams
+const miniSectorSize = 64
+
+// File reads the streams of an OLE2 compound file
+type File struct {
+	r          io.ReaderAt
+	sectorSize int64
+	fat        []uint32
+	miniFAT    []uint32
+	miniCutoff int64
+	entries    []entry
+}
+
+// entry is a directory entry of a compound file
+type entry struct {
+	name     string
+	isStream bool
+	start    uint32
+	size     int64
+}
+
+// Open reads the header, allocation tables and directory of a compound file.
+// It returns ErrCorrupt for content that isn't a compound file.
+func Open(r io.ReaderAt) (*File, error) {
+	header := make([]byte, 512)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, ReadError(err)
+	}
+	if !bytes.Equal(header[:len(Signature)], Signature) {
+		return nil, ErrCorrupt
+	}
+
+	// Sector size as a power of two at 0x1E, 512 in version 3 and 4096 in version 4
+	shift := binary.LittleEndian.Uint16(header[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, ErrCorrupt
+	}
+	cf := &File{
+		r:          r,
+		sectorSize: int64(1) << shift,
+		miniCutoff: int64(binary.LittleEndian.Uint32(header[0x38:])),
+	}
+
+	if err := cf.readFAT(header); err != nil {
+		return nil, err
+	}
+
+	directory, err := cf.readChain(binary.LittleEndian.Uint32(header[0x30:]), -1, false)
+	if err != nil {
+		return nil, err
+	}
+	for offset := 0; offset+128 <= len(directory); offset += 128 {
+		cf.entries = append(cf.entries, parseEntry(directory[offset:offset+128], shift == 9))
+	}
+	if len(cf.entries) == 0 {
+		return nil, ErrCorrupt
+	}
+
+	if miniFATSectors := binary.LittleEndian.Uint32(header[0x40:]); miniFATSectors > 0 {
+		miniFAT, err := cf.readChain(binary.LittleEndian.Uint32(header[0x3C:]), -1, false)
+		if err != nil {
+			return nil, err
+		}
+		cf.miniFAT = sectorNumbers(miniFAT)
+	}
+
+	return cf, nil
+}
+
+// readFAT collects the allocation table from the sectors listed in the header
+// and in the DIFAT sector chain
+func (cf *File) readFAT(header []byte) error {
+	fatSectors := int(binary.LittleEndian.Uint32(header[0x2C:]))
+	perSector := int(cf.sectorSize / 4)
+
+	var locations []uint32
+	for i := 0; i < 109 && len(locations) < fatSectors; i++ {
+		locations = append(locations, binary.LittleEndian.Uint32(header[0x4C+i*4:]))
+	}
+
+	// A sector listed twice would be read twice, so a damaged header could
+	// make a small file claim a huge table
+	seen := make(map[uint32]bool)
+	next := binary.LittleEndian.Uint32(header[0x44:])
+	sector := make([]byte, cf.sectorSize)
+	for len(locations) < fatSectors && next <= MaxSector {
+		if seen[next] {
+			return ErrCorrupt
+		}
+		seen[next] = true
+		if _, err := cf.r.ReadAt(sector, cf.sectorOffset(next)); err != nil {
+			return ReadError(err)
+		}
+		entries := sectorNumbers(sector)
+		for _, location := range entries[:perSector-1] {
+			if len(locations) < fatSectors {
+				locations = append(locations, location)
+			}
+		}
+		next = entries[perSector-1]
+	}
+	if len(locations) < fatSectors {
+		return ErrCorrupt
+	}
+
+	for _, location := range locations {
+		if location > MaxSector || seen[location] {
+			return ErrCorrupt
+		}
+		seen[location] = true
+		if _, err := cf.r.ReadAt(sector, cf.sectorOffset(location)); err != nil {
+			return ReadError(err)
+		}
+		cf.fat = append(cf.fat, sectorNumbers(sector)...)
+	}
+	return nil
+}
+
+// sectorOffset returns the file offset of a sector, the header takes the first sector
+func (cf *File) sectorOffset(sector uint32) int64 {
+	return (int64(sector) + 1) * cf.sectorSize
+}
+
+// chain follows an allocation table from start and returns the sectors in order.
+// A chain that loops back on itself is corrupt.
+func chain(table []uint32, start uint32) ([]uint32, error) {
+	var sectors []uint32
+	visited := make([]bool, len(table))
+	for sector := start; sector != EndOfChain; sector = table[sector] {
+		if int(sector) >= len(table) || visited[sector] {
+			return nil, ErrCorrupt
+		}
+		visited[sector] = true
+		sectors = append(sectors, sector)
+	}
+	return sectors, nil
+}
+
+// readChain reads a sector chain into memory, limited to size bytes unless size is negative
+func (cf *File) readChain(start uint32, size int64, mini bool) ([]byte, error) {
+	reader, err := cf.chainReader(start, size, mini)
+	if err != nil {
+		return nil, err
+	}
+	// Read as far as the file goes rather than trusting the chain's length
+	data, err := io.ReadAll(io.NewSectionReader(reader, 0, reader.size))
+	if err != nil {
+		return nil, ReadError(err)
+	}
+	return data, nil
+}
+
+// chainReader returns a reader of the sector chain starting at start, in the mini
+// stream for mini. A negative size reads whole sectors.
+func (cf *File) chainReader(start uint32, size int64, mini bool) (*sectorChainReader, error) {
+	table, sectorSize, base, src := cf.fat, cf.sectorSize, int64(1), cf.r
+	if mini {
+		miniStream, err := cf.chainReader(cf.entries[0].start, cf.entries[0].size, false)
+		if err != nil {
+			return nil, err
+		}
+		table, sectorSize, base, src = cf.miniFAT, miniSectorSize, 0, miniStream
+	}
+
+	sectors, err := chain(table, start)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		size = int64(len(sectors)) * sectorSize
+	}
+	if int64(len(sectors))*sectorSize < size {
+		return nil, ErrCorrupt
+	}
+
+	return &sectorChainReader{src: src, sectorSize: sectorSize, base: base, sectors: sectors, size: size}, nil
+}
+
+// stream returns the stream named name, compared case-insensitively like compound
+// files do, or nil if there is none
+func (cf *File) stream(name string) *entry {
+	for i := range cf.entries {
+		if cf.entries[i].isStream && strings.EqualFold(cf.entries[i].name, name) {
+			return &cf.entries[i]
+		}
+	}
+	return nil
+}
+
+// HasStream reports whether the root storage holds a stream named name
+func (cf *File) HasStream(name string) bool {
+	return cf.stream(name) != nil
+}
+
+// OpenStream returns a reader of the stream named name and its size
+func (cf *File) OpenStream(name string) (*io.SectionReader, error) {
+	stream := cf.stream(name)
+	if stream == nil {
+		return nil, fmt.Errorf("%w: no %s stream", ErrCorrupt, name)
+	}
+
+	reader, err := cf.chainReader(stream.start, stream.size, stream.size < cf.miniCutoff)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(reader, 0, stream.size), nil
+}
+
+// ReadStream reads the stream named name into memory, for small streams
+func (cf *File) ReadStream(name string) ([]byte, error) {
+	stream, err := cf.OpenStream(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, ReadError(err)
+	}
+	if int64(len(data)) < stream.Size() {
+		return nil, ErrCorrupt
+	}
+	return data, nil
+}
+
+// sectorChainReader reads the bytes of a sector chain as one contiguous stream
+type sectorChainReader struct {
+	src        io.ReaderAt
+	sectorSize int64
+	base       int64 // sectors before sector 0, the header of regular sectors
+	sectors    []uint32
+	size       int64
+}
+
+func (r *sectorChainReader) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		within := off % r.sectorSize
+		chunk := min(int64(len(p)-n), r.sectorSize-within, r.size-off)
+		position := (int64(r.sectors[off/r.sectorSize])+r.base)*r.sectorSize + within
+		if _, err := r.src.ReadAt(p[n:n+int(chunk)], position); err != nil {
+			return n, ReadError(err)
+		}
+		n += int(chunk)
+		off += chunk
+	}
+	return n, nil
+}
+
+// parseEntry decodes a 128 byte directory entry. Version 3 files only use
+// the low 32 bits of the stream size.
+func parseEntry(data []byte, version3 bool) entry {
+	nameLength := int(binary.LittleEndian.Uint16(data[0x40:]))
+	if nameLength > 64 {
+		nameLength = 64
+	}
+	name := make([]uint16, 0, nameLength/2)
+	for i := 0; i+1 < nameLength; i += 2 {
+		if c := binary.LittleEndian.Uint16(data[i:]); c != 0 {
+			name = append(name, c)
+		}
+	}
+
+	size := binary.LittleEndian.Uint64(data[0x78:])
+	if version3 {
+		size &= 0xFFFFFFFF
+	}
+
+	return entry{
+		name:     string(utf16.Decode(name)),
+		isStream: data[0x42] == 2,
+		start:    binary.LittleEndian.Uint32(data[0x74:]),
+		size:     int64(min(size, 1<<62)),
+	}
+}
+
+// sectorNumbers decodes a sector of little-endian sector numbers
+func sectorNumbers(data []byte) []uint32 {
+	numbers := make([]uint32, len(data)/4)
+	for i := range numbers {
+		numbers[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return numbers
+}
+
+// ReadError reports reads past the end of a file or stream as a corrupt compound file
+func ReadError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrCorrupt
+	}
+	return err
+}
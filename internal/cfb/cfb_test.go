@@ -0,0 +1,87 @@
+package cfb_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/oxyii/excel2csv/internal/cfb"
+	"github.com/oxyii/excel2csv/internal/cfb/cfbtest"
+)
+
+func TestOpenStream(t *testing.T) {
+	streams := map[string][]byte{
+		"Workbook":       bytes.Repeat([]byte("large stream "), 1000), // regular sectors
+		"EncryptionInfo": []byte("small stream in the mini stream"),
+		"Empty":          nil,
+	}
+	file := cfbtest.Build(t, []string{"Workbook", "EncryptionInfo", "Empty"}, streams)
+
+	cf, err := cfb.Open(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range streams {
+		got, err := cf.ReadStream(name)
+		if err != nil {
+			t.Fatalf("ReadStream(%q) error = %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ReadStream(%q) = %d bytes, want %d", name, len(got), len(want))
+		}
+	}
+
+	// Names compare case-insensitively
+	if !cf.HasStream("WORKBOOK") || cf.HasStream("Book") {
+		t.Errorf("HasStream() doesn't find the streams by name")
+	}
+	if _, err := cf.OpenStream("Book"); !errors.Is(err, cfb.ErrCorrupt) {
+		t.Errorf("OpenStream() of a missing stream error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestOpenCorrupt(t *testing.T) {
+	file := cfbtest.Build(t, []string{"Workbook"}, map[string][]byte{"Workbook": bytes.Repeat([]byte{1}, 5000)})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"zip", []byte("PK\x03\x04 not a compound file")},
+		{"header only", file[:512]},
+		{"truncated", file[:len(file)-512]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cf, err := cfb.Open(bytes.NewReader(tt.data))
+			if err == nil {
+				_, err = cf.ReadStream("Workbook")
+			}
+			if !errors.Is(err, cfb.ErrCorrupt) {
+				t.Errorf("reading the file error = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+// FuzzOpen feeds damaged compound files to Open and reads the streams it finds
+func FuzzOpen(f *testing.F) {
+	f.Add(cfbtest.Build(f, []string{"Workbook"}, map[string][]byte{"Workbook": bytes.Repeat([]byte{1}, 5000)}))
+	f.Add(cfbtest.Build(f, []string{"EncryptionInfo", "EncryptedPackage"},
+		map[string][]byte{"EncryptionInfo": []byte("info"), "EncryptedPackage": []byte("package")}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cf, err := cfb.Open(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, name := range []string{"Workbook", "EncryptionInfo", "EncryptedPackage"} {
+			if stream, err := cf.OpenStream(name); err == nil {
+				_, _ = io.Copy(io.Discard, stream)
+			}
+		}
+	})
+}
@@ -0,0 +1,161 @@
+// Package cfbtest writes compound files for tests of the code reading them
+package cfbtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/oxyii/excel2csv/internal/cfb"
+)
+
+// Build builds a version 3 compound file holding streams in the root storage in
+// the order of names, small ones in the mini stream like Office writes them
+func Build(t testing.TB, names []string, streams map[string][]byte) []byte {
+	t.Helper()
+	const sectorSize, cutoff = 512, 4096
+	sectors := func(size int, unit int) int { return (size + unit - 1) / unit }
+
+	var miniStream []byte
+	var miniFAT []uint32
+	miniStart := map[string]int{}
+	for _, name := range names {
+		data := streams[name]
+		if len(data) >= cutoff || len(data) == 0 {
+			continue
+		}
+		start, count := len(miniStream)/64, sectors(len(data), 64)
+		miniStart[name] = start
+		for i := range count {
+			next := uint32(start + i + 1)
+			if i == count-1 {
+				next = cfb.EndOfChain
+			}
+			miniFAT = append(miniFAT, next)
+		}
+		padded := make([]byte, count*64)
+		copy(padded, data)
+		miniStream = append(miniStream, padded...)
+	}
+
+	// Sector layout: FAT, directory, mini FAT, mini stream, then the large streams
+	dirSectors := sectors((len(names)+1)*128, sectorSize)
+	miniFATSectors := sectors(len(miniFAT)*4, sectorSize)
+	miniStreamSectors := sectors(len(miniStream), sectorSize)
+	dataSectors := 0
+	for _, name := range names {
+		if len(streams[name]) >= cutoff {
+			dataSectors += sectors(len(streams[name]), sectorSize)
+		}
+	}
+	fatSectors := 1
+	for fatSectors*128 < fatSectors+dirSectors+miniFATSectors+miniStreamSectors+dataSectors {
+		fatSectors++
+	}
+	if fatSectors > 109 {
+		t.Fatal("test compound file too large")
+	}
+
+	var fat []uint32
+	for range fatSectors {
+		fat = append(fat, 0xFFFFFFFD) // FAT sector
+	}
+	addChain := func(count int) uint32 {
+		if count == 0 {
+			return cfb.EndOfChain
+		}
+		start := len(fat)
+		for i := range count {
+			next := uint32(start + i + 1)
+			if i == count-1 {
+				next = cfb.EndOfChain
+			}
+			fat = append(fat, next)
+		}
+		return uint32(start)
+	}
+	dirStart := addChain(dirSectors)
+	miniFATStart := addChain(miniFATSectors)
+	miniStreamStart := addChain(miniStreamSectors)
+
+	var body bytes.Buffer
+	entry := func(name string, objectType byte, child, right, start uint32, size int) []byte {
+		data := make([]byte, 128)
+		units := utf16.Encode([]rune(name))
+		for i, unit := range units {
+			binary.LittleEndian.PutUint16(data[i*2:], unit)
+		}
+		binary.LittleEndian.PutUint16(data[0x40:], uint16(len(units)*2+2))
+		data[0x42], data[0x43] = objectType, 1
+		binary.LittleEndian.PutUint32(data[0x44:], 0xFFFFFFFF)
+		binary.LittleEndian.PutUint32(data[0x48:], right)
+		binary.LittleEndian.PutUint32(data[0x4C:], child)
+		binary.LittleEndian.PutUint32(data[0x74:], start)
+		binary.LittleEndian.PutUint32(data[0x78:], uint32(size))
+		return data
+	}
+
+	var directory []byte
+	directory = append(directory, entry("Root Entry", 5, 1, 0xFFFFFFFF, miniStreamStart, len(miniStream))...)
+	var large [][]byte
+	for i, name := range names {
+		data := streams[name]
+		right := uint32(i + 2)
+		if i == len(names)-1 {
+			right = 0xFFFFFFFF
+		}
+		start := uint32(cfb.EndOfChain)
+		if len(data) >= cutoff {
+			start = addChain(sectors(len(data), sectorSize))
+			large = append(large, data)
+		} else if len(data) > 0 {
+			start = uint32(miniStart[name])
+		}
+		directory = append(directory, entry(name, 2, 0xFFFFFFFF, right, start, len(data))...)
+	}
+
+	pad := func(data []byte) []byte {
+		padded := make([]byte, sectors(len(data), sectorSize)*sectorSize)
+		copy(padded, data)
+		return padded
+	}
+	for len(fat) < fatSectors*128 {
+		fat = append(fat, 0xFFFFFFFF)
+	}
+	for _, next := range fat {
+		_ = binary.Write(&body, binary.LittleEndian, next)
+	}
+	body.Write(pad(directory))
+	for _, next := range miniFAT {
+		_ = binary.Write(&body, binary.LittleEndian, next)
+	}
+	body.Write(make([]byte, miniFATSectors*sectorSize-len(miniFAT)*4))
+	body.Write(pad(miniStream))
+	for _, data := range large {
+		body.Write(pad(data))
+	}
+
+	header := make([]byte, sectorSize)
+	copy(header, cfb.Signature)
+	binary.LittleEndian.PutUint16(header[0x18:], 0x3E)
+	binary.LittleEndian.PutUint16(header[0x1A:], 3)
+	binary.LittleEndian.PutUint16(header[0x1C:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[0x1E:], 9)
+	binary.LittleEndian.PutUint16(header[0x20:], 6)
+	binary.LittleEndian.PutUint32(header[0x2C:], uint32(fatSectors))
+	binary.LittleEndian.PutUint32(header[0x30:], dirStart)
+	binary.LittleEndian.PutUint32(header[0x38:], cutoff)
+	binary.LittleEndian.PutUint32(header[0x3C:], miniFATStart)
+	binary.LittleEndian.PutUint32(header[0x40:], uint32(miniFATSectors))
+	binary.LittleEndian.PutUint32(header[0x44:], cfb.EndOfChain)
+	for i := range 109 {
+		location := uint32(0xFFFFFFFF)
+		if i < fatSectors {
+			location = uint32(i)
+		}
+		binary.LittleEndian.PutUint32(header[0x4C+i*4:], location)
+	}
+
+	return append(header, body.Bytes()...)
+}
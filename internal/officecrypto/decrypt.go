@@ -0,0 +1,399 @@
+// Package officecrypto checks passwords against and decrypts the encryption of
+// Office workbooks: agile and standard encryption of xlsx, xlsm and xlsb packages,
+// and the password verifiers of encrypted xls files.
+package officecrypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/oxyii/excel2csv/internal/cfb"
+)
+
+// ErrWrongPassword is returned when the password doesn't open an encrypted workbook
+var ErrWrongPassword = errors.New("wrong workbook password")
+
+// maxSpinCount bounds the password hashing rounds a file can ask for, so a crafted
+// file can't keep a server busy. Office writes 100000.
+var maxSpinCount = 10000000
+
+// agileSegmentSize is the size of the independently encrypted segments of an agile package
+const agileSegmentSize = 4096
+
+// Block keys of the agile encryption key derivation
+var (
+	agileVerifierInputBlock = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	agileVerifierHashBlock  = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+	agileKeyValueBlock      = []byte{0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6}
+)
+
+// agileEncryption is the XML descriptor of agile encryption, the default since Office 2010
+type agileEncryption struct {
+	KeyData       agileKeyData `xml:"keyData"`
+	KeyEncryptors []struct {
+		URI          string       `xml:"uri,attr"`
+		EncryptedKey agileKeyData `xml:"encryptedKey"`
+	} `xml:"keyEncryptors>keyEncryptor"`
+}
+
+// agileKeyData holds the parameters of the package key or of a password key encryptor
+type agileKeyData struct {
+	SaltSize                   int    `xml:"saltSize,attr"`
+	BlockSize                  int    `xml:"blockSize,attr"`
+	KeyBits                    int    `xml:"keyBits,attr"`
+	HashSize                   int    `xml:"hashSize,attr"`
+	CipherAlgorithm            string `xml:"cipherAlgorithm,attr"`
+	CipherChaining             string `xml:"cipherChaining,attr"`
+	HashAlgorithm              string `xml:"hashAlgorithm,attr"`
+	SaltValue                  string `xml:"saltValue,attr"`
+	SpinCount                  int    `xml:"spinCount,attr"`
+	EncryptedVerifierHashInput string `xml:"encryptedVerifierHashInput,attr"`
+	EncryptedVerifierHashValue string `xml:"encryptedVerifierHashValue,attr"`
+	EncryptedKeyValue          string `xml:"encryptedKeyValue,attr"`
+}
+
+// packageDecrypter decrypts the size bytes of an encrypted package read from src
+type packageDecrypter func(dst io.Writer, src io.Reader, size int64) error
+
+// Decrypt writes the decrypted package of an encrypted xlsx, xlsm or xlsb file
+// to dstPath. It supports agile encryption and the standard encryption of Office 2007,
+// and returns ErrWrongPassword when password doesn't open the file.
+func Decrypt(inputPath, dstPath, password string) error {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	cf, err := cfb.Open(file)
+	if err != nil {
+		return err
+	}
+	decrypt, err := ooxmlDecrypter(cf, password)
+	if err != nil {
+		return err
+	}
+
+	pkg, err := cf.OpenStream("EncryptedPackage")
+	if err != nil {
+		return err
+	}
+	var sizeField [8]byte
+	if _, err := io.ReadFull(pkg, sizeField[:]); err != nil {
+		return cfb.ReadError(err)
+	}
+	size := int64(min(binary.LittleEndian.Uint64(sizeField[:]), 1<<62))
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("failed to create decrypted file: %w", err)
+	}
+	if err := decrypt(dst, pkg, size); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// CheckPassword checks that password opens an encrypted xlsx, xlsm or xlsb
+// file without decrypting it
+func CheckPassword(inputPath, password string) error {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	cf, err := cfb.Open(file)
+	if err != nil {
+		return err
+	}
+	_, err = ooxmlDecrypter(cf, password)
+	return err
+}
+
+// ooxmlDecrypter reads the EncryptionInfo stream, checks password against it and
+// returns the decrypter of the package
+func ooxmlDecrypter(cf *cfb.File, password string) (packageDecrypter, error) {
+	info, err := cf.ReadStream("EncryptionInfo")
+	if err != nil {
+		return nil, err
+	}
+	return infoDecrypter(info, password)
+}
+
+// infoDecrypter parses the content of an EncryptionInfo stream: the version, flags
+// and the descriptor of the encryption it names
+func infoDecrypter(info []byte, password string) (packageDecrypter, error) {
+	if len(info) < 8 {
+		return nil, cfb.ErrCorrupt
+	}
+
+	major, minor := binary.LittleEndian.Uint16(info), binary.LittleEndian.Uint16(info[2:])
+	switch {
+	case major == 4 && minor == 4:
+		return agileDecrypter(info[8:], password)
+	case (major == 3 || major == 4) && minor == 2:
+		return standardDecrypter(info[8:], password)
+	default:
+		return nil, fmt.Errorf("unsupported encryption version %d.%d", major, minor)
+	}
+}
+
+// agileDecrypter checks password against an agile encryption descriptor and
+// returns a function decrypting the package with the unlocked key
+func agileDecrypter(descriptor []byte, password string) (packageDecrypter, error) {
+	var encryption agileEncryption
+	if err := xml.Unmarshal(descriptor, &encryption); err != nil {
+		return nil, fmt.Errorf("invalid encryption descriptor: %w", err)
+	}
+
+	var encryptor *agileKeyData
+	for i := range encryption.KeyEncryptors {
+		if encryption.KeyEncryptors[i].URI == "http://schemas.microsoft.com/office/2006/keyEncryptor/password" {
+			encryptor = &encryption.KeyEncryptors[i].EncryptedKey
+		}
+	}
+	if encryptor == nil {
+		return nil, errors.New("workbook is not encrypted with a password")
+	}
+	keyData := encryption.KeyData
+	for _, params := range []*agileKeyData{encryptor, &keyData} {
+		if params.CipherAlgorithm != "AES" || params.CipherChaining != "ChainingModeCBC" {
+			return nil, fmt.Errorf("unsupported cipher %s %s", params.CipherAlgorithm, params.CipherChaining)
+		}
+		if params.BlockSize != aes.BlockSize || (params.KeyBits != 128 && params.KeyBits != 192 && params.KeyBits != 256) {
+			return nil, fmt.Errorf("unsupported AES parameters, block size %d and key size %d", params.BlockSize, params.KeyBits)
+		}
+	}
+	if encryptor.SpinCount < 0 || encryptor.SpinCount > maxSpinCount {
+		return nil, fmt.Errorf("unsupported spin count %d", encryptor.SpinCount)
+	}
+
+	newHash, err := hashAlgorithm(encryptor.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	newDataHash, err := hashAlgorithm(keyData.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded [5][]byte
+	for i, value := range []string{encryptor.SaltValue, encryptor.EncryptedVerifierHashInput,
+		encryptor.EncryptedVerifierHashValue, encryptor.EncryptedKeyValue, keyData.SaltValue} {
+		if encoded[i], err = base64.StdEncoding.DecodeString(value); err != nil {
+			return nil, fmt.Errorf("invalid encryption descriptor: %w", err)
+		}
+	}
+	salt, verifierInput, verifierHash, keyValue, dataSalt := encoded[0], encoded[1], encoded[2], encoded[3], encoded[4]
+
+	passwordHash := iteratedHash(newHash, salt, password, encryptor.SpinCount)
+	iv := fitBytes(salt, encryptor.BlockSize, 0x36)
+	unlock := func(block, encrypted []byte) ([]byte, error) {
+		h := newHash()
+		h.Write(passwordHash)
+		h.Write(block)
+		return decryptCBC(fitBytes(h.Sum(nil), encryptor.KeyBits/8, 0x36), iv, encrypted)
+	}
+
+	input, err := unlock(agileVerifierInputBlock, verifierInput)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := unlock(agileVerifierHashBlock, verifierHash)
+	if err != nil {
+		return nil, err
+	}
+	h := newHash()
+	h.Write(input[:min(len(input), max(encryptor.SaltSize, 0))])
+	actual := h.Sum(nil)
+	if len(expected) < len(actual) || subtle.ConstantTimeCompare(actual, expected[:len(actual)]) != 1 {
+		return nil, ErrWrongPassword
+	}
+
+	key, err := unlock(agileKeyValueBlock, keyValue)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < keyData.KeyBits/8 {
+		return nil, errors.New("invalid encryption descriptor: key too short")
+	}
+	key = key[:keyData.KeyBits/8]
+
+	return func(dst io.Writer, src io.Reader, size int64) error {
+		segment := make([]byte, agileSegmentSize)
+		var index [4]byte
+		for i := uint32(0); size > 0; i++ {
+			n, err := io.ReadFull(src, segment)
+			if n == 0 || (err != nil && !errors.Is(err, io.ErrUnexpectedEOF)) {
+				return cfb.ReadError(err)
+			}
+
+			h := newDataHash()
+			h.Write(dataSalt)
+			binary.LittleEndian.PutUint32(index[:], i)
+			h.Write(index[:])
+			plain, err := decryptCBC(key, fitBytes(h.Sum(nil), keyData.BlockSize, 0x36), segment[:n])
+			if err != nil {
+				return err
+			}
+
+			plain = plain[:min(int64(len(plain)), size)]
+			if _, err := dst.Write(plain); err != nil {
+				return fmt.Errorf("failed to write decrypted file: %w", err)
+			}
+			size -= int64(len(plain))
+		}
+		return nil
+	}, nil
+}
+
+// standardDecrypter checks password against the standard encryption header and
+// verifier of Office 2007 and returns a function decrypting the package
+func standardDecrypter(info []byte, password string) (packageDecrypter, error) {
+	// Header size, header, then the verifier
+	if len(info) < 4 {
+		return nil, cfb.ErrCorrupt
+	}
+	headerSize := int(binary.LittleEndian.Uint32(info))
+	if headerSize < 32 || len(info) < 4+headerSize+72 {
+		return nil, cfb.ErrCorrupt
+	}
+	header, verifier := info[4:4+headerSize], info[4+headerSize:]
+
+	algorithm, keyBits := binary.LittleEndian.Uint32(header[8:]), int(binary.LittleEndian.Uint32(header[16:]))
+	if algorithm != 0x660E && algorithm != 0x660F && algorithm != 0x6610 {
+		return nil, fmt.Errorf("unsupported cipher 0x%04X", algorithm)
+	}
+	if keyBits != 128 && keyBits != 192 && keyBits != 256 {
+		return nil, fmt.Errorf("unsupported key size %d", keyBits)
+	}
+	if binary.LittleEndian.Uint32(verifier) != 16 {
+		return nil, cfb.ErrCorrupt
+	}
+	salt, encryptedVerifier, encryptedHash := verifier[4:20], verifier[20:36], verifier[40:72]
+
+	// The key is derived from the iterated SHA-1 password hash as CryptoAPI does
+	passwordHash := iteratedHash(sha1.New, salt, password, 50000)
+	final := sha1.Sum(append(passwordHash, 0, 0, 0, 0))
+	derive := func(pad byte) [sha1.Size]byte {
+		buffer := bytes.Repeat([]byte{pad}, 64)
+		for i, b := range final {
+			buffer[i] ^= b
+		}
+		return sha1.Sum(buffer)
+	}
+	x1, x2 := derive(0x36), derive(0x5c)
+	key := append(x1[:], x2[:]...)[:keyBits/8]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plainVerifier := decryptECB(block, encryptedVerifier)
+	expected := decryptECB(block, encryptedHash)
+	actual := sha1.Sum(plainVerifier)
+	if subtle.ConstantTimeCompare(actual[:], expected[:sha1.Size]) != 1 {
+		return nil, ErrWrongPassword
+	}
+
+	return func(dst io.Writer, src io.Reader, size int64) error {
+		chunk := make([]byte, agileSegmentSize)
+		for size > 0 {
+			n, err := io.ReadFull(src, chunk)
+			n -= n % aes.BlockSize
+			if n == 0 || (err != nil && !errors.Is(err, io.ErrUnexpectedEOF)) {
+				return cfb.ReadError(err)
+			}
+
+			plain := decryptECB(block, chunk[:n])
+			plain = plain[:min(int64(len(plain)), size)]
+			if _, err := dst.Write(plain); err != nil {
+				return fmt.Errorf("failed to write decrypted file: %w", err)
+			}
+			size -= int64(len(plain))
+		}
+		return nil
+	}, nil
+}
+
+// iteratedHash hashes the salt and the UTF-16 password, then rehashes the result
+// with the round number spinCount times
+func iteratedHash(newHash func() hash.Hash, salt []byte, password string, spinCount int) []byte {
+	h := newHash()
+	h.Write(salt)
+	h.Write(utf16LE(password))
+	sum := h.Sum(nil)
+
+	var round [4]byte
+	for i := 0; i < spinCount; i++ {
+		binary.LittleEndian.PutUint32(round[:], uint32(i))
+		h.Reset()
+		h.Write(round[:])
+		h.Write(sum)
+		sum = h.Sum(sum[:0])
+	}
+	return sum
+}
+
+// hashAlgorithm returns the hash named in an agile encryption descriptor
+func hashAlgorithm(name string) (func() hash.Hash, error) {
+	switch name {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA384":
+		return sha512.New384, nil
+	case "SHA512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %q", name)
+	}
+}
+
+// fitBytes truncates b to size bytes or pads it with pad
+func fitBytes(b []byte, size int, pad byte) []byte {
+	if len(b) >= size {
+		return b[:size]
+	}
+	return append(bytes.Clone(b), bytes.Repeat([]byte{pad}, size-len(b))...)
+}
+
+// decryptCBC decrypts AES-CBC data, which must be whole blocks
+func decryptCBC(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("encrypted data is not a whole number of blocks")
+	}
+
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	return plain, nil
+}
+
+// decryptECB decrypts AES-ECB data block by block, a trailing partial block is dropped
+func decryptECB(block cipher.Block, data []byte) []byte {
+	plain := make([]byte, len(data)-len(data)%aes.BlockSize)
+	for i := 0; i < len(plain); i += aes.BlockSize {
+		block.Decrypt(plain[i:], data[i:])
+	}
+	return plain
+}
@@ -0,0 +1,100 @@
+package officecrypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oxyii/excel2csv/internal/cfb"
+	"github.com/oxyii/excel2csv/internal/officecrypto/officecryptotest"
+)
+
+func TestDecrypt(t *testing.T) {
+	plain := officecryptotest.RandomBytes(t, 3*agileSegmentSize+100) // segments and a partial block
+
+	tests := []struct {
+		name     string
+		file     []byte
+		password string
+		wantErr  error
+	}{
+		{"agile", officecryptotest.EncryptAgile(t, plain, "secret", 1000), "secret", nil},
+		{"agile wrong password", officecryptotest.EncryptAgile(t, plain, "secret", 1000), "Secret", ErrWrongPassword},
+		{"agile small package", officecryptotest.EncryptAgile(t, plain[:100], "pässwörd", 10), "pässwörd", nil},
+		{"standard", officecryptotest.EncryptStandard(t, plain, "secret"), "secret", nil},
+		{"standard wrong password", officecryptotest.EncryptStandard(t, plain, "secret"), "other", ErrWrongPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, "book.xlsx")
+			if err := os.WriteFile(inputPath, tt.file, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := CheckPassword(inputPath, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckPassword() error = %v, want %v", err, tt.wantErr)
+			}
+
+			outputPath := filepath.Join(dir, "plain.xlsx")
+			err := Decrypt(inputPath, outputPath, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := plain
+			if tt.name == "agile small package" {
+				want = plain[:100]
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Decrypt() wrote %d bytes, differing from the %d plain bytes", len(got), len(want))
+			}
+		})
+	}
+}
+
+// FuzzEncryptionInfo feeds EncryptionInfo streams to the parser of the encryption
+// descriptors, and the package that follows to a decrypter that accepts the password
+func FuzzEncryptionInfo(f *testing.F) {
+	// Password hashing rounds are what a crafted file costs, keep them cheap here
+	defer func(limit int) { maxSpinCount = limit }(maxSpinCount)
+	maxSpinCount = 100
+
+	for _, file := range [][]byte{
+		officecryptotest.EncryptAgile(f, []byte("package"), "secret", 10),
+		officecryptotest.EncryptStandard(f, []byte("package"), "secret"),
+	} {
+		cf, err := cfb.Open(bytes.NewReader(file))
+		if err != nil {
+			f.Fatal(err)
+		}
+		info, err := cf.ReadStream("EncryptionInfo")
+		if err != nil {
+			f.Fatal(err)
+		}
+		pkg, err := cf.ReadStream("EncryptedPackage")
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(info, pkg[8:])
+	}
+
+	f.Fuzz(func(t *testing.T, info, pkg []byte) {
+		decrypt, err := infoDecrypter(info, "secret")
+		if err != nil {
+			return
+		}
+		_ = decrypt(io.Discard, bytes.NewReader(pkg), int64(len(pkg)))
+	})
+}
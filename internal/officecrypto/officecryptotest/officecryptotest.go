@@ -0,0 +1,227 @@
+// Package officecryptotest encrypts workbooks for tests of the code decrypting them.
+// It follows the Office document cryptography specification on its own rather than
+// reusing the decryption code, so the two check each other.
+package officecryptotest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/oxyii/excel2csv/internal/cfb/cfbtest"
+)
+
+// segmentSize is the size of the independently encrypted segments of an agile package
+const segmentSize = 4096
+
+// Block keys of the agile encryption key derivation
+var (
+	verifierInputBlock = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	verifierHashBlock  = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+	keyValueBlock      = []byte{0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6}
+)
+
+// RandomBytes returns n random bytes, for salts, keys and package content
+func RandomBytes(t testing.TB, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// encryptCBC encrypts data with AES-CBC, zero padded to whole blocks
+func encryptCBC(t testing.TB, key, iv, data []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padded := make([]byte, (len(data)+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+	copy(padded, data)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+	return padded
+}
+
+// EncryptAgile encrypts a package with agile encryption, as Office does by default
+func EncryptAgile(t testing.TB, plain []byte, password string, spinCount int) []byte {
+	t.Helper()
+	salt, dataSalt, key, verifier := RandomBytes(t, 16), RandomBytes(t, 16), RandomBytes(t, 32), RandomBytes(t, 16)
+
+	passwordHash := iteratedHash(sha512.New, salt, password, spinCount)
+	lock := func(block, data []byte) string {
+		sum := sha512.Sum512(append(bytes.Clone(passwordHash), block...))
+		return base64.StdEncoding.EncodeToString(encryptCBC(t, sum[:32], salt, data))
+	}
+	verifierHash := sha512.Sum512(verifier)
+
+	descriptor := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<encryption xmlns="http://schemas.microsoft.com/office/2006/encryption" xmlns:p="http://schemas.microsoft.com/office/2006/keyEncryptor/password">
+<keyData saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="%s"/>
+<keyEncryptors><keyEncryptor uri="http://schemas.microsoft.com/office/2006/keyEncryptor/password">
+<p:encryptedKey spinCount="%d" saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="%s" encryptedVerifierHashInput="%s" encryptedVerifierHashValue="%s" encryptedKeyValue="%s"/>
+</keyEncryptor></keyEncryptors></encryption>`,
+		base64.StdEncoding.EncodeToString(dataSalt), spinCount, base64.StdEncoding.EncodeToString(salt),
+		lock(verifierInputBlock, verifier), lock(verifierHashBlock, verifierHash[:]), lock(keyValueBlock, key))
+	info := append([]byte{4, 0, 4, 0, 0x40, 0, 0, 0}, descriptor...)
+
+	pkg := binary.LittleEndian.AppendUint64(nil, uint64(len(plain)))
+	for i := 0; i*segmentSize < len(plain); i++ {
+		segment := plain[i*segmentSize : min((i+1)*segmentSize, len(plain))]
+		iv := sha512.Sum512(binary.LittleEndian.AppendUint32(bytes.Clone(dataSalt), uint32(i)))
+		pkg = append(pkg, encryptCBC(t, key, iv[:16], segment)...)
+	}
+
+	return cfbtest.Build(t, []string{"EncryptionInfo", "EncryptedPackage"},
+		map[string][]byte{"EncryptionInfo": info, "EncryptedPackage": pkg})
+}
+
+// EncryptStandard encrypts a package with the standard AES-128 encryption of Office 2007
+func EncryptStandard(t testing.TB, plain []byte, password string) []byte {
+	t.Helper()
+	salt, verifier := RandomBytes(t, 16), RandomBytes(t, 16)
+
+	passwordHash := iteratedHash(sha1.New, salt, password, 50000)
+	final := sha1.Sum(append(passwordHash, 0, 0, 0, 0))
+	buffer := bytes.Repeat([]byte{0x36}, 64)
+	for i, b := range final {
+		buffer[i] ^= b
+	}
+	x1 := sha1.Sum(buffer)
+	block, err := aes.NewCipher(x1[:16])
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptECB := func(data []byte) []byte {
+		padded := make([]byte, (len(data)+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+		copy(padded, data)
+		for i := 0; i < len(padded); i += aes.BlockSize {
+			block.Encrypt(padded[i:], padded[i:])
+		}
+		return padded
+	}
+
+	header := make([]byte, 32)
+	binary.LittleEndian.PutUint32(header[0:], 0x24)
+	binary.LittleEndian.PutUint32(header[8:], 0x660E)
+	binary.LittleEndian.PutUint32(header[12:], 0x8004)
+	binary.LittleEndian.PutUint32(header[16:], 128)
+	binary.LittleEndian.PutUint32(header[20:], 0x18)
+	verifierHash := sha1.Sum(verifier)
+
+	info := []byte{3, 0, 2, 0, 0x24, 0, 0, 0}
+	info = binary.LittleEndian.AppendUint32(info, uint32(len(header)))
+	info = append(info, header...)
+	info = binary.LittleEndian.AppendUint32(info, 16)
+	info = append(info, salt...)
+	info = append(info, encryptECB(verifier)...)
+	info = binary.LittleEndian.AppendUint32(info, 20)
+	info = append(info, encryptECB(verifierHash[:])...)
+
+	pkg := binary.LittleEndian.AppendUint64(nil, uint64(len(plain)))
+	pkg = append(pkg, encryptECB(plain)...)
+
+	return cfbtest.Build(t, []string{"EncryptionInfo", "EncryptedPackage"},
+		map[string][]byte{"EncryptionInfo": info, "EncryptedPackage": pkg})
+}
+
+// XORFilePass builds a FilePass record of XOR obfuscation with password
+func XORFilePass(password string) []byte {
+	filePass := make([]byte, 6)
+	binary.LittleEndian.PutUint16(filePass[4:], xorVerifier(password))
+	return filePass
+}
+
+// RC4FilePass builds a FilePass record of RC4 encryption with password
+func RC4FilePass(t testing.TB, password string) []byte {
+	salt, verifier := RandomBytes(t, 16), RandomBytes(t, 16)
+	hash := md5.Sum(utf16LE(password))
+	var buffer []byte
+	for range 16 {
+		buffer = append(append(buffer, hash[:5]...), salt...)
+	}
+	hash = md5.Sum(buffer)
+	key := md5.Sum(append(hash[:5:5], 0, 0, 0, 0))
+
+	stream, _ := rc4.NewCipher(key[:])
+	verifierHash := md5.Sum(verifier)
+	encrypted := append(bytes.Clone(verifier), verifierHash[:]...)
+	stream.XORKeyStream(encrypted, encrypted)
+
+	return append(append([]byte{1, 0, 1, 0, 1, 0}, salt...), encrypted...)
+}
+
+// CryptoAPIFilePass builds a FilePass record of RC4 CryptoAPI encryption with password,
+// the encryption Excel uses for write-protected workbooks
+func CryptoAPIFilePass(t testing.TB, password string) []byte {
+	salt, verifier := RandomBytes(t, 16), RandomBytes(t, 16)
+	hash := sha1.Sum(append(bytes.Clone(salt), utf16LE(password)...))
+	final := sha1.Sum(append(hash[:], 0, 0, 0, 0))
+
+	stream, _ := rc4.NewCipher(final[:16])
+	verifierHash := sha1.Sum(verifier)
+	encrypted := append(bytes.Clone(verifier), verifierHash[:]...)
+	stream.XORKeyStream(encrypted, encrypted)
+
+	header := make([]byte, 32)
+	binary.LittleEndian.PutUint32(header[8:], 0x6801)  // RC4
+	binary.LittleEndian.PutUint32(header[12:], 0x8004) // SHA-1
+	binary.LittleEndian.PutUint32(header[16:], 128)
+
+	filePass := []byte{1, 0, 4, 0, 2, 0, 0x04, 0, 0, 0}
+	filePass = binary.LittleEndian.AppendUint32(filePass, uint32(len(header)))
+	filePass = append(filePass, header...)
+	filePass = binary.LittleEndian.AppendUint32(filePass, 16)
+	filePass = append(filePass, salt...)
+	filePass = append(filePass, encrypted[:16]...)
+	filePass = binary.LittleEndian.AppendUint32(filePass, 20)
+	return append(filePass, encrypted[16:]...)
+}
+
+// iteratedHash hashes the salt and the UTF-16 password, then rehashes the result
+// with the round number spinCount times
+func iteratedHash(newHash func() hash.Hash, salt []byte, password string, spinCount int) []byte {
+	h := newHash()
+	h.Write(salt)
+	h.Write(utf16LE(password))
+	sum := h.Sum(nil)
+	for i := range spinCount {
+		h.Reset()
+		h.Write(binary.LittleEndian.AppendUint32(nil, uint32(i)))
+		h.Write(sum)
+		sum = h.Sum(nil)
+	}
+	return sum
+}
+
+// xorVerifier computes the password verifier of XOR obfuscation
+func xorVerifier(password string) uint16 {
+	var verifier uint16
+	for i := len(password) - 1; i >= 0; i-- {
+		verifier = (verifier>>14)&1 | (verifier<<1)&0x7FFF
+		verifier ^= uint16(password[i])
+	}
+	verifier = (verifier>>14)&1 | (verifier<<1)&0x7FFF
+	return verifier ^ uint16(len(password)) ^ 0xCE4B
+}
+
+// utf16LE encodes s as little-endian UTF-16
+func utf16LE(s string) []byte {
+	var encoded []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		encoded = binary.LittleEndian.AppendUint16(encoded, unit)
+	}
+	return encoded
+}
@@ -0,0 +1,112 @@
+package officecrypto
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"unicode/utf16"
+)
+
+// XLSPasswordMatches checks password against the verifier of the FilePass record of
+// an xls workbook: XOR obfuscation, RC4 or RC4 CryptoAPI encryption. Unknown
+// encryption matches, so whoever opens the file reports it.
+func XLSPasswordMatches(filePass []byte, password string) bool {
+	if len(filePass) < 6 {
+		return true
+	}
+
+	if binary.LittleEndian.Uint16(filePass) == 0 {
+		// XOR obfuscation: key, then the verifier of the password
+		return binary.LittleEndian.Uint16(filePass[4:]) == xorPasswordVerifier(password)
+	}
+
+	major, minor := binary.LittleEndian.Uint16(filePass[2:]), binary.LittleEndian.Uint16(filePass[4:])
+	switch {
+	case major == 1 && minor == 1:
+		// RC4: salt, encrypted verifier and encrypted MD5 hash of the verifier
+		if len(filePass) < 54 {
+			return true
+		}
+		salt := filePass[6:22]
+		hash := md5.Sum(utf16LE(password))
+		buffer := make([]byte, 0, 16*(5+len(salt)))
+		for range 16 {
+			buffer = append(append(buffer, hash[:5]...), salt...)
+		}
+		hash = md5.Sum(buffer)
+		key := md5.Sum(append(hash[:5:5], 0, 0, 0, 0))
+		return rc4VerifierMatches(key[:], filePass[22:38], filePass[38:54], md5.New())
+	case major >= 2 && major <= 4 && minor == 2:
+		// RC4 CryptoAPI: flags, header size, header, then salt size, salt, encrypted
+		// verifier, hash size and encrypted SHA-1 hash of the verifier
+		if len(filePass) < 14 {
+			return true
+		}
+		headerSize := int(binary.LittleEndian.Uint32(filePass[10:]))
+		verifier := 14 + headerSize
+		if headerSize < 32 || len(filePass) < verifier+60 {
+			return true
+		}
+		keyBits := int(binary.LittleEndian.Uint32(filePass[14+16:]))
+		if keyBits == 0 {
+			keyBits = 40
+		}
+		if keyBits < 40 || keyBits > 128 || keyBits%8 != 0 {
+			return true
+		}
+
+		salt := filePass[verifier+4 : verifier+20]
+		hash := sha1.Sum(append(bytes.Clone(salt), utf16LE(password)...))
+		final := sha1.Sum(append(hash[:], 0, 0, 0, 0))
+		key := final[:keyBits/8]
+		if keyBits == 40 {
+			// 40 bit keys are padded to 128 bits
+			key = append(bytes.Clone(key), make([]byte, 11)...)
+		}
+		return rc4VerifierMatches(key, filePass[verifier+20:verifier+36], filePass[verifier+40:verifier+60], sha1.New())
+	default:
+		return true
+	}
+}
+
+// rc4VerifierMatches decrypts a verifier and its hash with one RC4 stream and
+// compares the hash of the verifier
+func rc4VerifierMatches(key, encryptedVerifier, encryptedHash []byte, h hash.Hash) bool {
+	stream, err := rc4.NewCipher(key)
+	if err != nil {
+		return true
+	}
+	verifier := make([]byte, len(encryptedVerifier))
+	stream.XORKeyStream(verifier, encryptedVerifier)
+	expected := make([]byte, len(encryptedHash))
+	stream.XORKeyStream(expected, encryptedHash)
+
+	h.Write(verifier)
+	return bytes.Equal(h.Sum(nil), expected)
+}
+
+// xorPasswordVerifier computes the 16 bit password verifier of XOR obfuscation
+// from the single byte characters of password
+func xorPasswordVerifier(password string) uint16 {
+	characters := append([]byte{byte(len(password))}, password...)
+	var verifier uint16
+	for i := len(characters) - 1; i >= 0; i-- {
+		verifier = (verifier>>14)&1 | (verifier<<1)&0x7FFF
+		verifier ^= uint16(characters[i])
+	}
+	return verifier ^ 0xCE4B
+}
+
+// utf16LE encodes a string as little-endian UTF-16, the way compound files store
+// names and encryption hashes passwords
+func utf16LE(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	result := make([]byte, len(encoded)*2)
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(result[i*2:], c)
+	}
+	return result
+}
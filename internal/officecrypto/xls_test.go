@@ -0,0 +1,53 @@
+package officecrypto
+
+import (
+	"testing"
+
+	"github.com/oxyii/excel2csv/internal/officecrypto/officecryptotest"
+)
+
+func TestXLSPasswordMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		filePass []byte
+		password string
+		want     bool
+	}{
+		{"xor", officecryptotest.XORFilePass("secret"), "secret", true},
+		{"xor wrong password", officecryptotest.XORFilePass("secret"), "Secret", false},
+		{"rc4", officecryptotest.RC4FilePass(t, "secret"), "secret", true},
+		{"rc4 wrong password", officecryptotest.RC4FilePass(t, "secret"), "other", false},
+		{"cryptoapi", officecryptotest.CryptoAPIFilePass(t, "pässwörd"), "pässwörd", true},
+		{"cryptoapi wrong password", officecryptotest.CryptoAPIFilePass(t, "secret"), "other", false},
+		// Unknown and truncated records are left to whoever opens the file
+		{"unknown encryption", []byte{1, 0, 9, 0, 9, 0}, "other", true},
+		{"truncated rc4", officecryptotest.RC4FilePass(t, "secret")[:40], "other", true},
+		{"truncated record", []byte{1, 0}, "other", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := XLSPasswordMatches(tt.filePass, tt.password); got != tt.want {
+				t.Errorf("XLSPasswordMatches() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXORPasswordVerifier(t *testing.T) {
+	// Example from the Office document cryptography specification
+	if got := xorPasswordVerifier("abcdefghij"); got != 0xFEF1 {
+		t.Errorf("xorPasswordVerifier() = %04X, want FEF1", got)
+	}
+}
+
+// FuzzXLSPasswordMatches feeds FilePass records to the xls password verifiers
+func FuzzXLSPasswordMatches(f *testing.F) {
+	f.Add(officecryptotest.XORFilePass("secret"))
+	f.Add(officecryptotest.RC4FilePass(f, "secret"))
+	f.Add(officecryptotest.CryptoAPIFilePass(f, "secret"))
+
+	f.Fuzz(func(t *testing.T, filePass []byte) {
+		XLSPasswordMatches(filePass, "secret")
+	})
+}
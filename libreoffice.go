@@ -0,0 +1,179 @@
+package excel2csv
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Errors of LibreOffice conversions, wrapped with the details of the failure
+// including LibreOffice's output
+var (
+	// ErrLibreOfficeNotFound is returned when no LibreOffice binary is found
+	ErrLibreOfficeNotFound = errors.New("LibreOffice is not available")
+	// ErrLibreOfficeLoadFailed is returned when LibreOffice fails or can't load the input file
+	ErrLibreOfficeLoadFailed = errors.New("LibreOffice could not convert the file")
+	// ErrNoOutputProduced is returned when LibreOffice exits without an error but writes no CSV
+	ErrNoOutputProduced = errors.New("LibreOffice did not generate CSV file")
+)
+
+// libreOfficeRuns counts the LibreOffice processes started
+var libreOfficeRuns atomic.Int64
+
+// LibreOfficeRuns returns the number of LibreOffice processes started so far
+// by this program, including retries, for metrics
+func LibreOfficeRuns() int64 {
+	return libreOfficeRuns.Load()
+}
+
+// libreOfficeError adds LibreOffice's output, if any, to err
+func libreOfficeError(err error, output []byte) error {
+	text := strings.TrimSpace(string(output))
+	if text == "" {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, text)
+}
+
+// FindLibreOffice returns the path of the LibreOffice binary.
+// It checks the LIBREOFFICE_PATH environment variable, then libreoffice and soffice
+// on PATH, then the default install locations of the current platform.
+func FindLibreOffice() (string, error) {
+	if path := os.Getenv("LIBREOFFICE_PATH"); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("%w: LIBREOFFICE_PATH is set but not usable: %w", ErrLibreOfficeNotFound, err)
+		}
+		return path, nil
+	}
+
+	for _, name := range []string{"libreoffice", "soffice"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+
+	for _, path := range libreOfficeInstallPaths() {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w. Please install LibreOffice or set LIBREOFFICE_PATH", ErrLibreOfficeNotFound)
+}
+
+// LibreOfficeVersion runs libreoffice --version and returns the version number,
+// e.g. "7.6.4.1" from "LibreOffice 7.6.4.1 e19e193f88cd6c0525a17fb7a176ed8e6a3e2aa1"
+func LibreOfficeVersion() (string, error) {
+	libreOfficePath, err := FindLibreOffice()
+	if err != nil {
+		return "", err
+	}
+	return libreOfficeVersion(libreOfficePath)
+}
+
+// libreOfficeVersion runs libreOfficePath --version and returns the version number
+func libreOfficeVersion(libreOfficePath string) (string, error) {
+	// A throwaway profile keeps this off the shared profile lock like every other run
+	profileParent, err := os.MkdirTemp("", "excel2csv_version_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(profileParent) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, libreOfficePath, profileArg(profileParent), "--version")
+	libreOfficeRuns.Add(1)
+
+	output, err := runProcessTree(cmd, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to get LibreOffice version: %w", err)
+	}
+
+	// Snap and some distro builds print warnings first, so look for the LibreOffice line
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.HasPrefix(fields[0], "LibreOffice") {
+			return fields[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("unexpected LibreOffice version output: %q", strings.TrimSpace(string(output)))
+}
+
+// libreOfficeInstallPaths lists the default install locations of the current platform
+func libreOfficeInstallPaths() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"/Applications/LibreOffice.app/Contents/MacOS/soffice"}
+	case "windows":
+		var paths []string
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+			if dir := os.Getenv(env); dir != "" {
+				paths = append(paths, filepath.Join(dir, "LibreOffice", "program", "soffice.exe"))
+			}
+		}
+		return paths
+	default:
+		return []string{
+			"/usr/lib/libreoffice/program/soffice",
+			"/opt/libreoffice/program/soffice",
+			"/snap/bin/libreoffice",
+		}
+	}
+}
+
+// sheetSelectionVersion is the first LibreOffice version whose CSV export filter takes
+// the sheet to export. Older versions ignore the option and export the default sheet.
+const sheetSelectionVersion = "7.2"
+
+// sheetSelectionChecked caches the LibreOffice binaries found to support sheet selection
+var sheetSelectionChecked sync.Map
+
+// checkSheetSelection fails if the LibreOffice at libreOfficePath is too old to export
+// a sheet other than the default one, which it would do without notice
+func checkSheetSelection(libreOfficePath string) error {
+	if _, ok := sheetSelectionChecked.Load(libreOfficePath); ok {
+		return nil
+	}
+
+	version, err := libreOfficeVersion(libreOfficePath)
+	if err != nil {
+		return fmt.Errorf("selecting a sheet needs LibreOffice %s or later: %w", sheetSelectionVersion, err)
+	}
+	if compareVersions(version, sheetSelectionVersion) < 0 {
+		return fmt.Errorf("selecting a sheet needs LibreOffice %s or later, found %s", sheetSelectionVersion, version)
+	}
+
+	sheetSelectionChecked.Store(libreOfficePath, true)
+	return nil
+}
+
+// compareVersions compares dotted version numbers like "7.6.4.1" part by part,
+// missing and non-numeric parts count as 0
+func compareVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(aParts), len(bParts)) {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[i])
+		}
+		if aPart != bPart {
+			return cmp.Compare(aPart, bPart)
+		}
+	}
+	return 0
+}
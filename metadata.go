@@ -0,0 +1,50 @@
+package excel2csv
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeMetadataComment writes the IncludeMetadataComment line for a table of the given
+// sheet and input file, if enabled. rows counts the data rows, without the header.
+// Parquet output has no place for comments and gets none.
+func (ec *ExcelConverter) writeMetadataComment(w io.Writer, inputPath, sheet string, records [][]string) error {
+	if !ec.IncludeMetadataComment || ec.OutputFormat == FormatParquet {
+		return nil
+	}
+
+	prefix := ec.MetadataCommentPrefix
+	if prefix == "" {
+		prefix = "#"
+	}
+
+	fields := []string{prefix}
+	if inputPath != "" {
+		fields = append(fields, "source="+metadataValue(filepath.Base(inputPath)))
+	}
+	fields = append(fields,
+		"sheet="+metadataValue(sheet),
+		fmt.Sprintf("rows=%d", max(len(records)-1, 0)),
+		"generated="+time.Now().UTC().Format(time.RFC3339),
+	)
+
+	lineEnding := "\n"
+	if ec.LineEnding == LineEndingCRLF {
+		lineEnding = "\r\n"
+	}
+
+	_, err := io.WriteString(w, strings.Join(fields, " ")+lineEnding)
+	return err
+}
+
+// metadataValue quotes values that would break the key=value format of the comment line
+func metadataValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
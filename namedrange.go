@@ -0,0 +1,241 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// maxWorkbookPart limits how much of a workbook, relationships or table part is read
+const maxWorkbookPart = 16 << 20
+
+// workbookXML is the part of xl/workbook.xml that lists the sheets and defined names
+type workbookXML struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+	DefinedNames []struct {
+		Name         string `xml:"name,attr"`
+		LocalSheetID *int   `xml:"localSheetId,attr"`
+		Value        string `xml:",chardata"`
+	} `xml:"definedNames>definedName"`
+}
+
+// relationshipsXML is a .rels part, which maps relationship IDs to the parts they point to
+type relationshipsXML struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Type   string `xml:"Type,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// tableXML is the part of a table object that locates it
+type tableXML struct {
+	Name           string `xml:"name,attr"`
+	DisplayName    string `xml:"displayName,attr"`
+	Ref            string `xml:"ref,attr"`
+	TotalsRowCount int    `xml:"totalsRowCount,attr"`
+}
+
+// withNamedRange returns ec if NamedRange is empty, else a copy that selects the sheet
+// of the named range or table with SheetName and its cells with CellRange
+func (ec *ExcelConverter) withNamedRange(inputPath string) (*ExcelConverter, error) {
+	if ec.NamedRange == "" {
+		return ec, nil
+	}
+
+	switch {
+	case ec.AllSheetsMode || ec.SheetPattern != "":
+		return nil, fmt.Errorf("NamedRange selects a single sheet, it can't be combined with all sheets mode")
+	case ec.CellRange != "":
+		return nil, fmt.Errorf("NamedRange can't be combined with CellRange")
+	}
+
+	ext := ec.inputExt(inputPath)
+	if ext != ".xlsx" && ext != ".xlsm" {
+		return nil, fmt.Errorf("named ranges and tables are only read from .xlsx and .xlsm files, not %s", ext)
+	}
+
+	// Names are read from the workbook itself, which for encrypted files is a decrypted copy
+	workbookPath := inputPath
+	if protected, err := ec.checkEncryption(inputPath, ext); err != nil {
+		return nil, err
+	} else if protected {
+		tempDir, err := ec.createTempDir()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = os.RemoveAll(tempDir) }()
+		if workbookPath, err = ec.decryptInput(inputPath, tempDir); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, cells, err := resolveNamedRange(workbookPath, ec.NamedRange)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Using %s: sheet %q, cells %s\n", ec.NamedRange, sheet, cells)
+
+	converter := *ec
+	converter.NamedRange = ""
+	converter.SheetName = sheet
+	converter.SheetIndex = nil
+	converter.CellRange = cells
+	return &converter, nil
+}
+
+// resolveNamedRange looks up a defined name or a table object of an xlsx workbook, names
+// are matched case-insensitively like Excel does. It returns the sheet and the A1 range,
+// a table's totals row is left out.
+func resolveNamedRange(inputPath, name string) (string, string, error) {
+	reader, err := zip.OpenReader(inputPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read workbook: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	entries := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		entries[file.Name] = file
+	}
+
+	var workbook workbookXML
+	if err := readZipXML(entries, "xl/workbook.xml", &workbook); err != nil {
+		return "", "", err
+	}
+
+	return findNamedRange(entries, &workbook, name)
+}
+
+// findNamedRange looks for a defined name, then for a table object called name
+func findNamedRange(entries map[string]*zip.File, workbook *workbookXML, name string) (string, string, error) {
+	// Workbook scoped names win over names local to a sheet
+	var local []string
+	for _, defined := range workbook.DefinedNames {
+		if !strings.EqualFold(defined.Name, name) {
+			continue
+		}
+		if defined.LocalSheetID == nil {
+			return splitSheetReference(name, defined.Value)
+		}
+		local = append(local, defined.Value)
+	}
+	switch len(local) {
+	case 0:
+	case 1:
+		return splitSheetReference(name, local[0])
+	default:
+		return "", "", fmt.Errorf("name %q is defined on %d sheets, use CellRange to pick one", name, len(local))
+	}
+
+	// Tables belong to sheets through the sheet relationships
+	var workbookRels relationshipsXML
+	if err := readZipXML(entries, "xl/_rels/workbook.xml.rels", &workbookRels); err != nil {
+		return "", "", err
+	}
+	for _, sheet := range workbook.Sheets {
+		for _, rel := range workbookRels.Relationships {
+			if rel.ID != sheet.RID {
+				continue
+			}
+			sheetPart := zipPartPath("xl", rel.Target)
+			ref, found, err := findTable(entries, sheetPart, name)
+			if err != nil {
+				return "", "", err
+			}
+			if found {
+				return sheet.Name, ref, nil
+			}
+		}
+	}
+
+	return "", "", fmt.Errorf("no named range or table %q in the workbook", name)
+}
+
+// findTable looks for a table called name among the tables of a sheet part
+func findTable(entries map[string]*zip.File, sheetPart, name string) (string, bool, error) {
+	relsPart := path.Join(path.Dir(sheetPart), "_rels", path.Base(sheetPart)+".rels")
+	if entries[relsPart] == nil {
+		return "", false, nil
+	}
+
+	var sheetRels relationshipsXML
+	if err := readZipXML(entries, relsPart, &sheetRels); err != nil {
+		return "", false, err
+	}
+
+	for _, rel := range sheetRels.Relationships {
+		if !strings.HasSuffix(rel.Type, "/table") {
+			continue
+		}
+
+		var table tableXML
+		if err := readZipXML(entries, zipPartPath(path.Dir(sheetPart), rel.Target), &table); err != nil {
+			return "", false, err
+		}
+		if !strings.EqualFold(table.DisplayName, name) && !strings.EqualFold(table.Name, name) {
+			continue
+		}
+
+		bounds, err := parseCellRange(table.Ref)
+		if err != nil {
+			return "", false, fmt.Errorf("table %q: %w", name, err)
+		}
+		if table.TotalsRowCount > 0 && bounds.lastRow-table.TotalsRowCount >= bounds.firstRow {
+			bounds.lastRow -= table.TotalsRowCount
+		}
+		return formatCellRange(bounds), true, nil
+	}
+	return "", false, nil
+}
+
+// splitSheetReference splits the value of a defined name such as 'My Sheet'!$A$1:$C$9
+// into the sheet name and the range
+func splitSheetReference(name, value string) (string, string, error) {
+	value = strings.TrimSpace(value)
+	bang := strings.LastIndex(value, "!")
+	if bang < 0 || strings.Contains(value, ",") {
+		return "", "", fmt.Errorf("name %q refers to %s, which is not a single cell range", name, value)
+	}
+
+	sheet, cells := value[:bang], value[bang+1:]
+	if len(sheet) >= 2 && strings.HasPrefix(sheet, "'") && strings.HasSuffix(sheet, "'") {
+		sheet = strings.ReplaceAll(sheet[1:len(sheet)-1], "''", "'")
+	}
+	if _, err := parseCellRange(cells); err != nil || sheet == "" {
+		return "", "", fmt.Errorf("name %q refers to %s, which is not a single cell range", name, value)
+	}
+	return sheet, strings.ReplaceAll(cells, "$", ""), nil
+}
+
+// zipPartPath resolves a relationship target against the directory of the part that
+// references it, targets starting with / are relative to the package root
+func zipPartPath(dir, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(path.Clean(target), "/")
+	}
+	return path.Join(dir, target)
+}
+
+// readZipXML decodes an XML part of an archive
+func readZipXML(entries map[string]*zip.File, name string, v any) error {
+	file := entries[name]
+	if file == nil {
+		return fmt.Errorf("failed to read workbook: %s is missing", name)
+	}
+
+	data, err := readZipEntry(file, maxWorkbookPart)
+	if err != nil {
+		return fmt.Errorf("failed to read workbook: %w", err)
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
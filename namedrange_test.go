@@ -0,0 +1,116 @@
+package excel2csv
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveNamedRange(t *testing.T) {
+	workbook := filepath.Join(t.TempDir(), "book.xlsx")
+	writeWorkbook(t, workbook,
+		testSheet{name: "Summary", names: []testName{
+			{name: "Prices", value: "'Prices EU'!$B$2:$D$9"},
+			{name: "Prices", value: "Summary!$A$1:$A$2", local: true},
+			{name: "Region", value: "Summary!$A$1:$B$3", local: true},
+			{name: "Quoted", value: "'It''s'!$A$1:$B$2"},
+			{name: "Areas", value: "Summary!$A$1,Summary!$B$2"},
+			{name: "Constant", value: "42"},
+			{name: "Local", value: "Summary!$A$1", local: true},
+		}},
+		testSheet{name: "Prices EU", names: []testName{
+			{name: "Local", value: "'Prices EU'!$A$1", local: true},
+		}, tables: []testTable{
+			{name: "Sales", ref: "A1:C10", totalsRows: 1},
+			{name: "HeaderOnly", ref: "A12:C12", totalsRows: 1},
+		}},
+		testSheet{name: "It's", tables: []testTable{
+			{name: "Stock", ref: "$B$2:$E$5"},
+		}})
+
+	tests := []struct {
+		name      string
+		wantSheet string
+		wantCells string
+		wantErr   string
+	}{
+		// The workbook scoped name wins over the one local to Summary
+		{"Prices", "Prices EU", "B2:D9", ""},
+		{"prices", "Prices EU", "B2:D9", ""},
+		{"Region", "Summary", "A1:B3", ""},
+		{"Quoted", "It's", "A1:B2", ""},
+		{"Local", "", "", `name "Local" is defined on 2 sheets`},
+		{"Areas", "", "", `name "Areas" refers to Summary!$A$1,Summary!$B$2, which is not a single cell range`},
+		{"Constant", "", "", `name "Constant" refers to 42, which is not a single cell range`},
+		// Tables are found on any sheet, without their totals row
+		{"sales", "Prices EU", "A1:C9", ""},
+		{"HeaderOnly", "Prices EU", "A12:C12", ""},
+		{"Stock", "It's", "B2:E5", ""},
+		{"Missing", "", "", `no named range or table "Missing" in the workbook`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet, cells, err := resolveNamedRange(workbook, tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveNamedRange() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sheet != tt.wantSheet || cells != tt.wantCells {
+				t.Errorf("resolveNamedRange() = %q, %q, want %q, %q", sheet, cells, tt.wantSheet, tt.wantCells)
+			}
+		})
+	}
+}
+
+func TestWithNamedRange(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	dir := t.TempDir()
+	workbook := filepath.Join(dir, "book.xlsx")
+	writeWorkbook(t, workbook, testSheet{name: "Summary"}, testSheet{name: "Data", tables: []testTable{
+		{name: "Sales", ref: "B2:D6", totalsRows: 1},
+	}})
+
+	converter := NewExcelConverter()
+	converter.NamedRange = "Sales"
+	converter.SheetIndex = intPtr(0)
+	got, err := converter.withNamedRange(workbook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SheetName != "Data" || got.SheetIndex != nil || got.CellRange != "B2:D5" || got.NamedRange != "" {
+		t.Errorf("withNamedRange() selects sheet %q (index %v), cells %q, name %q",
+			got.SheetName, got.SheetIndex, got.CellRange, got.NamedRange)
+	}
+	if converter.SheetName != "" || converter.CellRange != "" {
+		t.Errorf("withNamedRange() changed the converter")
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		configure func(ec *ExcelConverter)
+		wantErr   string
+	}{
+		{"cell range", workbook, func(ec *ExcelConverter) { ec.CellRange = "A1:B2" }, "NamedRange can't be combined with CellRange"},
+		{"all sheets", workbook, func(ec *ExcelConverter) { ec.AllSheetsMode = true }, "NamedRange selects a single sheet"},
+		{"xls", filepath.Join(dir, "book.xls"), func(ec *ExcelConverter) {}, "named ranges and tables are only read from .xlsx and .xlsm files, not .xls"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			converter.NamedRange = "Sales"
+			tt.configure(converter)
+
+			if _, err := converter.withNamedRange(tt.path); err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("withNamedRange() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
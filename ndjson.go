@@ -0,0 +1,204 @@
+package excel2csv
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// ndjsonBatchRows is the number of streamed rows transformed together
+const ndjsonBatchRows = 1000
+
+// StreamNDJSON converts the selected sheet and writes one JSON object per data row
+// to w, keyed by the header cells in column order. Values are the cell strings.
+//
+// With DetectionStrategy StrategyNone or a forced row range (ForceDataStartRow and
+// ForceDataEndRow), rows are written as they are read from the exported sheet, so
+// neither the sheet nor the output is held in memory. Cells past the header row's
+// width are dropped then, and a forced range ends at the sheet's last row.
+//
+// Table detection, CellRange, NamedRange, Transpose, FillMergedDown, SyntheticHeaders,
+// TrimTrailingEmptyColumns, DiagnosticsPath and DetectMultipleTables look at all rows,
+// so with any of them the whole sheet is read into memory first; MaxRows bounds it
+// for untrusted input.
+func (ec *ExcelConverter) StreamNDJSON(inputPath string, w io.Writer) error {
+	return ec.StreamNDJSONContext(context.Background(), inputPath, w)
+}
+
+// StreamNDJSONContext is like StreamNDJSON, but stops converting and kills
+// LibreOffice when ctx is done
+func (ec *ExcelConverter) StreamNDJSONContext(ctx context.Context, inputPath string, w io.Writer) error {
+	converter, err := ec.withNamedRange(inputPath)
+	if err != nil {
+		return err
+	}
+	if first, last, ok := converter.streamedRows(); ok {
+		return converter.streamNDJSONRows(ctx, inputPath, w, first, last)
+	}
+
+	records, err := converter.convertRecords(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	keys, err := ndjsonKeys(records[0])
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(w)
+	data := records[1:]
+	for row, record := range data {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := writeNDJSONRow(writer, keys, record); err != nil {
+			return fmt.Errorf("row %d: %w", row+1, err)
+		}
+		converter.rowProgress(row+1, len(data))
+	}
+
+	return writer.Flush()
+}
+
+// streamedRows returns the first and last row (0-based) of the table when it is known
+// without reading the sheet and every transformation works on a row at a time, so
+// StreamNDJSON can write rows as they are read
+func (ec *ExcelConverter) streamedRows() (int, int, bool) {
+	if ec.CellRange != "" || ec.Transpose || ec.FillMergedDown || ec.SyntheticHeaders ||
+		ec.TrimTrailingEmptyColumns || ec.DiagnosticsPath != "" || ec.DetectMultipleTables {
+		return 0, 0, false
+	}
+
+	// Forced rows win over the strategy, as in tableBoundaries
+	if ec.ForceDataStartRow != nil && ec.ForceDataEndRow != nil &&
+		*ec.ForceDataStartRow >= 0 && *ec.ForceDataEndRow >= *ec.ForceDataStartRow {
+		return *ec.ForceDataStartRow, *ec.ForceDataEndRow, true
+	}
+	if ec.DetectionStrategy == StrategyNone {
+		return 0, math.MaxInt, true
+	}
+	return 0, 0, false
+}
+
+// streamNDJSONRows writes the rows first to last of the selected sheet as they are read.
+// The first row is the header; data rows are transformed in batches under a copy of it.
+func (ec *ExcelConverter) streamNDJSONRows(ctx context.Context, inputPath string, w io.Writer, first, last int) error {
+	writer := bufio.NewWriter(w)
+	var header []string
+	var keys [][]byte
+	var batch [][]string
+	written := 0
+
+	writeBatch := func() error {
+		table, err := ec.transformTable(append([][]string{slices.Clone(header)}, batch...))
+		if err != nil {
+			return err
+		}
+		batch = batch[:0]
+
+		if keys == nil {
+			if keys, err = ndjsonKeys(table[0]); err != nil {
+				return err
+			}
+		}
+		for _, record := range table[1:] {
+			written++
+			if err := writeNDJSONRow(writer, keys, record); err != nil {
+				return fmt.Errorf("row %d: %w", written, err)
+			}
+			if written%progressRowInterval == 0 {
+				ec.progress(written, 0, "rows")
+			}
+		}
+		return nil
+	}
+
+	row := -1
+	err := ec.eachSheetRecord(ctx, inputPath, func(record []string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row++
+		switch {
+		case row < first:
+			return nil
+		case row == first:
+			header = record
+			return nil
+		}
+
+		batch = append(batch, record)
+		if len(batch) == ndjsonBatchRows {
+			if err := writeBatch(); err != nil {
+				return err
+			}
+		}
+		if row == last {
+			return errStopRecords
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// A header without data rows writes nothing, like an empty table
+	if len(batch) > 0 {
+		if err := writeBatch(); err != nil {
+			return err
+		}
+	}
+	if written > 0 && written%progressRowInterval != 0 {
+		ec.progress(written, written, "rows")
+	}
+
+	return writer.Flush()
+}
+
+// ndjsonKeys encodes the JSON keys of a header row once, every row reuses them
+func ndjsonKeys(header []string) ([][]byte, error) {
+	names := fieldNames(header)
+	keys := make([][]byte, len(names))
+	for i, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// writeNDJSONRow writes record as a JSON object with the fields in column order.
+// Missing cells are written as empty strings.
+func writeNDJSONRow(w *bufio.Writer, keys [][]byte, record []string) error {
+	_ = w.WriteByte('{')
+	for col, key := range keys {
+		if col > 0 {
+			_ = w.WriteByte(',')
+		}
+		_, _ = w.Write(key)
+		_ = w.WriteByte(':')
+
+		cell := ""
+		if col < len(record) {
+			cell = record[col]
+		}
+		value, err := json.Marshal(cell)
+		if err != nil {
+			return err
+		}
+		_, _ = w.Write(value)
+	}
+	_ = w.WriteByte('}')
+	return w.WriteByte('\n')
+}
@@ -0,0 +1,108 @@
+package excel2csv
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamNDJSON(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	none := func(ec *ExcelConverter) { ec.DetectionStrategy = StrategyNone }
+
+	tests := []struct {
+		name      string
+		input     string
+		configure func(ec *ExcelConverter)
+		want      string
+	}{
+		// Keys keep the column order, short rows get empty strings
+		{"streamed key order and missing cells", "Zeta,Alpha,Mid\n1,2,3\n4\n", none,
+			`{"Zeta":"1","Alpha":"2","Mid":"3"}` + "\n" + `{"Zeta":"4","Alpha":"","Mid":""}` + "\n"},
+		{"streamed header only", "Zeta,Alpha,Mid\n", none, ""},
+		{"streamed empty", "", none, ""},
+		{"streamed duplicate and blank headers", "Name,Name,\na,b,c\n", none,
+			`{"Name":"a","Name_2":"b","column_3":"c"}` + "\n"},
+		{"streamed transformations", "Name,Qty\n a ,1\nb,2\n", func(ec *ExcelConverter) {
+			ec.DetectionStrategy = StrategyNone
+			ec.CollapseSpaces = true
+			ec.RowFilters = []RowFilter{{Column: "Qty", Operator: FilterGt, Value: "0"}}
+			ec.HeaderRename = map[string]string{"Qty": "quantity"}
+		}, `{"Name":"a","quantity":"1"}` + "\n" + `{"Name":"b","quantity":"2"}` + "\n"},
+		// Reading stops at the last forced row, the broken quote after it is never parsed
+		{"streamed forced rows", "Report\nName,Qty\na,1\nb,2\nTotal,\"3\n", func(ec *ExcelConverter) {
+			ec.ForceDataStartRow, ec.ForceDataEndRow = intPtr(1), intPtr(3)
+		}, `{"Name":"a","Qty":"1"}` + "\n" + `{"Name":"b","Qty":"2"}` + "\n"},
+		{"streamed forced header only", "Report\nName,Qty\n", func(ec *ExcelConverter) {
+			ec.ForceDataStartRow, ec.ForceDataEndRow = intPtr(1), intPtr(5)
+		}, ""},
+		// Detection needs the whole sheet, its rows are padded to the widest one
+		{"detected", "Report,,,,\nName,City,Qty,Price,Note\na,Köln,1,2.5,x\nb,Wien,2,3.5\n", func(ec *ExcelConverter) {},
+			`{"Name":"a","City":"Köln","Qty":"1","Price":"2.5","Note":"x"}` + "\n" +
+				`{"Name":"b","City":"Wien","Qty":"2","Price":"3.5","Note":""}` + "\n"},
+		{"detected header only", "Zeta,Alpha,Mid\n", func(ec *ExcelConverter) {}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := filepath.Join(t.TempDir(), "input.csv")
+			if err := os.WriteFile(inputPath, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			converter := NewExcelConverter()
+			tt.configure(converter)
+
+			var out bytes.Buffer
+			if err := converter.StreamNDJSON(inputPath, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("StreamNDJSON() wrote\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamedRows(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+
+	tests := []struct {
+		name        string
+		configure   func(ec *ExcelConverter)
+		first, last int
+		want        bool
+	}{
+		{"detection", func(ec *ExcelConverter) {}, 0, 0, false},
+		{"no detection", func(ec *ExcelConverter) { ec.DetectionStrategy = StrategyNone }, 0, math.MaxInt, true},
+		{"forced rows", func(ec *ExcelConverter) {
+			ec.ForceDataStartRow, ec.ForceDataEndRow = intPtr(2), intPtr(9)
+		}, 2, 9, true},
+		{"reversed forced rows", func(ec *ExcelConverter) {
+			ec.ForceDataStartRow, ec.ForceDataEndRow = intPtr(9), intPtr(2)
+		}, 0, 0, false},
+		{"forced start only", func(ec *ExcelConverter) { ec.ForceDataStartRow = intPtr(2) }, 0, 0, false},
+		{"cell range", func(ec *ExcelConverter) {
+			ec.DetectionStrategy, ec.CellRange = StrategyNone, "A1:B2"
+		}, 0, 0, false},
+		{"transpose", func(ec *ExcelConverter) {
+			ec.DetectionStrategy, ec.Transpose = StrategyNone, true
+		}, 0, 0, false},
+		{"fill merged down", func(ec *ExcelConverter) {
+			ec.DetectionStrategy, ec.FillMergedDown = StrategyNone, true
+		}, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			tt.configure(converter)
+
+			first, last, ok := converter.streamedRows()
+			if first != tt.first || last != tt.last || ok != tt.want {
+				t.Errorf("streamedRows() = %d, %d, %t, want %d, %d, %t", first, last, ok, tt.first, tt.last, tt.want)
+			}
+		})
+	}
+}
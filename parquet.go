@@ -0,0 +1,152 @@
+package excel2csv
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetColumnType is the inferred type of a Parquet column
+type parquetColumnType int
+
+const (
+	parquetString parquetColumnType = iota
+	parquetInt
+	parquetFloat
+)
+
+// writeParquet writes records as a Parquet file using the first record as field names.
+// Empty cells are written as nulls, text cells keep their spaces like in CSV output.
+func (ec *ExcelConverter) writeParquet(w io.Writer, records [][]string) error {
+	if len(records) == 0 {
+		return fmt.Errorf("no data to write to Parquet")
+	}
+
+	names := fieldNames(records[0])
+	data := records[1:]
+
+	types := make([]parquetColumnType, len(names))
+	group := parquet.Group{}
+	for col, name := range names {
+		types[col] = ec.inferParquetColumnType(data, col)
+
+		switch types[col] {
+		case parquetInt:
+			group[name] = parquet.Optional(parquet.Int(64))
+		case parquetFloat:
+			group[name] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		default:
+			group[name] = parquet.Optional(parquet.String())
+		}
+	}
+
+	schema := parquet.NewSchema("excel2csv", group)
+
+	// Group fields are ordered by name, so look up the leaf index of every header
+	columnIndexes := make(map[string]int, len(names))
+	for i, path := range schema.Columns() {
+		columnIndexes[path[0]] = i
+	}
+
+	rows := make([]parquet.Row, 0, len(data))
+	for _, record := range data {
+		row := make(parquet.Row, len(names))
+		for col, name := range names {
+			columnIndex := columnIndexes[name]
+
+			cell := ""
+			if col < len(record) {
+				cell = record[col]
+			}
+
+			// Strings keep their spaces like in CSV output, blank numbers are null
+			if cell == "" || (types[col] != parquetString && strings.TrimSpace(cell) == "") {
+				row[columnIndex] = parquet.Value{}.Level(0, 0, columnIndex)
+			} else {
+				row[columnIndex] = ec.parquetValue(cell, types[col]).Level(0, 1, columnIndex)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	writer := parquet.NewWriter(w, schema)
+	if _, err := writer.WriteRows(rows); err != nil {
+		return fmt.Errorf("failed to write Parquet rows: %w", err)
+	}
+	ec.progress(len(records), len(records), "rows")
+
+	return writer.Close()
+}
+
+// inferParquetColumnType scans all rows of a column and picks int, float or string.
+// Mixed or non-numeric content falls back to string.
+func (ec *ExcelConverter) inferParquetColumnType(records [][]string, col int) parquetColumnType {
+	columnType := parquetInt
+	hasValues := false
+
+	for _, record := range records {
+		if col >= len(record) {
+			continue
+		}
+
+		cell := strings.TrimSpace(record[col])
+		if cell == "" {
+			continue
+		}
+		hasValues = true
+
+		if !ec.looksLikeNumber(cell) {
+			return parquetString
+		}
+
+		if _, err := strconv.ParseInt(ec.normalizeNumber(cell), 10, 64); err != nil {
+			columnType = parquetFloat
+		}
+	}
+
+	if !hasValues {
+		return parquetString
+	}
+
+	return columnType
+}
+
+// parquetValue converts a non-empty cell to a value of the inferred column type
+func (ec *ExcelConverter) parquetValue(cell string, columnType parquetColumnType) parquet.Value {
+	switch columnType {
+	case parquetInt:
+		v, _ := strconv.ParseInt(ec.normalizeNumber(strings.TrimSpace(cell)), 10, 64)
+		return parquet.Int64Value(v)
+	case parquetFloat:
+		v, _ := strconv.ParseFloat(ec.normalizeNumber(strings.TrimSpace(cell)), 64)
+		return parquet.DoubleValue(v)
+	default:
+		return parquet.ByteArrayValue([]byte(cell))
+	}
+}
+
+// fieldNames makes unique, non-empty field names from the header row,
+// used for Parquet fields and NDJSON keys
+func fieldNames(header []string) []string {
+	names := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+
+	for i, cell := range header {
+		name := strings.TrimSpace(cell)
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+
+		base := name
+		for n := 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+
+	return names
+}
@@ -0,0 +1,57 @@
+package excel2csv
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestWriteParquetKeepsCellValues(t *testing.T) {
+	records := [][]string{
+		{"Name", "Qty"},
+		{"  padded ", " 5 "},
+		{"   ", ""},
+		{"", "\t7"},
+	}
+
+	var buf bytes.Buffer
+	if err := NewExcelConverter().writeParquet(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+
+	type row struct {
+		Name *string `parquet:"Name,optional"`
+		Qty  *int64  `parquet:"Qty,optional"`
+	}
+	var got []row
+	for {
+		var r row
+		if err := reader.Read(&r); err != nil {
+			break
+		}
+		got = append(got, r)
+	}
+
+	str := func(s string) *string { return &s }
+	num := func(n int64) *int64 { return &n }
+	want := []row{
+		{str("  padded "), num(5)},
+		{str("   "), nil},
+		{nil, num(7)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("writeParquet() rows differ")
+		for i := range got {
+			t.Logf("row %d: %v", i, got[i])
+		}
+	}
+}
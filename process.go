@@ -0,0 +1,27 @@
+package excel2csv
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+// runProcessTree runs cmd and returns its standard output, with standard error
+// included if combined is set, like cmd.Output and cmd.CombinedOutput. When the
+// command's context is done, cmd and all of its child processes are killed, so
+// soffice.bin doesn't outlive the soffice launcher.
+func runProcessTree(cmd *exec.Cmd, combined bool) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if combined {
+		cmd.Stderr = &output
+	}
+
+	release, err := startProcessTree(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	err = cmd.Wait()
+	return output.Bytes(), err
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package excel2csv
+
+import "os/exec"
+
+// startProcessTree starts cmd, keeping the default behavior of killing only cmd itself
+func startProcessTree(cmd *exec.Cmd) (func(), error) {
+	return func() {}, cmd.Start()
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package excel2csv
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// startProcessTree starts cmd in its own process group and kills the whole group
+// when the command's context is done. Killing only soffice leaves soffice.bin
+// running, which then keeps the output pipe open.
+func startProcessTree(cmd *exec.Cmd) (func(), error) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	return func() {}, cmd.Start()
+}
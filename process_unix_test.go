@@ -0,0 +1,72 @@
+//go:build unix
+
+package excel2csv
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestCancelKillsProcessGroup cancels a conversion while a fake soffice waits for its
+// soffice.bin child and checks that no process of the group is left
+func TestCancelKillsProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	pidPath := filepath.Join(dir, "soffice.pid")
+	script := filepath.Join(dir, "soffice")
+	content := "#!/bin/sh\necho $$ > " + pidPath + "\nsleep 60 &\nwait\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LIBREOFFICE_PATH", script)
+
+	inputPath := filepath.Join(dir, "book.xlsx")
+	if err := os.WriteFile(inputPath, []byte("PK\x03\x04"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pgid := make(chan int, 1)
+	go func() {
+		for ctx.Err() == nil {
+			if data, err := os.ReadFile(pidPath); err == nil && strings.HasSuffix(string(data), "\n") {
+				pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+				pgid <- pid
+				time.Sleep(100 * time.Millisecond) // let the child start
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	converter := NewExcelConverter()
+	converter.TempDir = dir
+	_, err := converter.convertViaLibreOffice(ctx, inputPath)
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, ErrLibreOfficeLoadFailed) {
+		t.Fatalf("convertViaLibreOffice() error = %v, want a cancelled conversion", err)
+	}
+
+	var group int
+	select {
+	case group = <-pgid:
+	default:
+		t.Fatal("fake soffice never started")
+	}
+
+	// Killed processes may take a moment to be reaped
+	deadline := time.Now().Add(5 * time.Second)
+	for syscall.Kill(-group, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("processes of group %d are still running after cancel", group)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,37 @@
+package excel2csv
+
+import (
+	"fmt"
+	"io"
+)
+
+// ConvertRecords runs table detection, the configured transformations and the output
+// writer on rows already in memory, as if they were the exported rows of a sheet, and
+// writes the result to w. It needs neither LibreOffice nor a file, so it suits rows from
+// other sources and checking detection and cleaning against known input.
+// ForceDataStartRow and ForceDataEndRow index records, sheet selection options are ignored.
+func (ec *ExcelConverter) ConvertRecords(records [][]string, w io.Writer) (ConvertStats, error) {
+	stats := ConvertStats{DetectedHeaderRow: -1}
+	if ec.MaxRows > 0 && len(records) > ec.MaxRows {
+		return stats, fmt.Errorf("%w: sheet has more than %d rows", ErrRowLimitExceeded, ec.MaxRows)
+	}
+
+	// Conversions modify rows in place, leave the caller's rows alone
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		rows[i] = append([]string(nil), record...)
+	}
+
+	rows, err := ec.applyCellRange(padRows(rows))
+	if err != nil {
+		return stats, err
+	}
+
+	table, headerRow, err := ec.detectedTable(rows)
+	stats.DetectedHeaderRow = headerRow
+	if err != nil {
+		return stats, err
+	}
+
+	return ec.writeTable(&countingWriter{w: w}, "", table, stats)
+}
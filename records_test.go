@@ -0,0 +1,168 @@
+package excel2csv
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// readFixture reads a CSV fixture from testdata as the rows of an exported sheet
+func readFixture(t *testing.T, name string) [][]string {
+	t.Helper()
+	records, err := readCSVFile(filepath.Join("testdata", name), ',', 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return padRows(records)
+}
+
+// checkGolden compares got with the golden file testdata/name, or rewrites it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestConvertRecordsGolden(t *testing.T) {
+	tests := []struct {
+		name      string
+		fixture   string
+		configure func(ec *ExcelConverter)
+		golden    string
+	}{
+		{"title and footer", "report.csv", func(ec *ExcelConverter) {}, "report.golden.csv"},
+		{"tsv", "report.csv", func(ec *ExcelConverter) {
+			ec.OutputFormat = FormatTSV
+			ec.CleanLineBreaks = false
+		}, "report.golden.tsv"},
+		{"no detection", "report.csv", func(ec *ExcelConverter) {
+			ec.DetectionStrategy = StrategyNone
+		}, "report.none.golden.csv"},
+		// Four columns are too few for StrategyImproved to take a row as the header
+		{"narrow table improved", "ledger.csv", func(ec *ExcelConverter) {}, "ledger.golden.csv"},
+		{"narrow table structural", "ledger.csv", func(ec *ExcelConverter) {
+			ec.DetectionStrategy = StrategyStructural
+		}, "ledger.structural.golden.csv"},
+		{"structural", "report.csv", func(ec *ExcelConverter) {
+			ec.DetectionStrategy = StrategyStructural
+		}, "report.golden.csv"},
+		{"non-ASCII", "cities.csv", func(ec *ExcelConverter) {}, "cities.golden.csv"},
+		// An export in a legacy charset is read as Latin-1 and written as UTF-8
+		{"latin-1 export", "cities_latin1.csv", func(ec *ExcelConverter) {}, "cities_latin1.golden.csv"},
+		// Field names down the first column become the header row
+		{"transpose", "staff.csv", func(ec *ExcelConverter) { ec.Transpose = true }, "staff.golden.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			tt.configure(converter)
+
+			var out bytes.Buffer
+			if _, err := converter.ConvertRecords(readFixture(t, tt.fixture), &out); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, out.Bytes())
+		})
+	}
+}
+
+func TestConvertRecords(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	table := [][]string{
+		{"Report", "", "", "", ""},
+		{"Name", "City", "Qty", "Price", "Note"},
+		{"a", "Köln", "1", "2.5", "x"},
+		{"b", "Wien", "2", "3.5"}, // short rows are padded like exported rows
+	}
+
+	tests := []struct {
+		name      string
+		configure func(ec *ExcelConverter)
+		want      string
+		wantRows  int
+		wantStart int
+		wantErr   error
+	}{
+		{"detected", func(ec *ExcelConverter) {},
+			"Name,City,Qty,Price,Note\na,Köln,1,2.5,x\nb,Wien,2,3.5,\n", 3, 1, nil},
+		{"forced rows index records", func(ec *ExcelConverter) {
+			ec.ForceDataStartRow, ec.ForceDataEndRow = intPtr(1), intPtr(2)
+		}, "Name,City,Qty,Price,Note\na,Köln,1,2.5,x\n", 2, 1, nil},
+		{"no header", func(ec *ExcelConverter) { ec.OmitHeader = true },
+			"a,Köln,1,2.5,x\nb,Wien,2,3.5,\n", 2, 1, nil},
+		{"row limit", func(ec *ExcelConverter) { ec.MaxRows = 3 }, "", 0, -1, ErrRowLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			tt.configure(converter)
+
+			var out bytes.Buffer
+			stats, err := converter.ConvertRecords(table, &out)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConvertRecords() error = %v, want %v", err, tt.wantErr)
+			}
+			if out.String() != tt.want {
+				t.Errorf("ConvertRecords() wrote %q, want %q", out.String(), tt.want)
+			}
+			if stats.RowsWritten != tt.wantRows || stats.DetectedHeaderRow != tt.wantStart {
+				t.Errorf("ConvertRecords() stats = %d rows from row %d, want %d rows from row %d",
+					stats.RowsWritten, stats.DetectedHeaderRow, tt.wantRows, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestConvertRecordsKeepsInput(t *testing.T) {
+	records := [][]string{
+		{"Name", "City", "Qty", "Price", "Note"},
+		{" a ", "Line\nbreak", "1", "2", "x"},
+		{"b"},
+	}
+	want := [][]string{
+		{"Name", "City", "Qty", "Price", "Note"},
+		{" a ", "Line\nbreak", "1", "2", "x"},
+		{"b"},
+	}
+
+	converter := NewExcelConverter()
+	converter.CollapseSpaces = true
+	if _, err := converter.ConvertRecords(records, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("ConvertRecords() changed its input to %q", records)
+	}
+}
+
+func TestConvertRecordsEmpty(t *testing.T) {
+	var out bytes.Buffer
+	stats, err := NewExcelConverter().ConvertRecords(nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 || stats.RowsWritten != 0 || stats.DetectedHeaderRow != -1 {
+		t.Errorf("ConvertRecords(nil) wrote %q, stats %+v", out.String(), stats)
+	}
+}
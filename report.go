@@ -0,0 +1,140 @@
+package excel2csv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SheetResult describes the file produced for a sheet in all sheets mode
+type SheetResult struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name"`
+	Table      int    `json:"table,omitempty"`       // table number (1-based) when a sheet is split into several tables
+	OutputPath string `json:"output_path,omitempty"` // empty if the sheet failed or was skipped
+	Empty      bool   `json:"empty,omitempty"`       // the sheet has no data rows, it is skipped unless IncludeEmptySheets is set
+	Rows       int    `json:"rows"`                  // rows written, including the header unless OmitHeader is set
+	Columns    int    `json:"columns"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ConvertAllSheetsWithReport converts all sheets to separate CSV files in outputDir
+// and reports what was written for each sheet. A failed sheet doesn't stop the
+// conversion, its error is recorded in the result instead.
+func (ec *ExcelConverter) ConvertAllSheetsWithReport(inputPath, outputDir string) ([]SheetResult, error) {
+	return ec.ConvertAllSheetsWithReportContext(context.Background(), inputPath, outputDir)
+}
+
+// ConvertAllSheetsWithReportContext is like ConvertAllSheetsWithReport, but stops
+// converting and kills LibreOffice when ctx is done
+func (ec *ExcelConverter) ConvertAllSheetsWithReportContext(ctx context.Context, inputPath, outputDir string) ([]SheetResult, error) {
+	if err := ec.checkInputFormat(inputPath); err != nil {
+		return nil, err
+	}
+	if err := ec.checkFilenameTemplate(); err != nil {
+		return nil, err
+	}
+
+	sheets, err := ec.sheetsToConvert(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create output directory if it doesn't exist
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	results := make([]SheetResult, 0, len(sheets))
+	names := make(uniqueFileNames)
+	for done, sheet := range sheets {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		fmt.Printf("Converting sheet %d (%s) to %s\n", sheet.Index+1, sheet.Name, outputDir)
+
+		// Create a temporary converter for this sheet
+		tempConverter := ec.sheetConverter(sheet)
+
+		tables, err := tempConverter.sheetTables(ctx, inputPath)
+		if err != nil {
+			fmt.Printf("Warning: failed to convert sheet %s: %v\n", sheet.Name, err)
+			results = append(results, SheetResult{Index: sheet.Index, Name: sheet.Name, Error: err.Error()})
+			ec.progress(done+1, len(sheets), "sheets")
+			continue
+		}
+
+		empty := !hasDataRows(tables)
+		if empty && !ec.IncludeEmptySheets {
+			fmt.Printf("Skipping empty sheet %s\n", sheet.Name)
+			results = append(results, SheetResult{Index: sheet.Index, Name: sheet.Name, Empty: true})
+			ec.progress(done+1, len(sheets), "sheets")
+			continue
+		}
+
+		for i, table := range tables {
+			result := SheetResult{Index: sheet.Index, Name: sheet.Name, Empty: empty}
+			fileName, err := tempConverter.sheetFileName(inputPath, sheet)
+			if err != nil {
+				return results, err
+			}
+			if len(tables) > 1 {
+				result.Table = i + 1
+				fileName = tableFileName(fileName, i)
+			}
+			outputFile := filepath.Join(outputDir, names.claim(fileName))
+
+			if err := tempConverter.writeSheetFile(inputPath, outputFile, table, &result); err != nil {
+				fmt.Printf("Warning: failed to write %s: %v\n", outputFile, err)
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+
+		ec.progress(done+1, len(sheets), "sheets")
+	}
+
+	return results, nil
+}
+
+// writeSheetFile writes the records of a sheet or table of inputPath to outputPath
+// and fills in the written size
+func (ec *ExcelConverter) writeSheetFile(inputPath, outputPath string, records [][]string, result *SheetResult) error {
+	dstFile, err := ec.CreateOutput(outputPath)
+	if err != nil {
+		return err
+	}
+
+	err = ec.writeMetadataComment(dstFile, inputPath, result.Name, records)
+	if err == nil {
+		err = ec.writeRecords(dstFile, records)
+	}
+	if err != nil {
+		_ = dstFile.Close()
+		_ = os.Remove(outputPath)
+		return err
+	}
+
+	if err := dstFile.Close(); err != nil {
+		return err
+	}
+
+	if ec.EmitDDL != "" {
+		if err := ec.writeDDL(ddlPath(outputPath), ec.convertedSheetName(), ec.inferColumns(records, true)); err != nil {
+			return fmt.Errorf("failed to write DDL: %w", err)
+		}
+	}
+
+	result.OutputPath = outputPath
+	result.Rows = len(ec.outputRows(records))
+	for _, record := range records {
+		if len(record) > result.Columns {
+			result.Columns = len(record)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package s3 adds Amazon S3 sources and destinations to excel2csv conversions.
+// It lives in its own package so the AWS SDK is only pulled in when used.
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/oxyii/excel2csv"
+)
+
+const urlPrefix = "s3://"
+
+// IsURL reports whether the path is an s3://bucket/key URL
+func IsURL(p string) bool {
+	return strings.HasPrefix(p, urlPrefix)
+}
+
+// ParseURL splits an s3://bucket/key URL into bucket and key
+func ParseURL(rawURL string) (string, string, error) {
+	if !IsURL(rawURL) {
+		return "", "", fmt.Errorf("not an S3 URL: %s", rawURL)
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(rawURL, urlPrefix), "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("S3 URL must be in the form s3://bucket/key: %s", rawURL)
+	}
+
+	return bucket, key, nil
+}
+
+// NewClient creates an S3 client from the default AWS configuration chain
+func NewClient(ctx context.Context) (*awss3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return awss3.NewFromConfig(cfg), nil
+}
+
+// Download saves an S3 object to a temp file in tempDir, keeping the key's extension.
+// The caller is responsible for removing the returned file.
+func Download(ctx context.Context, client *awss3.Client, bucket, key, tempDir string) (string, error) {
+	obj, err := client.GetObject(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to download s3://%s/%s: %w", bucket, key, err)
+	}
+	defer func() { _ = obj.Body.Close() }()
+
+	// Keep the extension, ConvertFile picks the format from it
+	dstFile, err := os.CreateTemp(tempDir, "excel2csv_s3_*"+path.Ext(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	if _, err := io.Copy(dstFile, obj.Body); err != nil {
+		_ = dstFile.Close()
+		_ = os.Remove(dstFile.Name())
+		return "", fmt.Errorf("failed to download s3://%s/%s: %w", bucket, key, err)
+	}
+
+	if err := dstFile.Close(); err != nil {
+		_ = os.Remove(dstFile.Name())
+		return "", err
+	}
+
+	return dstFile.Name(), nil
+}
+
+// Upload streams a local file to an S3 object
+func Upload(ctx context.Context, client *awss3.Client, srcPath, bucket, key string) error {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = srcFile.Close() }()
+
+	// The file body is read in place rather than buffered in memory
+	_, err = client.PutObject(ctx, &awss3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        srcFile,
+		ContentType: aws.String("text/csv"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", bucket, key, err)
+	}
+
+	return nil
+}
+
+// ConvertS3 downloads an Excel object, converts it with ec and uploads the resulting CSV
+func ConvertS3(ctx context.Context, client *awss3.Client, ec *excel2csv.ExcelConverter, srcBucket, srcKey, dstBucket, dstKey string) error {
+	if ec.AllSheetsMode || ec.SheetPattern != "" {
+		return fmt.Errorf("all sheets mode is not supported for S3 destinations")
+	}
+
+	inputPath, err := Download(ctx, client, srcBucket, srcKey, ec.TempDir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(inputPath) }()
+
+	outputFile, err := os.CreateTemp(ec.TempDir, "excel2csv_s3_*.csv")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	outputPath := outputFile.Name()
+	_ = outputFile.Close()
+	defer func() { _ = os.Remove(outputPath) }()
+
+	// The temp file was created above, convert over it
+	converter := *ec
+	converter.NoClobber = false
+	if err := converter.ConvertFile(inputPath, outputPath); err != nil {
+		return err
+	}
+
+	return Upload(ctx, client, outputPath, dstBucket, dstKey)
+}
@@ -0,0 +1,128 @@
+package excel2csv
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ColumnType is the value type inferred for a column
+type ColumnType string
+
+const (
+	ColumnInt    ColumnType = "int"
+	ColumnFloat  ColumnType = "float"
+	ColumnDate   ColumnType = "date"
+	ColumnBool   ColumnType = "bool"
+	ColumnString ColumnType = "string"
+)
+
+// schemaSampleCount is the number of distinct sample values kept per column
+const schemaSampleCount = 5
+
+// ColumnSchema describes a column of the converted table
+type ColumnSchema struct {
+	Name     string     `json:"name"`     // header, made unique and non-empty
+	Type     ColumnType `json:"type"`     // type every non-empty cell has, string if they differ
+	Nullable bool       `json:"nullable"` // some cells are empty
+	Samples  []string   `json:"samples"`  // first distinct non-empty values
+}
+
+// InferSchema converts the selected sheet without writing it and infers the type
+// of every column from the data rows of the detected table
+func (ec *ExcelConverter) InferSchema(inputPath string) ([]ColumnSchema, error) {
+	records, err := ec.convertRecords(context.Background(), inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return ec.inferColumns(records, false), nil
+}
+
+// inferColumns infers the schema of a converted table, header first. With
+// plainNumbers, columns are numeric only if a database loading the written text
+// reads every cell as the number it shows, as CREATE TABLE statements need.
+func (ec *ExcelConverter) inferColumns(records [][]string, plainNumbers bool) []ColumnSchema {
+	if len(records) == 0 {
+		return nil
+	}
+
+	names := fieldNames(records[0])
+	columns := make([]ColumnSchema, len(names))
+	for col, name := range names {
+		columns[col] = ec.inferColumn(name, records[1:], col, plainNumbers)
+	}
+
+	return columns
+}
+
+// inferColumn picks the most specific type that fits every non-empty cell of a column.
+// Dates are checked before numbers, since 15.01.2024 is also a number in NumberLocaleEU.
+// Cells holding the EmptyCellValue placeholder count as empty.
+func (ec *ExcelConverter) inferColumn(name string, records [][]string, col int, plainNumbers bool) ColumnSchema {
+	column := ColumnSchema{Name: name, Samples: []string{}}
+	isInt, isFloat, isDate, isBool := true, true, true, true
+	hasValues := false
+
+	for _, record := range records {
+		cell := ""
+		if col < len(record) {
+			cell = strings.TrimSpace(record[col])
+		}
+		if cell == "" || (ec.EmptyCellValue != "" && cell == strings.TrimSpace(ec.EmptyCellValue)) {
+			column.Nullable = true
+			continue
+		}
+		hasValues = true
+
+		if len(column.Samples) < schemaSampleCount && !slices.Contains(column.Samples, cell) {
+			column.Samples = append(column.Samples, cell)
+		}
+
+		if isDate {
+			_, isDate = ec.parseDate(cell)
+		}
+		if isFloat && (!ec.looksLikeNumber(cell) || plainNumbers && !ec.isPlainNumber(cell)) {
+			isInt, isFloat = false, false
+		}
+		if isInt {
+			_, err := strconv.ParseInt(ec.normalizeNumber(cell), 10, 64)
+			isInt = err == nil
+		}
+		if isBool {
+			isBool = strings.EqualFold(cell, "true") || strings.EqualFold(cell, "false")
+		}
+	}
+
+	switch {
+	case !hasValues:
+		column.Type = ColumnString
+	case isDate:
+		column.Type = ColumnDate
+	case isInt:
+		column.Type = ColumnInt
+	case isFloat:
+		column.Type = ColumnFloat
+	case isBool:
+		column.Type = ColumnBool
+	default:
+		column.Type = ColumnString
+	}
+
+	return column
+}
+
+// plainNumberPattern matches numbers written without thousands separators
+var plainNumberPattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// isPlainNumber reports whether a database reads cell as the number it shows.
+// NumberLocaleEU writes decimals with a comma and may group thousands with a point,
+// so only integers are plain there.
+func (ec *ExcelConverter) isPlainNumber(cell string) bool {
+	if ec.NumberLocale == NumberLocaleEU && strings.Contains(cell, ".") {
+		return false
+	}
+	return plainNumberPattern.MatchString(cell)
+}
@@ -0,0 +1,52 @@
+package excel2csv
+
+import "testing"
+
+func TestInferColumns(t *testing.T) {
+	tests := []struct {
+		name         string
+		locale       NumberLocale
+		emptyValue   string
+		plainNumbers bool
+		cells        []string
+		wantType     ColumnType
+		wantNullable bool
+	}{
+		{"ints", NumberLocaleEN, "", true, []string{"1", "-2", "30"}, ColumnInt, false},
+		{"floats", NumberLocaleEN, "", true, []string{"1.5", "2", ".25", "1e3"}, ColumnFloat, false},
+		{"empty cell", NumberLocaleEN, "", true, []string{"1", ""}, ColumnInt, true},
+		{"placeholder is null", NumberLocaleEN, `\N`, true, []string{"1", `\N`}, ColumnInt, true},
+		{"only placeholders", NumberLocaleEN, `\N`, true, []string{`\N`, `\N`}, ColumnString, true},
+		{"grouped schema", NumberLocaleEN, "", false, []string{"1,234", "5"}, ColumnInt, false},
+		{"grouped ddl", NumberLocaleEN, "", true, []string{"1,234", "5"}, ColumnString, false},
+		{"eu decimal schema", NumberLocaleEU, "", false, []string{"1.234,56"}, ColumnFloat, false},
+		{"eu decimal ddl", NumberLocaleEU, "", true, []string{"1.234,56"}, ColumnString, false},
+		{"eu grouped ddl", NumberLocaleEU, "", true, []string{"1.234"}, ColumnString, false},
+		{"eu ints ddl", NumberLocaleEU, "", true, []string{"1234", "-5"}, ColumnInt, false},
+		{"bools", NumberLocaleEN, "", true, []string{"true", "FALSE"}, ColumnBool, false},
+		{"dates", NumberLocaleEN, "", true, []string{"2024-01-15"}, ColumnDate, false},
+		{"mixed", NumberLocaleEN, "", true, []string{"1", "abc"}, ColumnString, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			converter.NumberLocale = tt.locale
+			converter.EmptyCellValue = tt.emptyValue
+
+			records := [][]string{{"Value"}}
+			for _, cell := range tt.cells {
+				records = append(records, []string{cell})
+			}
+
+			columns := converter.inferColumns(records, tt.plainNumbers)
+			if len(columns) != 1 {
+				t.Fatalf("inferColumns() returned %d columns, want 1", len(columns))
+			}
+			if columns[0].Type != tt.wantType || columns[0].Nullable != tt.wantNullable {
+				t.Errorf("inferColumns() = %s nullable %t, want %s nullable %t",
+					columns[0].Type, columns[0].Nullable, tt.wantType, tt.wantNullable)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package excel2csv
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// SheetOptions overrides the converter settings for one sheet in all sheets mode.
+// Zero values and nil pointers keep the converter's setting.
+type SheetOptions struct {
+	CSVSeparator rune          // e.g. ';' for a sheet meant for European spreadsheets
+	OutputFormat *OutputFormat // e.g. FormatTSV, the file extension follows it
+	NumberLocale *NumberLocale // how the sheet writes numbers, used for detection and types
+	DateFormat   string        // Go layout to rewrite date cells with
+}
+
+// clone returns a copy that shares no pointers with o
+func (o SheetOptions) clone() SheetOptions {
+	if o.OutputFormat != nil {
+		format := *o.OutputFormat
+		o.OutputFormat = &format
+	}
+	if o.NumberLocale != nil {
+		locale := *o.NumberLocale
+		o.NumberLocale = &locale
+	}
+	return o
+}
+
+// applySheetOverrides merges the SheetOverrides of a sheet, matched by exact name,
+// into the converter of that sheet
+func (ec *ExcelConverter) applySheetOverrides(sheetName string) {
+	options, ok := ec.SheetOverrides[sheetName]
+	if !ok {
+		return
+	}
+
+	if options.CSVSeparator != 0 {
+		ec.CSVSeparator = options.CSVSeparator
+	}
+	if options.OutputFormat != nil {
+		ec.OutputFormat = *options.OutputFormat
+	}
+	if options.NumberLocale != nil {
+		ec.NumberLocale = *options.NumberLocale
+	}
+	if options.DateFormat != "" {
+		ec.DateFormat = options.DateFormat
+	}
+}
+
+// checkSheetOverrides fails for SheetOverrides naming none of the listed sheets,
+// which would otherwise be ignored without notice
+func (ec *ExcelConverter) checkSheetOverrides(sheets []SheetInfo) error {
+	for _, name := range slices.Sorted(maps.Keys(ec.SheetOverrides)) {
+		if !slices.ContainsFunc(sheets, func(sheet SheetInfo) bool { return sheet.Name == name }) {
+			return fmt.Errorf("sheet options for %q match no sheet, the workbook lists %s", name, sheetNames(sheets))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/oxyii/excel2csv/internal/cfb"
+)
+
+// ErrSheetNotFound is returned when SheetName or SheetIndex selects no sheet of the workbook
+var ErrSheetNotFound = errors.New("sheet not found")
+
+// odsTableNamespace is the XML namespace of the tables in an ods content.xml
+const odsTableNamespace = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+
+// BIFF8 record types read from the globals substream of xls workbooks
+const (
+	biffEOF         = 0x000A
+	biffFilePass    = 0x002F
+	biffBoundSheet8 = 0x0085
+)
+
+// xlsb record types of the sheet list in xl/workbook.bin
+const (
+	xlsbBundleSheet     = 0x9C
+	xlsbEndBundleSheets = 0x90
+)
+
+// defaultSheets is the sheet list of CSV and TSV files and of workbooks whose sheets
+// can't be read: the sheet LibreOffice exports by default
+func defaultSheets() []SheetInfo {
+	return []SheetInfo{{Index: 0, Name: "Sheet1"}}
+}
+
+// ListSheets returns the sheets of a workbook in workbook order, read from the file
+// without LibreOffice. Hidden and chart sheets are listed too, LibreOffice numbers them
+// like the others. CSV and TSV files have a single sheet named Sheet1, and so do
+// workbooks whose sheet list can't be read, such as HTML saved as .xls, with a warning.
+func (ec *ExcelConverter) ListSheets(inputPath string) ([]SheetInfo, error) {
+	// CSV and TSV files hold a single sheet
+	if ec.isDelimitedText(inputPath) {
+		return defaultSheets(), nil
+	}
+
+	// Encrypted workbooks list their sheets inside the encrypted package
+	if protected, err := ec.checkEncryption(inputPath, ec.inputExt(inputPath)); err != nil {
+		return nil, err
+	} else if protected {
+		tempDir, err := ec.createTempDir()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = os.RemoveAll(tempDir) }()
+		if inputPath, err = ec.decryptInput(inputPath, tempDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return readSheetList(inputPath)
+}
+
+// readSheetList reads the sheet names of an unencrypted workbook, whatever its extension
+func readSheetList(inputPath string) ([]SheetInfo, error) {
+	format, err := SniffFormat(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("input file not accessible: %w", err)
+	}
+
+	var names []string
+	switch format {
+	case "xlsx", "xlsm", "xlsb", "ods":
+		names, err = zipSheetNames(inputPath, format)
+	case "xls":
+		names, err = xlsSheetNames(inputPath)
+	default:
+		err = errors.New("unrecognized content")
+	}
+	if err == nil && len(names) == 0 {
+		err = errors.New("no sheets listed")
+	}
+	if err != nil {
+		// LibreOffice may still read the file, as it repairs damaged workbooks
+		fmt.Printf("Warning: can't read the sheets of %s (%v), listing the default sheet only\n", filepath.Base(inputPath), err)
+		return defaultSheets(), nil
+	}
+
+	sheets := make([]SheetInfo, len(names))
+	for i, name := range names {
+		sheets[i] = SheetInfo{Index: i, Name: name}
+	}
+	return sheets, nil
+}
+
+// zipSheetNames reads the sheet names of the ZIP based formats
+func zipSheetNames(inputPath, format string) ([]string, error) {
+	reader, err := zip.OpenReader(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	entries := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		entries[file.Name] = file
+	}
+
+	switch format {
+	case "ods":
+		return odsSheetNames(entries["content.xml"])
+	case "xlsb":
+		if entries["xl/workbook.bin"] == nil {
+			return nil, errors.New("xl/workbook.bin is missing")
+		}
+		data, err := readZipEntry(entries["xl/workbook.bin"], maxWorkbookPart)
+		if err != nil {
+			return nil, err
+		}
+		return xlsbSheetNames(data)
+	default:
+		var workbook workbookXML
+		if err := readZipXML(entries, "xl/workbook.xml", &workbook); err != nil {
+			return nil, err
+		}
+		names := make([]string, len(workbook.Sheets))
+		for i, sheet := range workbook.Sheets {
+			names[i] = sheet.Name
+		}
+		return names, nil
+	}
+}
+
+// odsSheetNames reads the names of the tables in an ods content.xml. The part also
+// holds all cells, so it is decoded as a stream and the tables are skipped.
+func odsSheetNames(file *zip.File) ([]string, error) {
+	if file == nil {
+		return nil, errors.New("content.xml is missing")
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	var names []string
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse content.xml: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Space != odsTableNamespace || start.Name.Local != "table" {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Space == odsTableNamespace && attr.Name.Local == "name" {
+				names = append(names, attr.Value)
+			}
+		}
+		if err := decoder.Skip(); err != nil {
+			return nil, fmt.Errorf("failed to parse content.xml: %w", err)
+		}
+	}
+}
+
+// xlsbSheetNames reads the BrtBundleSh records of an xlsb workbook.bin. Record types
+// and sizes are stored 7 bits per byte, the high bit set on all but the last byte.
+func xlsbSheetNames(data []byte) ([]string, error) {
+	reader := bytes.NewReader(data)
+	var names []string
+	for reader.Len() > 0 {
+		recordType, err := xlsbVarUint(reader, 2)
+		if err != nil {
+			return nil, err
+		}
+		size, err := xlsbVarUint(reader, 4)
+		if err != nil {
+			return nil, err
+		}
+		if int64(size) > int64(reader.Len()) {
+			return nil, errors.New("truncated workbook.bin record")
+		}
+		record := make([]byte, size)
+		_, _ = reader.Read(record)
+
+		switch recordType {
+		case xlsbBundleSheet:
+			// State and tab ID, then the relationship ID, which may be null, and the name
+			if len(record) < 8 {
+				return nil, errors.New("truncated sheet record")
+			}
+			rest := record[8:]
+			if _, rest, err = xlsbWideString(rest, true); err != nil {
+				return nil, err
+			}
+			name, _, err := xlsbWideString(rest, false)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		case xlsbEndBundleSheets:
+			return names, nil
+		}
+	}
+	return names, nil
+}
+
+// xlsbVarUint reads a record type or size of at most maxBytes bytes
+func xlsbVarUint(reader io.ByteReader, maxBytes int) (uint32, error) {
+	var value uint32
+	for i := range maxBytes {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, errors.New("truncated workbook.bin record")
+		}
+		value |= uint32(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return value, nil
+}
+
+// xlsbWideString reads a character count and as many UTF-16 code units, returning the
+// rest of data. A nullable string has the count 0xFFFFFFFF when it is null.
+func xlsbWideString(data []byte, nullable bool) (string, []byte, error) {
+	if len(data) < 4 {
+		return "", nil, errors.New("truncated string")
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	if nullable && count == 0xFFFFFFFF {
+		return "", data, nil
+	}
+	if uint64(count)*2 > uint64(len(data)) {
+		return "", nil, errors.New("truncated string")
+	}
+	units := make([]uint16, count)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units)), data[count*2:], nil
+}
+
+// xlsSheetNames reads the BoundSheet8 records of a BIFF8 xls workbook. The names are
+// encrypted in files with a FilePass record and can't be read then.
+func xlsSheetNames(inputPath string) ([]string, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	cf, err := cfb.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := cf.OpenStream("Workbook")
+	if err != nil {
+		return nil, fmt.Errorf("no BIFF8 workbook stream: %w", err)
+	}
+
+	var names []string
+	err = walkWorkbookGlobals(stream, func(recordType uint16, data []byte) (bool, error) {
+		switch recordType {
+		case biffFilePass:
+			return false, errors.New("the sheet names are encrypted")
+		case biffBoundSheet8:
+			// Stream position, visibility and type, then a short Unicode string
+			if len(data) < 8 {
+				return false, errors.New("truncated BoundSheet8 record")
+			}
+			if data[5] == 6 { // Visual Basic module, not a sheet
+				return true, nil
+			}
+			name, err := biffShortString(data[6:])
+			if err != nil {
+				return false, err
+			}
+			names = append(names, name)
+		}
+		return true, nil
+	})
+	return names, err
+}
+
+// biffShortString decodes a ShortXLUnicodeString: a character count, a flag for
+// UTF-16 characters, then the characters, one byte each if the flag isn't set
+func biffShortString(data []byte) (string, error) {
+	count, wide, chars := int(data[0]), data[1]&1 == 1, data[2:]
+	if wide && len(chars) < count*2 || !wide && len(chars) < count {
+		return "", errors.New("truncated string")
+	}
+
+	units := make([]uint16, count)
+	for i := range units {
+		if wide {
+			units[i] = binary.LittleEndian.Uint16(chars[i*2:])
+		} else {
+			units[i] = uint16(chars[i])
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// walkWorkbookGlobals calls handle with the records of the globals substream that starts
+// an xls workbook stream, until handle returns false or the substream ends. Records are
+// a type, a length and the data, record headers aren't encrypted.
+func walkWorkbookGlobals(stream io.Reader, handle func(recordType uint16, data []byte) (bool, error)) error {
+	reader := bufio.NewReader(stream)
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return errors.New("truncated workbook globals")
+		}
+		recordType, length := binary.LittleEndian.Uint16(header[:]), binary.LittleEndian.Uint16(header[2:])
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return errors.New("truncated workbook globals")
+		}
+
+		if recordType == biffEOF {
+			return nil
+		}
+		if more, err := handle(recordType, data); err != nil || !more {
+			return err
+		}
+	}
+}
+
+// selectedSheetNumber returns the 1-based number of the sheet SheetIndex or SheetName
+// select, as the CSV export filter takes it, or 0 to export the default sheet: when
+// neither is set or the workbook has a single sheet. SheetIndex wins if both are set,
+// as in all sheets mode. Names are matched exactly, then case-insensitively like Excel.
+func (ec *ExcelConverter) selectedSheetNumber(workbookPath string) (int, error) {
+	if ec.SheetIndex == nil && ec.SheetName == "" {
+		return 0, nil
+	}
+
+	sheets, err := readSheetList(workbookPath)
+	if err != nil {
+		return 0, err
+	}
+
+	index := -1
+	if ec.SheetIndex != nil {
+		if *ec.SheetIndex < 0 || *ec.SheetIndex >= len(sheets) {
+			return 0, fmt.Errorf("%w: sheet index %d is out of range, the workbook has %d sheets", ErrSheetNotFound, *ec.SheetIndex, len(sheets))
+		}
+		index = *ec.SheetIndex
+	} else {
+		for _, sheet := range sheets {
+			if sheet.Name == ec.SheetName {
+				index = sheet.Index
+				break
+			}
+			if index < 0 && strings.EqualFold(sheet.Name, ec.SheetName) {
+				index = sheet.Index
+			}
+		}
+		if index < 0 {
+			return 0, fmt.Errorf("%w: no sheet named %q, the workbook lists %s", ErrSheetNotFound, ec.SheetName, sheetNames(sheets))
+		}
+	}
+
+	if len(sheets) == 1 {
+		return 0, nil
+	}
+	return index + 1, nil
+}
+
+// sheetNames joins the names of sheets for messages
+func sheetNames(sheets []SheetInfo) string {
+	names := make([]string, len(sheets))
+	for i, sheet := range sheets {
+		names[i] = strconv.Quote(sheet.Name)
+	}
+	return strings.Join(names, ", ")
+}
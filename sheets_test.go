@@ -0,0 +1,289 @@
+package excel2csv
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/oxyii/excel2csv/internal/cfb/cfbtest"
+	"github.com/oxyii/excel2csv/internal/officecrypto/officecryptotest"
+)
+
+// writeODS writes an ods package whose content.xml holds a table per name
+func writeODS(t *testing.T, path string, names ...string) {
+	t.Helper()
+	content := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+		`xmlns:table="` + odsTableNamespace + `" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">` +
+		`<office:body><office:spreadsheet>`
+	for _, name := range names {
+		// A nested table in a cell isn't a sheet
+		content += `<table:table table:name="` + xmlAttr(name) + `"><table:table-row><table:table-cell>` +
+			`<table:table table:name="nested"/><text:p>x</text:p></table:table-cell></table:table-row></table:table>`
+	}
+	content += `</office:spreadsheet></office:body></office:document-content>`
+	writeZip(t, path,
+		zipPart{"mimetype", "application/vnd.oasis.opendocument.spreadsheet"},
+		zipPart{"content.xml", content})
+}
+
+// writeXLSB writes an xlsb package whose workbook.bin lists a sheet per name
+func writeXLSB(t *testing.T, path string, names ...string) {
+	t.Helper()
+	varUint := func(buf []byte, value int) []byte {
+		for value >= 0x80 {
+			buf = append(buf, byte(value&0x7F|0x80))
+			value >>= 7
+		}
+		return append(buf, byte(value))
+	}
+	wideString := func(buf []byte, s string) []byte {
+		units := utf16.Encode([]rune(s))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(units)))
+		for _, unit := range units {
+			buf = binary.LittleEndian.AppendUint16(buf, unit)
+		}
+		return buf
+	}
+	record := func(buf []byte, recordType int, data []byte) []byte {
+		buf = varUint(buf, recordType)
+		buf = varUint(buf, len(data))
+		return append(buf, data...)
+	}
+
+	workbook := record(nil, 0x83, nil) // BrtBeginBook
+	for i, name := range names {
+		data := binary.LittleEndian.AppendUint32(nil, 0)           // visible
+		data = binary.LittleEndian.AppendUint32(data, uint32(i+1)) // tab ID
+		data = wideString(data, "rId"+string(rune('1'+i)))
+		data = wideString(data, name)
+		workbook = record(workbook, xlsbBundleSheet, data)
+	}
+	workbook = record(workbook, xlsbEndBundleSheets, nil)
+	workbook = record(workbook, xlsbBundleSheet, []byte{1}) // never read
+	writeZip(t, path, zipPart{"xl/workbook.bin", string(workbook)})
+}
+
+// xlsWithSheets builds an xls file whose globals list sheets by name. A name of the
+// form "vba:Module" is listed as a Visual Basic module.
+func xlsWithSheets(t *testing.T, names ...string) []byte {
+	t.Helper()
+	record := func(recordType uint16, data []byte) []byte {
+		header := binary.LittleEndian.AppendUint16(nil, recordType)
+		header = binary.LittleEndian.AppendUint16(header, uint16(len(data)))
+		return append(header, data...)
+	}
+
+	workbook := record(0x0809, make([]byte, 16)) // BOF
+	for _, name := range names {
+		data := make([]byte, 6)
+		if module, ok := strings.CutPrefix(name, "vba:"); ok {
+			data[5], name = 6, module
+		}
+		units := utf16.Encode([]rune(name))
+		wide := false
+		for _, unit := range units {
+			wide = wide || unit > 0xFF
+		}
+		if wide {
+			data = append(data, byte(len(units)), 1)
+			for _, unit := range units {
+				data = binary.LittleEndian.AppendUint16(data, unit)
+			}
+		} else {
+			data = append(data, byte(len(units)), 0)
+			for _, unit := range units {
+				data = append(data, byte(unit))
+			}
+		}
+		workbook = append(workbook, record(biffBoundSheet8, data)...)
+	}
+	workbook = append(workbook, record(biffEOF, nil)...)
+	workbook = append(workbook, record(biffBoundSheet8, []byte{0})...) // after the globals
+
+	return cfbtest.Build(t, []string{"Workbook"}, map[string][]byte{"Workbook": workbook})
+}
+
+func TestReadSheetList(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"Prices EU", "It's", "Übersicht", "数据"}
+
+	tests := []struct {
+		name  string
+		write func(path string)
+		want  []string
+	}{
+		{"xlsx", func(path string) {
+			sheets := make([]testSheet, len(names))
+			for i, name := range names {
+				sheets[i] = testSheet{name: name, records: [][]string{{name}}}
+			}
+			writeWorkbook(t, path, sheets...)
+		}, names},
+		{"ods", func(path string) { writeODS(t, path, names...) }, names},
+		{"xlsb", func(path string) { writeXLSB(t, path, names...) }, names},
+		{"xls", func(path string) {
+			data := xlsWithSheets(t, "Prices EU", "vba:Module1", "It's", "Übersicht", "数据")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, names},
+		// ListSheets refuses encrypted xls files before, as they can't be decrypted
+		{"xls with FilePass", func(path string) {
+			if err := os.WriteFile(path, xlsWithFilePass(t, officecryptotest.XORFilePass("secret")), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"Sheet1"}},
+		{"html saved as xls", func(path string) {
+			if err := os.WriteFile(path, []byte("<html><table><tr><td>1</td></tr></table></html>"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"Sheet1"}},
+		{"xlsx without sheets", func(path string) { writeWorkbook(t, path) }, []string{"Sheet1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			tt.write(path)
+
+			sheets, err := readSheetList(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(sheets))
+			for i, sheet := range sheets {
+				if sheet.Index != i {
+					t.Errorf("sheet %q has index %d, want %d", sheet.Name, sheet.Index, i)
+				}
+				got[i] = sheet.Name
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readSheetList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListSheetsDelimitedText(t *testing.T) {
+	sheets, err := NewExcelConverter().ListSheets(filepath.Join("testdata", "report.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sheets, defaultSheets()) {
+		t.Errorf("ListSheets() = %+v, want %+v", sheets, defaultSheets())
+	}
+}
+
+func TestSelectedSheetNumber(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	dir := t.TempDir()
+	workbook := filepath.Join(dir, "book.xlsx")
+	writeWorkbook(t, workbook,
+		testSheet{name: "Summary"}, testSheet{name: "Prices EU"}, testSheet{name: "prices eu"})
+	single := filepath.Join(dir, "single.xlsx")
+	writeWorkbook(t, single, testSheet{name: "Data"})
+
+	tests := []struct {
+		name      string
+		path      string
+		configure func(ec *ExcelConverter)
+		want      int
+		wantErr   error
+	}{
+		{"default sheet", workbook, func(ec *ExcelConverter) {}, 0, nil},
+		{"index", workbook, func(ec *ExcelConverter) { ec.SheetIndex = intPtr(1) }, 2, nil},
+		{"first index", workbook, func(ec *ExcelConverter) { ec.SheetIndex = intPtr(0) }, 1, nil},
+		{"index out of range", workbook, func(ec *ExcelConverter) { ec.SheetIndex = intPtr(3) }, 0, ErrSheetNotFound},
+		{"negative index", workbook, func(ec *ExcelConverter) { ec.SheetIndex = intPtr(-1) }, 0, ErrSheetNotFound},
+		{"exact name", workbook, func(ec *ExcelConverter) { ec.SheetName = "prices eu" }, 3, nil},
+		{"case-insensitive name", workbook, func(ec *ExcelConverter) { ec.SheetName = "SUMMARY" }, 1, nil},
+		{"unknown name", workbook, func(ec *ExcelConverter) { ec.SheetName = "Prices US" }, 0, ErrSheetNotFound},
+		{"index wins over name", workbook, func(ec *ExcelConverter) {
+			ec.SheetIndex, ec.SheetName = intPtr(0), "Prices EU"
+		}, 1, nil},
+		// The only sheet is the one LibreOffice exports anyway
+		{"single sheet", single, func(ec *ExcelConverter) { ec.SheetName = "data" }, 0, nil},
+		{"single sheet unknown name", single, func(ec *ExcelConverter) { ec.SheetName = "Summary" }, 0, ErrSheetNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			tt.configure(converter)
+
+			got, err := converter.selectedSheetNumber(tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("selectedSheetNumber() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("selectedSheetNumber() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"7.2", "7.2", 0},
+		{"7.2.0", "7.2", 0},
+		{"7.2.0.4", "7.2", 1},
+		{"7.10.1", "7.2", 1},
+		{"7.1.8.1", "7.2", -1},
+		{"24.2.1", "7.2", 1},
+		{"6.4", "7.2", -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSheetsToConvertOverrides(t *testing.T) {
+	workbook := filepath.Join(t.TempDir(), "book.xlsx")
+	writeWorkbook(t, workbook, testSheet{name: "Prices US"}, testSheet{name: "Prices EU"})
+
+	tests := []struct {
+		name      string
+		overrides map[string]SheetOptions
+		wantErr   string
+	}{
+		{"matching name", map[string]SheetOptions{"Prices EU": {CSVSeparator: ';'}}, ""},
+		// Overrides match exactly, unlike SheetName
+		{"other case", map[string]SheetOptions{"prices eu": {CSVSeparator: ';'}},
+			`sheet options for "prices eu" match no sheet, the workbook lists "Prices US", "Prices EU"`},
+		{"unknown name", map[string]SheetOptions{"Sheet1": {}},
+			`sheet options for "Sheet1" match no sheet, the workbook lists "Prices US", "Prices EU"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			converter.SheetOverrides = tt.overrides
+
+			sheets, err := converter.sheetsToConvert(workbook)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("sheetsToConvert() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sheets) != 2 {
+				t.Errorf("sheetsToConvert() = %+v, want both sheets", sheets)
+			}
+		})
+	}
+}
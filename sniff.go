@@ -0,0 +1,94 @@
+package excel2csv
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/oxyii/excel2csv/internal/cfb"
+)
+
+// zipSignature starts every ZIP archive, the container of xlsx, xlsm, xlsb and ods files
+var zipSignature = []byte("PK\x03\x04")
+
+// SniffFormat detects the spreadsheet format of a file from its content, for files
+// without a meaningful extension. It returns "xlsx", "xlsm", "xlsb", "ods" or "xls",
+// or "" if the content isn't a recognized spreadsheet. Encrypted xlsx files are compound
+// files like xls, they are reported as "xlsx" so they are decrypted with Password.
+// The result can be used as ForceFormat.
+func SniffFormat(inputPath string) (string, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	header := make([]byte, len(cfb.Signature))
+	_, err = io.ReadFull(file, header)
+	_ = file.Close()
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.Equal(header, cfb.Signature):
+		if encrypted, err := isEncryptedOOXML(inputPath); err != nil {
+			return "", err
+		} else if encrypted {
+			return "xlsx", nil
+		}
+		return "xls", nil
+	case bytes.HasPrefix(header, zipSignature):
+		return sniffZipFormat(inputPath)
+	default:
+		return "", nil
+	}
+}
+
+// sniffZipFormat tells the ZIP based formats apart by their entries
+func sniffZipFormat(inputPath string) (string, error) {
+	reader, err := zip.OpenReader(inputPath)
+	if err != nil {
+		// A damaged archive isn't a spreadsheet we can read
+		return "", nil
+	}
+	defer func() { _ = reader.Close() }()
+
+	entries := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		entries[file.Name] = file
+	}
+
+	switch {
+	case entries["xl/workbook.bin"] != nil:
+		return "xlsb", nil
+	case entries["xl/workbook.xml"] != nil && entries["xl/vbaProject.bin"] != nil:
+		return "xlsm", nil
+	case entries["xl/workbook.xml"] != nil:
+		return "xlsx", nil
+	case entries["mimetype"] != nil:
+		mimetype, err := readZipEntry(entries["mimetype"], 128)
+		if err != nil {
+			return "", err
+		}
+		if string(mimetype) == "application/vnd.oasis.opendocument.spreadsheet" {
+			return "ods", nil
+		}
+	}
+
+	return "", nil
+}
+
+// readZipEntry reads up to limit bytes of an archive entry
+func readZipEntry(file *zip.File, limit int64) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	return io.ReadAll(io.LimitReader(reader, limit))
+}
@@ -0,0 +1,78 @@
+package excel2csv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// partPath returns the path of part n (1-based) of a split output, out.csv becomes out.part001.csv
+func partPath(outputPath string, n int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s.part%03d%s", strings.TrimSuffix(outputPath, ext), n, ext)
+}
+
+// convertToParts converts the selected sheet to files of at most SplitRows data rows,
+// each starting with the header row. A table without data rows still gets one part.
+func (ec *ExcelConverter) convertToParts(ctx context.Context, inputPath, outputPath string) (ConvertStats, error) {
+	stats := ConvertStats{DetectedHeaderRow: -1}
+
+	records, headerRow, err := ec.convertedTable(ctx, inputPath)
+	stats.DetectedHeaderRow = headerRow
+	if err != nil {
+		return stats, err
+	}
+
+	header := records[:min(1, len(records))]
+	data := records[len(header):]
+	for start := 0; start == 0 || start < len(data); start += ec.SplitRows {
+		part := append(header[:len(header):len(header)], data[start:min(start+ec.SplitRows, len(data))]...)
+		path := partPath(outputPath, len(stats.OutputFiles)+1)
+
+		written, err := ec.writePartFile(path, inputPath, part)
+		if err != nil {
+			// Don't leave an incomplete set of parts behind
+			for _, written := range stats.OutputFiles {
+				_ = os.Remove(written)
+			}
+			return stats, err
+		}
+
+		stats.OutputFiles = append(stats.OutputFiles, path)
+		stats.RowsWritten += len(ec.outputRows(part))
+		stats.BytesWritten += written
+	}
+
+	stats.SheetsConverted = 1
+	if ec.EmitDDL != "" {
+		stats.columns = ec.inferColumns(records, true)
+	}
+	if len(records) <= 1 {
+		stats.EmptySheets = []string{ec.convertedSheetName()}
+	}
+	return stats, nil
+}
+
+// writePartFile writes the records of one part of a sheet of inputPath to path
+// and returns the bytes written
+func (ec *ExcelConverter) writePartFile(path, inputPath string, records [][]string) (int64, error) {
+	dstFile, err := ec.CreateOutput(path)
+	if err != nil {
+		return 0, err
+	}
+
+	counter := &countingWriter{w: dstFile}
+	err = ec.writeMetadataComment(counter, inputPath, ec.convertedSheetName(), records)
+	if err == nil {
+		err = ec.writeRecords(counter, records)
+	}
+	if err != nil {
+		_ = dstFile.Close()
+		_ = os.Remove(path)
+		return 0, err
+	}
+
+	return counter.n, dstFile.Close()
+}
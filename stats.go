@@ -0,0 +1,219 @@
+package excel2csv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ConvertStats describes what a conversion wrote
+type ConvertStats struct {
+	RowsWritten       int    // rows written, including the header unless OmitHeader is set
+	BytesWritten      int64  // size of the output, the ZIP archive in all sheets mode
+	SheetsConverted   int    // sheets converted without errors
+	DetectedHeaderRow int    // first table row (0-based) in the sheet, usually the header, -1 in all sheets mode or for an empty sheet
+	Source            string // archive entry or file the stats are for, set by ConvertArchive and ConvertDirectory
+
+	// EmptySheets names the sheets without data rows. In all sheets mode they are
+	// skipped unless IncludeEmptySheets is set, a single sheet is written anyway.
+	EmptySheets []string
+
+	// FailedSheets names the sheets that failed in all sheets mode, which are
+	// skipped with a warning while the other sheets are still written
+	FailedSheets []string
+
+	// OutputFiles lists the parts written when SplitRows splits the output, the tables
+	// written when DetectMultipleTables finds several, or the sheet files in all sheets mode
+	OutputFiles []string
+
+	columns []ColumnSchema // schema of the written table, kept for EmitDDL
+}
+
+// ConvertFileStats is like ConvertFile, but also reports what was written
+func (ec *ExcelConverter) ConvertFileStats(inputPath, outputPath string) (ConvertStats, error) {
+	return ec.ConvertFileStatsContext(context.Background(), inputPath, outputPath)
+}
+
+// ConvertFileStatsContext is like ConvertFileStats, but stops converting and kills
+// LibreOffice when ctx is done
+func (ec *ExcelConverter) ConvertFileStatsContext(ctx context.Context, inputPath, outputPath string) (ConvertStats, error) {
+	if err := checkDDLDialect(ec.EmitDDL); err != nil {
+		return ConvertStats{DetectedHeaderRow: -1}, err
+	}
+
+	// A named range or table picks the sheet and cells to convert
+	if ec.NamedRange != "" {
+		converter, err := ec.withNamedRange(inputPath)
+		if err != nil {
+			return ConvertStats{DetectedHeaderRow: -1}, err
+		}
+		return converter.ConvertFileStatsContext(ctx, inputPath, outputPath)
+	}
+
+	// Handle ConvertAllSheets mode, a sheet pattern converts the matching sheets the same way
+	if ec.AllSheetsMode || ec.SheetPattern != "" {
+		if err := ec.checkInputFormat(inputPath); err != nil {
+			return ConvertStats{DetectedHeaderRow: -1}, err
+		}
+
+		// A .zip output path gets all sheets as one archive
+		if strings.EqualFold(filepath.Ext(outputPath), ".zip") {
+			return ec.convertAllSheetsToZipFile(ctx, inputPath, outputPath)
+		}
+
+		outputDir := filepath.Dir(outputPath)
+		results, err := ec.ConvertAllSheetsWithReportContext(ctx, inputPath, outputDir)
+		return reportStats(results), err
+	}
+
+	// Pick Parquet output from the file extension
+	converter := ec
+	if ec.OutputFormat == FormatCSV && strings.EqualFold(filepath.Ext(outputPath), ".parquet") {
+		parquetConverter := *ec
+		parquetConverter.OutputFormat = FormatParquet
+		converter = &parquetConverter
+	}
+
+	// A CSV input would be truncated before it is read
+	if absInput, _ := filepath.Abs(inputPath); ec.isDelimitedText(inputPath) {
+		if absOutput, _ := filepath.Abs(outputPath); absOutput == absInput {
+			return ConvertStats{DetectedHeaderRow: -1}, fmt.Errorf("output %s would overwrite the input", outputPath)
+		}
+	}
+
+	// Several tables of a sheet go to their own files, a cell range selects a single one
+	if ec.DetectMultipleTables && ec.CellRange == "" {
+		if ec.SplitRows > 0 {
+			return ConvertStats{DetectedHeaderRow: -1}, fmt.Errorf("SplitRows can't be combined with DetectMultipleTables")
+		}
+		return converter.convertToTables(ctx, inputPath, outputPath)
+	}
+
+	if ec.SplitRows > 0 {
+		stats, err := converter.convertToParts(ctx, inputPath, outputPath)
+		if err != nil || ec.EmitDDL == "" {
+			return stats, err
+		}
+		return stats, ec.writeDDL(ddlPath(outputPath), ec.convertedSheetName(), stats.columns)
+	}
+
+	dstFile, err := converter.CreateOutput(outputPath)
+	if err != nil {
+		return ConvertStats{DetectedHeaderRow: -1}, err
+	}
+
+	stats, err := converter.convertTo(ctx, inputPath, dstFile)
+	if err != nil {
+		_ = dstFile.Close()
+		_ = os.Remove(outputPath)
+		return stats, err
+	}
+
+	if err := dstFile.Close(); err != nil {
+		return stats, err
+	}
+
+	if ec.EmitDDL != "" {
+		return stats, ec.writeDDL(ddlPath(outputPath), ec.convertedSheetName(), stats.columns)
+	}
+	return stats, nil
+}
+
+// reportStats sums up the files written in all sheets mode
+func reportStats(results []SheetResult) ConvertStats {
+	stats := ConvertStats{DetectedHeaderRow: -1}
+	converted := make(map[int]bool)
+	empty := make(map[int]bool)
+	for _, result := range results {
+		if result.Empty && !empty[result.Index] {
+			empty[result.Index] = true
+			stats.EmptySheets = append(stats.EmptySheets, result.Name)
+		}
+		if result.Error != "" && !slices.Contains(stats.FailedSheets, result.Name) {
+			stats.FailedSheets = append(stats.FailedSheets, result.Name)
+		}
+		if result.Error != "" || result.OutputPath == "" {
+			continue
+		}
+		converted[result.Index] = true
+		stats.OutputFiles = append(stats.OutputFiles, result.OutputPath)
+		stats.RowsWritten += result.Rows
+		if info, err := os.Stat(result.OutputPath); err == nil {
+			stats.BytesWritten += info.Size()
+		}
+	}
+	stats.SheetsConverted = len(converted)
+	return stats
+}
+
+// hasDataRows reports whether any table has rows below its header
+func hasDataRows(tables [][][]string) bool {
+	for _, table := range tables {
+		if len(table) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// countingWriter counts the bytes written to w and keeps the first write error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// csvRowCounter counts the CSV rows written to w by counting line feeds outside quoted fields,
+// so copied CSV can be counted without parsing it. Writing more than max rows fails
+// with ErrRowLimitExceeded unless max is 0.
+type csvRowCounter struct {
+	w       io.Writer
+	max     int
+	rows    int
+	quoted  bool
+	partial bool // bytes written after the last line feed
+}
+
+func (c *csvRowCounter) Write(p []byte) (int, error) {
+	for i, b := range p {
+		switch {
+		case b == '"':
+			c.quoted = !c.quoted
+		case b == '\n' && !c.quoted:
+			c.rows++
+			c.partial = false
+			continue
+		}
+		if c.max > 0 && c.rows >= c.max {
+			// Write the allowed rows only
+			n, err := c.w.Write(p[:i])
+			if err != nil {
+				return n, err
+			}
+			return n, fmt.Errorf("%w: sheet has more than %d rows", ErrRowLimitExceeded, c.max)
+		}
+		c.partial = true
+	}
+	return c.w.Write(p)
+}
+
+// count returns the rows written, including a last row without a line ending
+func (c *csvRowCounter) count() int {
+	if c.partial {
+		return c.rows + 1
+	}
+	return c.rows
+}
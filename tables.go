@@ -0,0 +1,197 @@
+package excel2csv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TableRegion is a table found in a sheet, with rows counted from the top of the sheet (0-based)
+type TableRegion struct {
+	Start     int // first row of the table, including the header
+	End       int // last row of the table
+	HeaderRow int // row used as the table header, currently always Start
+}
+
+// DetectTables exports a sheet (0-based index) and returns the tables found in it.
+// Tables are blocks of rows separated by blank rows, each detected on its own. A sheet
+// the workbook doesn't have fails with ErrSheetNotFound.
+func (ec *ExcelConverter) DetectTables(inputPath string, sheet int) ([]TableRegion, error) {
+	if err := ec.checkInputFormat(inputPath); err != nil {
+		return nil, err
+	}
+
+	tempConverter := *ec
+	tempConverter.SheetIndex = &sheet
+	tempConverter.AllSheetsMode = false
+
+	records, err := tempConverter.readSheet(context.Background(), inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return tempConverter.detectTableRegions(records), nil
+}
+
+// detectTableRegions splits records at blank rows and runs table detection on every block.
+// Blocks without at least a header and one data row are skipped.
+func (ec *ExcelConverter) detectTableRegions(records [][]string) []TableRegion {
+	// Forced rows describe the whole sheet, not a single block
+	detector := *ec
+	detector.ForceDataStartRow = nil
+	detector.ForceDataEndRow = nil
+
+	var regions []TableRegion
+	for blockStart := 0; blockStart < len(records); {
+		if !ec.hasData(records[blockStart]) {
+			blockStart++
+			continue
+		}
+
+		blockEnd := blockStart
+		for blockEnd+1 < len(records) && ec.hasData(records[blockEnd+1]) {
+			blockEnd++
+		}
+
+		block := records[blockStart : blockEnd+1]
+		start, end := detector.detectTableBoundaries(block)
+		if end > start && end < len(block) && ec.countNonEmptyCells(block[start]) >= 2 {
+			regions = append(regions, TableRegion{
+				Start:     blockStart + start,
+				End:       blockStart + end,
+				HeaderRow: blockStart + start,
+			})
+		}
+
+		blockStart = blockEnd + 1
+	}
+
+	return regions
+}
+
+// sheetTables converts the selected sheet and returns its processed tables.
+// Without DetectMultipleTables that is the single detected table.
+func (ec *ExcelConverter) sheetTables(ctx context.Context, inputPath string) ([][][]string, error) {
+	if !ec.DetectMultipleTables {
+		records, err := ec.convertRecords(ctx, inputPath)
+		if err != nil {
+			return nil, err
+		}
+		return [][][]string{records}, nil
+	}
+
+	if err := ec.checkInputFormat(inputPath); err != nil {
+		return nil, err
+	}
+
+	records, err := ec.readSheet(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := ec.detectTableRegions(records)
+	if len(regions) == 0 {
+		// Nothing table-like, fall back to regular detection
+		table, err := ec.processRecords(records)
+		if err != nil {
+			return nil, err
+		}
+		return [][][]string{table}, nil
+	}
+
+	fmt.Printf("Found %d tables in the sheet\n", len(regions))
+
+	tables := make([][][]string, 0, len(regions))
+	for i, region := range regions {
+		table, err := ec.transformTable(records[region.Start : region.End+1])
+		if err != nil {
+			return nil, fmt.Errorf("table %d: %w", i+1, err)
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+// warnMultipleTables warns conversions to a single output that the sheet holds several
+// tables, which only file outputs write separately
+func (ec *ExcelConverter) warnMultipleTables(records [][]string) {
+	if regions := ec.detectTableRegions(records); len(regions) > 1 {
+		fmt.Printf("Warning: sheet contains %d tables separated by blank rows, convert to a file to write one file per table\n", len(regions))
+	}
+}
+
+// convertToTables converts the selected sheet with DetectMultipleTables. A sheet holding
+// several tables gets one file per table next to outputPath, out.csv becomes out_table_1.csv,
+// out_table_2.csv, ...; a sheet with a single table is written to outputPath as usual.
+func (ec *ExcelConverter) convertToTables(ctx context.Context, inputPath, outputPath string) (ConvertStats, error) {
+	stats := ConvertStats{DetectedHeaderRow: -1}
+	if err := ec.checkInputFormat(inputPath); err != nil {
+		return stats, err
+	}
+
+	records, err := ec.readSheet(ctx, inputPath)
+	if err != nil {
+		return stats, err
+	}
+
+	regions := ec.detectTableRegions(records)
+	if len(regions) <= 1 {
+		table, headerRow, err := ec.detectedTable(records)
+		stats.DetectedHeaderRow = headerRow
+		if err != nil {
+			return stats, err
+		}
+		result := SheetResult{Name: ec.convertedSheetName()}
+		if err := ec.writeSheetFile(inputPath, outputPath, table, &result); err != nil {
+			return stats, err
+		}
+		if len(table) <= 1 {
+			stats.EmptySheets = []string{result.Name}
+		}
+		return ec.addTableStats(stats, result), nil
+	}
+
+	fmt.Printf("Found %d tables in the sheet\n", len(regions))
+
+	stats.DetectedHeaderRow = regions[0].HeaderRow
+	dir, name := filepath.Split(outputPath)
+	for i, region := range regions {
+		result := SheetResult{Name: ec.convertedSheetName(), Table: i + 1}
+		path := filepath.Join(dir, tableFileName(name, i))
+		table, err := ec.transformTable(records[region.Start : region.End+1])
+		if err == nil {
+			err = ec.writeSheetFile(inputPath, path, table, &result)
+		}
+		if err != nil {
+			// Don't leave an incomplete set of tables behind
+			for _, written := range stats.OutputFiles {
+				_ = os.Remove(written)
+			}
+			return stats, fmt.Errorf("table %d: %w", i+1, err)
+		}
+
+		stats = ec.addTableStats(stats, result)
+		stats.OutputFiles = append(stats.OutputFiles, path)
+	}
+	return stats, nil
+}
+
+// addTableStats adds a written table to stats
+func (ec *ExcelConverter) addTableStats(stats ConvertStats, result SheetResult) ConvertStats {
+	stats.SheetsConverted = 1
+	stats.RowsWritten += result.Rows
+	if info, err := os.Stat(result.OutputPath); err == nil {
+		stats.BytesWritten += info.Size()
+	}
+	return stats
+}
+
+// tableFileName adds a table number to the file name of a sheet, used when a sheet
+// holds several tables
+func tableFileName(sheetFileName string, table int) string {
+	ext := filepath.Ext(sheetFileName)
+	return fmt.Sprintf("%s_table_%d%s", strings.TrimSuffix(sheetFileName, ext), table+1, ext)
+}
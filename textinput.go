@@ -0,0 +1,123 @@
+package excel2csv
+
+import (
+	"bufio"
+	"context"
+	"os"
+)
+
+// isDelimitedText reports whether the input is a .csv or .tsv file,
+// which is read directly instead of through LibreOffice
+func (ec *ExcelConverter) isDelimitedText(inputPath string) bool {
+	switch ec.inputExt(inputPath) {
+	case ".csv", ".tsv":
+		return true
+	default:
+		return false
+	}
+}
+
+// readSheet returns all rows of the selected sheet: delimited text inputs are read
+// directly, spreadsheets are exported by LibreOffice
+func (ec *ExcelConverter) readSheet(ctx context.Context, inputPath string) ([][]string, error) {
+	if !ec.isDelimitedText(inputPath) {
+		return ec.convertViaLibreOffice(ctx, inputPath)
+	}
+
+	separator := '\t'
+	if ec.inputExt(inputPath) == ".csv" {
+		var err error
+		separator, err = detectSeparator(inputPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	records, err := readCSVFile(inputPath, separator, ec.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	return padRows(records), nil
+}
+
+// eachSheetRecord calls handle with each row of the selected sheet as it is read,
+// without holding the sheet in memory. Rows of delimited text inputs aren't padded.
+func (ec *ExcelConverter) eachSheetRecord(ctx context.Context, inputPath string, handle func(record []string) error) error {
+	if !ec.isDelimitedText(inputPath) {
+		return ec.exportViaLibreOffice(ctx, inputPath, func(csvPath string) error {
+			return eachCSVRecord(csvPath, ec.exportSeparator(), ec.MaxRows, handle)
+		})
+	}
+
+	separator := '\t'
+	if ec.inputExt(inputPath) == ".csv" {
+		var err error
+		separator, err = detectSeparator(inputPath)
+		if err != nil {
+			return err
+		}
+	}
+	return eachCSVRecord(inputPath, separator, ec.MaxRows, handle)
+}
+
+// padRows pads all rows to the widest one, as LibreOffice does in its exports,
+// since table detection compares row widths
+func padRows(records [][]string) [][]string {
+	width := 0
+	for _, record := range records {
+		if len(record) > width {
+			width = len(record)
+		}
+	}
+
+	for i, record := range records {
+		for len(record) < width {
+			record = append(record, "")
+		}
+		records[i] = record
+	}
+	return records
+}
+
+// separatorCandidates are the separators detectSeparator chooses from
+var separatorCandidates = []rune{',', ';', '\t', '|'}
+
+// separatorSampleLines is the number of lines detectSeparator looks at,
+// enough to get past title rows above the table
+const separatorSampleLines = 20
+
+// detectSeparator picks the candidate separator found most often outside quotes
+// in the first lines of a CSV file, comma if none is found
+func detectSeparator(inputPath string) (rune, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	counts := make(map[rune]int, len(separatorCandidates))
+	quoted := false
+	for lines := 0; lines < separatorSampleLines; {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			break
+		}
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '\n' && !quoted:
+			lines++
+		case !quoted:
+			counts[r]++
+		}
+	}
+
+	separator := ','
+	for _, candidate := range separatorCandidates {
+		if counts[candidate] > counts[separator] {
+			separator = candidate
+		}
+	}
+	return separator, nil
+}
@@ -0,0 +1,485 @@
+package excel2csv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterOperator is the comparison applied by a RowFilter
+type FilterOperator string
+
+const (
+	FilterEq       FilterOperator = "eq"       // equal
+	FilterNe       FilterOperator = "ne"       // not equal
+	FilterContains FilterOperator = "contains" // contains the value
+	FilterGt       FilterOperator = "gt"       // numerically greater than
+	FilterGe       FilterOperator = "ge"       // numerically greater than or equal
+	FilterLt       FilterOperator = "lt"       // numerically less than
+	FilterLe       FilterOperator = "le"       // numerically less than or equal
+)
+
+// RowFilter keeps only data rows whose column value matches a condition.
+// Text comparisons are case-insensitive; eq and ne compare numerically when both sides are numbers.
+type RowFilter struct {
+	Column      string // header name, matched like SelectColumns
+	ColumnIndex *int   // column index (0-based), overrides Column
+	Operator    FilterOperator
+	Value       string
+}
+
+// filterOperators maps expression operators to filter operators. Two character
+// operators come first, so they win over the one character operator at the same position.
+var filterOperators = []struct {
+	token    string
+	operator FilterOperator
+}{
+	{"!=", FilterNe},
+	{">=", FilterGe},
+	{"<=", FilterLe},
+	{"=", FilterEq},
+	{"~", FilterContains},
+	{">", FilterGt},
+	{"<", FilterLt},
+}
+
+// ParseRowFilter parses a filter expression such as "Status=Active".
+// Supported operators are = (eq), != (ne), ~ (contains), > (gt), >= (ge), < (lt) and <= (le).
+// Values starting with another operator character, as in "Amount=>5", are rejected.
+func ParseRowFilter(expr string) (RowFilter, error) {
+	opIndex := -1
+	var op string
+	var operator FilterOperator
+
+	// Split at the leftmost operator so values may contain operator characters
+	for _, candidate := range filterOperators {
+		i := strings.Index(expr, candidate.token)
+		if i >= 0 && (opIndex < 0 || i < opIndex) {
+			opIndex, op, operator = i, candidate.token, candidate.operator
+		}
+	}
+
+	if opIndex < 0 {
+		return RowFilter{}, fmt.Errorf("invalid filter %q: expected column, operator (=, !=, ~, >, >=, <, <=) and value", expr)
+	}
+
+	column := strings.TrimSpace(expr[:opIndex])
+	if column == "" {
+		return RowFilter{}, fmt.Errorf("invalid filter %q: missing column name", expr)
+	}
+
+	value := strings.TrimSpace(expr[opIndex+len(op):])
+	if value != "" && strings.ContainsRune("=!~<>", rune(value[0])) {
+		return RowFilter{}, fmt.Errorf("invalid filter %q: value %q starts with an operator character", expr, value)
+	}
+
+	return RowFilter{
+		Column:   column,
+		Operator: operator,
+		Value:    value,
+	}, nil
+}
+
+// validFilterOperator reports whether operator is one of the FilterOperator constants
+func validFilterOperator(operator FilterOperator) bool {
+	for _, candidate := range filterOperators {
+		if candidate.operator == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRows keeps the header row and the data rows matching all RowFilters
+func (ec *ExcelConverter) filterRows(records [][]string) ([][]string, error) {
+	if len(ec.RowFilters) == 0 || len(records) == 0 {
+		return records, nil
+	}
+
+	// Resolve filter columns against the header row
+	indexes := make([]int, len(ec.RowFilters))
+	for i, filter := range ec.RowFilters {
+		if !validFilterOperator(filter.Operator) {
+			return nil, fmt.Errorf("filter on '%s': unknown operator %q", filter.Column, filter.Operator)
+		}
+		if filter.ColumnIndex != nil {
+			indexes[i] = *filter.ColumnIndex
+			continue
+		}
+
+		indexes[i] = findHeaderColumn(records[0], filter.Column)
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("filter column '%s' not found in header row", filter.Column)
+		}
+	}
+
+	result := [][]string{records[0]}
+	for _, record := range records[1:] {
+		keep := true
+		for i, filter := range ec.RowFilters {
+			cell := ""
+			if indexes[i] >= 0 && indexes[i] < len(record) {
+				cell = record[indexes[i]]
+			}
+			if !ec.matchFilter(cell, filter) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, record)
+		}
+	}
+
+	fmt.Printf("Row filters kept %d of %d data rows\n", len(result)-1, len(records)-1)
+	return result, nil
+}
+
+// matchFilter checks a single cell value against a filter
+func (ec *ExcelConverter) matchFilter(cell string, filter RowFilter) bool {
+	cell = strings.TrimSpace(cell)
+	value := strings.TrimSpace(filter.Value)
+
+	cellNumber, cellIsNumber := ec.parseNumber(cell)
+	valueNumber, valueIsNumber := ec.parseNumber(value)
+	numeric := cellIsNumber && valueIsNumber
+
+	switch filter.Operator {
+	case FilterEq:
+		if numeric {
+			return cellNumber == valueNumber
+		}
+		return strings.EqualFold(cell, value)
+	case FilterNe:
+		if numeric {
+			return cellNumber != valueNumber
+		}
+		return !strings.EqualFold(cell, value)
+	case FilterContains:
+		return strings.Contains(strings.ToLower(cell), strings.ToLower(value))
+	case FilterGt:
+		return numeric && cellNumber > valueNumber
+	case FilterGe:
+		return numeric && cellNumber >= valueNumber
+	case FilterLt:
+		return numeric && cellNumber < valueNumber
+	default: // FilterLe, operators are validated by filterRows
+		return numeric && cellNumber <= valueNumber
+	}
+}
+
+// fillMergedDown fills empty data cells with the last non-empty value above them.
+// LibreOffice exports merged cells as a value in the top-left cell and blanks below it.
+func (ec *ExcelConverter) fillMergedDown(records [][]string) {
+	if !ec.FillMergedDown || len(records) < 2 {
+		return
+	}
+
+	columns := ec.FillColumns
+	if len(columns) == 0 {
+		for _, record := range records {
+			for len(columns) < len(record) {
+				columns = append(columns, len(columns))
+			}
+		}
+	}
+
+	// The header row is never used as a fill value
+	for _, col := range columns {
+		last := ""
+		for _, record := range records[1:] {
+			if col < 0 || col >= len(record) {
+				continue
+			}
+			if strings.TrimSpace(record[col]) == "" {
+				record[col] = last
+			} else {
+				last = record[col]
+			}
+		}
+	}
+}
+
+// selectColumns keeps only the columns whose header matches SelectColumns, in the requested order.
+// The first record is treated as the header row.
+func (ec *ExcelConverter) selectColumns(records [][]string) ([][]string, error) {
+	if len(ec.SelectColumns) == 0 || len(records) == 0 {
+		return records, nil
+	}
+
+	var indexes []int
+	for _, name := range ec.SelectColumns {
+		index := findHeaderColumn(records[0], name)
+		if index < 0 {
+			fmt.Printf("Warning: column '%s' not found in header row\n", name)
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("none of the selected columns were found in the header row")
+	}
+
+	result := make([][]string, len(records))
+	for i, record := range records {
+		selected := make([]string, len(indexes))
+		for j, index := range indexes {
+			if index < len(record) {
+				selected[j] = record[index]
+			}
+		}
+		result[i] = selected
+	}
+
+	return result, nil
+}
+
+// findHeaderColumn returns the index of the first header cell containing name (case-insensitive), or -1
+func findHeaderColumn(header []string, name string) int {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return -1
+	}
+
+	for i, cell := range header {
+		if strings.Contains(strings.ToLower(strings.TrimSpace(cell)), name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// transformCells runs the built-in cleaner, if CleanLineBreaks or CollapseSpaces is set,
+// the date formatter, if DateFormat is set, CellTransformers in order and finally
+// the formula sanitizer, if SanitizeFormulas is set, on every cell, including the header.
+// Data cells that were empty in the sheet are set to EmptyCellValue instead, if set,
+// except in Parquet output, which stores them as nulls.
+func (ec *ExcelConverter) transformCells(records [][]string) {
+	emptyValue := ec.EmptyCellValue
+	if ec.OutputFormat == FormatParquet {
+		emptyValue = ""
+	}
+
+	if !ec.CleanLineBreaks && !ec.CollapseSpaces && ec.DateFormat == "" && len(ec.CellTransformers) == 0 &&
+		!ec.SanitizeFormulas && emptyValue == "" {
+		return
+	}
+
+	for row, record := range records {
+		for i, cell := range record {
+			// Check the exported value, a cell holding only a line break isn't empty
+			if emptyValue != "" && row > 0 && strings.Trim(cell, " \t") == "" {
+				record[i] = emptyValue
+				continue
+			}
+
+			if ec.CleanLineBreaks || ec.CollapseSpaces {
+				cell = ec.cleanCellData(cell)
+			}
+			if ec.DateFormat != "" {
+				cell = ec.formatDate(cell)
+			}
+			for _, transform := range ec.CellTransformers {
+				cell = transform(cell)
+			}
+			if ec.SanitizeFormulas {
+				cell = ec.sanitizeFormula(cell)
+			}
+			record[i] = cell
+		}
+	}
+}
+
+// sanitizeFormula neutralizes CSV injection as recommended by OWASP: a cell starting
+// with a character that makes spreadsheets evaluate it as a formula gets a leading
+// single quote, which forces text. Numbers such as -5 or +1.5 are left alone.
+func (ec *ExcelConverter) sanitizeFormula(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@\t\r", rune(value[0])) || ec.looksLikeNumber(value) {
+		return value
+	}
+	return "'" + value
+}
+
+// addSyntheticHeader prepends a header of column letters (A, B, C, ...) when
+// SyntheticHeaders is set and the table starts with data instead of a header row
+func (ec *ExcelConverter) addSyntheticHeader(records [][]string) [][]string {
+	if !ec.SyntheticHeaders || len(records) == 0 || !ec.startsWithData(records) {
+		return records
+	}
+
+	width := 0
+	for _, record := range records {
+		width = max(width, len(record))
+	}
+	header := make([]string, width)
+	for col := range header {
+		header[col] = columnLetters(col)
+	}
+
+	fmt.Printf("No header row detected, using column letters as headers\n")
+	return append([][]string{header}, records...)
+}
+
+// startsWithData reports whether the first row of a table holds data rather than headers:
+// it has numbers and doesn't look like the header of the next row. A row picked by
+// CellRange, ForceDataStartRow or ExpectedHeaders is always the header.
+func (ec *ExcelConverter) startsWithData(records [][]string) bool {
+	first := records[0]
+	if ec.CellRange != "" || ec.ForceDataStartRow != nil || ec.countExpectedHeaders(first) > 0 {
+		return false
+	}
+	if ec.countNumericCells(first) == 0 {
+		return false
+	}
+	return len(records) == 1 || !ec.looksLikeHeaderRow(first, records[1])
+}
+
+// dedupeHeader handles duplicate and blank header cells as HeaderDedupeMode says.
+// It runs before renameHeaders, so generated names such as column_3 can be renamed.
+func (ec *ExcelConverter) dedupeHeader(records [][]string) ([][]string, error) {
+	if ec.HeaderDedupeMode == HeaderDedupeKeep || len(records) == 0 {
+		return records, nil
+	}
+
+	header := records[0]
+	switch ec.HeaderDedupeMode {
+	case HeaderDedupeError:
+		seen := make(map[string]int, len(header))
+		for col, cell := range header {
+			name := strings.TrimSpace(cell)
+			if name == "" {
+				return nil, fmt.Errorf("header cell of column %d is blank", col+1)
+			}
+			if first, ok := seen[name]; ok {
+				return nil, fmt.Errorf("header %q is repeated in columns %d and %d", name, first+1, col+1)
+			}
+			seen[name] = col
+		}
+		return records, nil
+
+	case HeaderDedupeDropBlank:
+		var keep []int
+		for col, cell := range header {
+			if strings.TrimSpace(cell) != "" {
+				keep = append(keep, col)
+			}
+		}
+		if len(keep) < len(header) {
+			for i, record := range records {
+				kept := make([]string, len(keep))
+				for j, col := range keep {
+					if col < len(record) {
+						kept[j] = record[col]
+					}
+				}
+				records[i] = kept
+			}
+		}
+	}
+
+	records[0] = fieldNames(records[0])
+	return records, nil
+}
+
+// renameHeaders rewrites header cells found in HeaderRename.
+// It runs last, so renamed headers are written exactly as given.
+func (ec *ExcelConverter) renameHeaders(records [][]string) {
+	if len(ec.HeaderRename) == 0 || len(records) == 0 {
+		return
+	}
+
+	renames := make(map[string]string, len(ec.HeaderRename))
+	for from, to := range ec.HeaderRename {
+		renames[strings.ToLower(strings.TrimSpace(from))] = to
+	}
+
+	header := records[0]
+	for i, cell := range header {
+		if to, ok := renames[strings.ToLower(strings.TrimSpace(cell))]; ok {
+			header[i] = to
+		}
+	}
+}
+
+// trimTrailingEmptyColumns truncates every row to the rightmost column with data in any row,
+// removing the padding LibreOffice adds up to the widest used cell of the sheet
+func (ec *ExcelConverter) trimTrailingEmptyColumns(records [][]string) [][]string {
+	if !ec.TrimTrailingEmptyColumns {
+		return records
+	}
+
+	width := 0
+	for _, record := range records {
+		for col := len(record) - 1; col >= width; col-- {
+			if strings.TrimSpace(record[col]) != "" {
+				width = col + 1
+				break
+			}
+		}
+	}
+
+	for i, record := range records {
+		if len(record) > width {
+			records[i] = record[:width]
+		}
+	}
+
+	return records
+}
+
+// transpose swaps rows and columns, so a table with field names down the first column
+// gets them as its header row. Short rows are padded with empty cells.
+func transpose(records [][]string) [][]string {
+	width := 0
+	for _, record := range records {
+		if len(record) > width {
+			width = len(record)
+		}
+	}
+
+	transposed := make([][]string, width)
+	for col := range transposed {
+		transposed[col] = make([]string, len(records))
+		for row, record := range records {
+			if col < len(record) {
+				transposed[col][row] = record[col]
+			}
+		}
+	}
+
+	return transposed
+}
+
+// addSheetNameColumn prepends the SheetNameColumn column, tagging every data row with
+// the name of the converted sheet so outputs of several sheets can be concatenated
+func (ec *ExcelConverter) addSheetNameColumn(records [][]string) [][]string {
+	if ec.SheetNameColumn == "" || len(records) == 0 {
+		return records
+	}
+
+	name := ec.convertedSheetName()
+	for i, record := range records {
+		value := name
+		if i == 0 {
+			value = ec.SheetNameColumn
+		}
+		records[i] = append([]string{value}, record...)
+	}
+
+	return records
+}
+
+// convertedSheetName returns the name of the sheet being converted. Without SheetName
+// it is named by position, the way ListSheets names sheets.
+func (ec *ExcelConverter) convertedSheetName() string {
+	if ec.SheetName != "" {
+		return ec.SheetName
+	}
+
+	index := 0
+	if ec.SheetIndex != nil {
+		index = *ec.SheetIndex
+	}
+	return fmt.Sprintf("Sheet%d", index+1)
+}
@@ -0,0 +1,166 @@
+package excel2csv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenameHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames map[string]string
+		records [][]string
+		want    [][]string
+	}{
+		{"exact", map[string]string{"Qty": "quantity"},
+			[][]string{{"Name", "Qty"}, {"a", "1"}}, [][]string{{"Name", "quantity"}, {"a", "1"}}},
+		{"case-insensitive", map[string]string{"QTY": "quantity"},
+			[][]string{{"Name", "qty"}}, [][]string{{"Name", "quantity"}}},
+		{"trimmed cell", map[string]string{"Unit Price": "price"},
+			[][]string{{" Unit Price "}}, [][]string{{"price"}}},
+		{"trimmed key", map[string]string{" name ": "customer"},
+			[][]string{{"Name"}}, [][]string{{"customer"}}},
+		{"new name kept as given", map[string]string{"id": "  ID  "},
+			[][]string{{"id"}}, [][]string{{"  ID  "}}},
+		{"partial match", map[string]string{"Name": "customer"},
+			[][]string{{"Name 2", "Full Name"}}, [][]string{{"Name 2", "Full Name"}}},
+		{"data rows kept", map[string]string{"Name": "customer"},
+			[][]string{{"Name"}, {"Name"}}, [][]string{{"customer"}, {"Name"}}},
+		{"no rows", map[string]string{"Name": "customer"}, [][]string{}, [][]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			converter.HeaderRename = tt.renames
+			converter.renameHeaders(tt.records)
+			if !reflect.DeepEqual(tt.records, tt.want) {
+				t.Errorf("renameHeaders() = %q, want %q", tt.records, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]string
+		want    [][]string
+	}{
+		{"square", [][]string{{"Name", "a"}, {"Qty", "1"}}, [][]string{{"Name", "Qty"}, {"a", "1"}}},
+		{"wide", [][]string{{"Name", "a", "b", "c"}, {"Qty", "1", "2", "3"}},
+			[][]string{{"Name", "Qty"}, {"a", "1"}, {"b", "2"}, {"c", "3"}}},
+		{"short rows padded", [][]string{{"Name", "a", "b"}, {"Qty"}}, [][]string{{"Name", "Qty"}, {"a", ""}, {"b", ""}}},
+		{"single row", [][]string{{"Name", "Qty"}}, [][]string{{"Name"}, {"Qty"}}},
+		{"empty", [][]string{}, [][]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transpose(tt.records); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("transpose() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRowFilter(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    RowFilter
+		wantErr string
+	}{
+		{"Status=Active", RowFilter{Column: "Status", Operator: FilterEq, Value: "Active"}, ""},
+		{"Status!=Done", RowFilter{Column: "Status", Operator: FilterNe, Value: "Done"}, ""},
+		{"Name~smith", RowFilter{Column: "Name", Operator: FilterContains, Value: "smith"}, ""},
+		{"Qty>5", RowFilter{Column: "Qty", Operator: FilterGt, Value: "5"}, ""},
+		// Two character operators aren't read as one character and a value starting with =
+		{"Qty>=5", RowFilter{Column: "Qty", Operator: FilterGe, Value: "5"}, ""},
+		{"Qty<=5", RowFilter{Column: "Qty", Operator: FilterLe, Value: "5"}, ""},
+		{"Qty<5", RowFilter{Column: "Qty", Operator: FilterLt, Value: "5"}, ""},
+		{" Unit Price >= 1,5 ", RowFilter{Column: "Unit Price", Operator: FilterGe, Value: "1,5"}, ""},
+		{"Status=", RowFilter{Column: "Status", Operator: FilterEq, Value: ""}, ""},
+		// The leftmost operator splits, later operator characters belong to the value
+		{"Note=a=b", RowFilter{Column: "Note", Operator: FilterEq, Value: "a=b"}, ""},
+		{"Range~1<x>2", RowFilter{Column: "Range", Operator: FilterContains, Value: "1<x>2"}, ""},
+		{"Amount=>5", RowFilter{}, `invalid filter "Amount=>5": value ">5" starts with an operator character`},
+		{"Qty>==5", RowFilter{}, `invalid filter "Qty>==5": value "=5" starts with an operator character`},
+		{"Qty=~5", RowFilter{}, `invalid filter "Qty=~5": value "~5" starts with an operator character`},
+		{"Qty==5", RowFilter{}, `invalid filter "Qty==5": value "=5" starts with an operator character`},
+		{"Qty", RowFilter{}, `invalid filter "Qty": expected column, operator (=, !=, ~, >, >=, <, <=) and value`},
+		{"Qty<>5", RowFilter{}, `invalid filter "Qty<>5": value ">5" starts with an operator character`},
+		{" >=5", RowFilter{}, `invalid filter " >=5": missing column name`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseRowFilter(tt.expr)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseRowFilter() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRowFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   NumberLocale
+		cell     string
+		operator FilterOperator
+		value    string
+		want     bool
+	}{
+		{"text eq ignores case", NumberLocaleEN, " active ", FilterEq, "Active", true},
+		{"text ne", NumberLocaleEN, "Done", FilterNe, "done", false},
+		{"numeric eq", NumberLocaleEN, "10.0", FilterEq, "10", true},
+		{"numeric ne", NumberLocaleEN, "1,000", FilterNe, "1000", false},
+		{"contains ignores case", NumberLocaleEN, "John Smith", FilterContains, "SMITH", true},
+		{"gt", NumberLocaleEN, "1,234.5", FilterGt, "1000", true},
+		{"ge equal", NumberLocaleEN, "5", FilterGe, "5.0", true},
+		{"lt", NumberLocaleEN, "-2", FilterLt, "0", true},
+		{"le", NumberLocaleEN, "6", FilterLe, "5", false},
+		// Ordering operators only compare numbers
+		{"gt text", NumberLocaleEN, "b", FilterGt, "a", false},
+		{"lt empty cell", NumberLocaleEN, "", FilterLt, "5", false},
+		{"gt text value", NumberLocaleEN, "5", FilterGt, "many", false},
+		// The locale decides what separators mean, on both sides
+		{"EU gt", NumberLocaleEU, "1.234,5", FilterGt, "1000", true},
+		{"EN reads the EU number as 1.2345", NumberLocaleEN, "1.234,5", FilterGt, "1000", false},
+		{"EU eq", NumberLocaleEU, "2,5", FilterEq, "2,50", true},
+		{"EN eq compares 25 with 250", NumberLocaleEN, "2,5", FilterEq, "2,50", false},
+		{"EU value", NumberLocaleEU, "2,5", FilterLt, "2,6", true},
+		{"EU space separator", NumberLocaleEU, "1 234,5", FilterGe, "1234,5", true},
+		{"EU text eq", NumberLocaleEU, "2,5 kg", FilterEq, "2,5 KG", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter := NewExcelConverter()
+			converter.NumberLocale = tt.locale
+			filter := RowFilter{Column: "x", Operator: tt.operator, Value: tt.value}
+			if got := converter.matchFilter(tt.cell, filter); got != tt.want {
+				t.Errorf("matchFilter(%q %s %q) = %t, want %t", tt.cell, tt.operator, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterRowsUnknownOperator(t *testing.T) {
+	converter := NewExcelConverter()
+	converter.RowFilters = []RowFilter{{Column: "Qty", Operator: "between", Value: "1"}}
+
+	_, err := converter.filterRows([][]string{{"Qty"}, {"1"}})
+	if err == nil || err.Error() != `filter on 'Qty': unknown operator "between"` {
+		t.Errorf("filterRows() error = %v, want the unknown operator", err)
+	}
+}
@@ -0,0 +1,147 @@
+package excel2csv
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// ValidationReport describes what converting a file would produce
+type ValidationReport struct {
+	InputPath string
+	Sheets    []SheetReport
+}
+
+// SheetReport describes the table detected in a sheet
+type SheetReport struct {
+	Index     int
+	Name      string
+	TotalRows int      // rows exported from the sheet
+	StartRow  int      // first table row (0-based), usually the header, -1 if none
+	EndRow    int      // last table row (0-based), -1 if none
+	Rows      int      // rows that would be written, including the header unless OmitHeader is set
+	Columns   int      // columns that would be written
+	Warnings  []string // problems found while analyzing the sheet
+}
+
+// Validate enumerates the sheets of a file and runs table detection on each,
+// reporting what would be converted without writing any output. The cells of
+// CellRange are validated on every sheet, a NamedRange validates its own sheet only.
+func (ec *ExcelConverter) Validate(inputPath string) (*ValidationReport, error) {
+	if err := ec.checkInputFormat(inputPath); err != nil {
+		return nil, err
+	}
+
+	converter, err := ec.withNamedRange(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sheets, err := converter.sheetsToConvert(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if ec.NamedRange != "" {
+		sheets = slices.DeleteFunc(sheets, func(sheet SheetInfo) bool { return sheet.Name != converter.SheetName })
+	}
+
+	report := &ValidationReport{InputPath: inputPath}
+	for _, sheet := range sheets {
+		// Create a temporary converter for this sheet
+		tempConverter := converter.sheetConverter(sheet)
+
+		report.Sheets = append(report.Sheets, tempConverter.validateSheet(inputPath, sheet))
+	}
+
+	return report, nil
+}
+
+// validateSheet analyzes a single sheet
+func (ec *ExcelConverter) validateSheet(inputPath string, sheet SheetInfo) SheetReport {
+	sheetReport := SheetReport{
+		Index:    sheet.Index,
+		Name:     sheet.Name,
+		StartRow: -1,
+		EndRow:   -1,
+	}
+
+	records, err := ec.exportRecords(context.Background(), inputPath)
+	if err != nil {
+		sheetReport.Warnings = append(sheetReport.Warnings, fmt.Sprintf("conversion failed: %v", err))
+		return sheetReport
+	}
+
+	sheetReport.TotalRows = len(records)
+	if len(records) == 0 {
+		sheetReport.Warnings = append(sheetReport.Warnings, "sheet is empty")
+		return sheetReport
+	}
+
+	sheetReport.StartRow, sheetReport.EndRow = ec.tableBoundaries(records)
+
+	processedRecords, err := ec.processRecords(records)
+	if err != nil {
+		sheetReport.Warnings = append(sheetReport.Warnings, err.Error())
+		return sheetReport
+	}
+
+	sheetReport.Rows = len(ec.outputRows(processedRecords))
+	for _, record := range processedRecords {
+		if len(record) > sheetReport.Columns {
+			sheetReport.Columns = len(record)
+		}
+	}
+
+	if len(processedRecords) <= 1 {
+		sheetReport.Warnings = append(sheetReport.Warnings, "no data rows below the header")
+	}
+	if sheetReport.Columns < 2 {
+		sheetReport.Warnings = append(sheetReport.Warnings, "table has a single column, detection may have failed")
+	}
+
+	return sheetReport
+}
+
+// SheetRowCount is the number of rows of a sheet
+type SheetRowCount struct {
+	Index int
+	Name  string
+	Rows  int // rows exported from the sheet
+}
+
+// CountRows returns the number of rows of every sheet, or of the sheets matching
+// SheetPattern, in workbook order, without table detection or writing any output.
+// Rows above and below the table are counted, and MaxRows doesn't apply. It is not
+// faster than converting: there is no reader that counts rows without LibreOffice,
+// so every sheet is exported by its own LibreOffice run.
+func (ec *ExcelConverter) CountRows(inputPath string) ([]SheetRowCount, error) {
+	return ec.CountRowsContext(context.Background(), inputPath)
+}
+
+// CountRowsContext is like CountRows, but stops and kills LibreOffice when ctx is done
+func (ec *ExcelConverter) CountRowsContext(ctx context.Context, inputPath string) ([]SheetRowCount, error) {
+	if err := ec.checkInputFormat(inputPath); err != nil {
+		return nil, err
+	}
+
+	sheets, err := ec.sheetsToConvert(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]SheetRowCount, 0, len(sheets))
+	for _, sheet := range sheets {
+		// Create a temporary converter for this sheet
+		tempConverter := ec.sheetConverter(sheet)
+		tempConverter.DiagnosticsPath = ""
+		tempConverter.MaxRows = 0
+
+		records, err := tempConverter.readSheet(ctx, inputPath)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", sheet.Name, err)
+		}
+		counts = append(counts, SheetRowCount{Index: sheet.Index, Name: sheet.Name, Rows: len(records)})
+	}
+
+	return counts, nil
+}
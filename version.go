@@ -0,0 +1,10 @@
+package excel2csv
+
+// version is the package build version, overridden at build time with
+// -ldflags "-X github.com/oxyii/excel2csv.version=<version>"
+var version = "1.1.0"
+
+// Version returns the excel2csv build version
+func Version() string {
+	return version
+}